@@ -0,0 +1,127 @@
+package client
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+)
+
+// plainConfirmation ответ, которым пользователь подтверждает выгрузку данных без шифрования
+const plainConfirmation = "yes"
+
+// runExport выгружает все записи пользователя в зашифрованный архив или, с подтверждением, в открытый json/csv.
+// Использование: export [--plain json|csv] file
+func runExport(c repo.Client, args []string) error {
+	fs := flag.NewFlagSet("export", flag.ContinueOnError)
+	plain := fs.String("plain", "", "write unencrypted json or csv instead of encrypted archive")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: export [--plain json|csv] file")
+	}
+
+	var password string
+	switch *plain {
+	case "":
+		password, err = scanNewPassword("Type password for backup:")
+		if err != nil {
+			return err
+		}
+
+	case "json", "csv":
+		var answer string
+		err = scanValue(fmt.Sprintf("All passwords will be written to %s unencrypted. Type \"%s\" to continue:",
+			fs.Arg(0), plainConfirmation), &answer)
+		if err != nil {
+			return err
+		}
+		if answer != plainConfirmation {
+			return errors.New("export cancelled")
+		}
+
+	default:
+		return fmt.Errorf("unknown plain format %q, use json or csv", *plain)
+	}
+
+	authorize(&c)
+	records, err := c.GetActualData()
+	if err != nil {
+		return err
+	}
+
+	f, err := os.OpenFile(fs.Arg(0), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return err
+	}
+
+	switch *plain {
+	case "json":
+		err = logic.ExportJSON(f, records)
+	case "csv":
+		err = logic.ExportCSV(f, records)
+	default:
+		err = logic.WriteArchive(f, records, password, logic.DefaultKDFParams)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	fmt.Printf("Vault exported to %s\n", fs.Arg(0))
+
+	return f.Close()
+}
+
+// runImportBackup восстанавливает записи из зашифрованного архива. Записи, которые уже есть в аккаунте, пропускаются.
+// Использование: import-backup [--dry-run] file
+func runImportBackup(c repo.Client, args []string) error {
+	fs := flag.NewFlagSet("import-backup", flag.ContinueOnError)
+	dryRun := fs.Bool("dry-run", false, "only show what would be restored")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: import-backup [--dry-run] file")
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	var password string
+	err = scanValue("Type backup password:", &password)
+	if err != nil {
+		return err
+	}
+
+	backup, err := logic.ReadArchive(f, password)
+	if err != nil {
+		return err
+	}
+
+	authorize(&c)
+	existing, err := c.GetActualData()
+	if err != nil {
+		return err
+	}
+
+	fresh, duplicates := logic.Deduplicate(logic.RestoreEntries(backup), existing)
+	logic.PrintImport(fresh, duplicates)
+	fmt.Printf("backup from %s, new: %d, already present: %d\n",
+		backup.Created.Local().Format("2006-01-02 15:04:05"), len(fresh), len(duplicates))
+	if *dryRun {
+		return nil
+	}
+
+	return saveEntries(c, fresh)
+}
@@ -2,9 +2,12 @@ package client
 
 import (
 	"errors"
+	"flag"
 	"fmt"
 	"log"
 	"net/http"
+	"os"
+	"time"
 
 	logic "github.com/azazel3ooo/keeper/internal/logic/client"
 	"github.com/azazel3ooo/keeper/internal/models"
@@ -14,100 +17,107 @@ import (
 const BuildVersion = "v0.0.1"
 const BuildDate = "20.08.22"
 
-// Start производит инициализацию клиента, после чего запускает интерактивное меню
+// Start производит инициализацию клиента, после чего выполняет переданную в аргументах команду
+// или запускает интерактивное меню
 func Start() {
-	var cfg models.Config
+	var cfg models.ClientConfig
+	args, err := models.LoadConfig(&cfg, "client", "client_settings.yml", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(args) > 0 && args[0] == "profiles" {
+		err = printProfiles()
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
 
-	err := cfg.Init("client_settings.yml")
+	var (
+		s           repo.ClientStorage
+		sessionFile string
+	)
+	if cfg.Profile != "" {
+		var profile repo.Profile
+		cfg, profile, args, err = loadProfile(cfg.Profile)
+		if err != nil {
+			log.Fatal(err)
+		}
+		sessionFile = profile.SessionFile()
+		err = openProfileStorage(&s, profile, cfg)
+	} else {
+		err = s.Init(cfg.DbLocation)
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	var s repo.ClientStorage
-	err = s.Init(cfg.DbLocation)
+	cl, err := repo.NewHTTPClient(cfg.TLS)
 	if err != nil {
 		log.Fatal(err)
 	}
 
-	c := repo.NewClient(
+	opts := []func(*repo.Client){
 		repo.WithStorage(&s),
 		repo.WithConfig(cfg),
-		repo.WithClient(http.DefaultClient),
-	)
-
-	LoopMenu(*c)
-}
-
-// LoopMenu проводит авторизацию\регистрацию пользователя, после чего запускает зацикленное меню для выполнения действий
-// (добавление, удаление, обновление и получение полного списка записей)
-func LoopMenu(c repo.Client) {
-	// GET TOKEN STAGE
-	fmt.Println("Hello in our keeper\nChoose option")
-	fmt.Printf("Reqistration: type option \"r\"\n" +
-		"Authorization: type option \"a\"\n" +
-		"Build version: type option \"b\"\n" +
-		"Build date: type option \"d\"\n")
-
-	for !c.ReadyForActions() {
-		var option string
-		fmt.Printf("Type option:\n")
-		_, err := fmt.Scanf("%s\n", &option)
+		repo.WithClient(cl),
+		repo.WithSessionFile(sessionFile),
+	}
+	if cfg.Transport == models.TransportGRPC {
+		conn, err := repo.NewGRPCConn(cfg)
 		if err != nil {
-			fmt.Printf("Please try again, error: %s\n", err.Error())
-			continue
-		}
-
-		if option == "b" {
-			fmt.Println(BuildVersion)
-			continue
-		}
-		if option == "d" {
-			fmt.Println(BuildDate)
-			continue
+			log.Fatal(err)
 		}
+		defer conn.Close()
+		opts = append(opts, repo.WithRPC(conn))
+	}
 
-		var requestBody models.UserRequest
-		fmt.Printf("Type your login:\n")
-		_, err = fmt.Scanf("%s\n", &requestBody.Login)
-		if err != nil {
-			fmt.Printf("Please try again, error: %s\n", err.Error())
-			continue
-		}
+	c := repo.NewClient(opts...)
 
-		fmt.Printf("Type your password:\n")
-		_, err = fmt.Scanf("%s\n", &requestBody.Password)
+	if len(args) > 0 {
+		err = runCommand(*c, args[0], args[1:])
 		if err != nil {
-			fmt.Printf("Please try again, error: %s\n", err.Error())
-			continue
+			log.Fatal(err)
 		}
+		return
+	}
 
-		var reqAddr string
-		if option == "r" {
-			reqAddr = c.RegistrationAddress()
-		}
-		if option == "a" {
-			reqAddr = c.AuthorizationAddress()
-		}
-		if reqAddr == "" {
-			fmt.Printf("Unknown option. Please, try again\n")
-			continue
-		}
+	LoopMenu(*c)
+}
 
-		err = c.GetToken(requestBody, reqAddr)
-		if err != nil {
-			fmt.Printf("Please try again, error: %s\n", err.Error())
-			continue
-		}
+// runCommand выполняет команду клиента, переданную в аргументах запуска
+func runCommand(c repo.Client, command string, args []string) error {
+	switch command {
+	case "import":
+		return runImport(c, args)
+	case "export":
+		return runExport(c, args)
+	case "import-backup":
+		return runImportBackup(c, args)
+	case "generate":
+		return runGenerate(args)
+	case "health":
+		return runHealth(c, args)
+	case "due":
+		return runDue(c, args)
+	case "rotate":
+		return runRotate(c, args)
+	case "watch":
+		return runWatch(c, args)
+	}
 
-		if option == "a" {
-			err = c.ActualizeStorage()
-			if err != nil {
-				log.Println("can't actualize storage: " + err.Error())
-			}
-		}
+	return fmt.Errorf("unknown command %q, available commands: import, export, import-backup, generate, health, due, "+
+		"rotate, watch, profiles", command)
+}
 
-	}
-	// GET TOKEN STAGE
+// LoopMenu проводит авторизацию\регистрацию пользователя, после чего запускает зацикленное меню для выполнения действий
+// (добавление, удаление, обновление и получение полного списка записей)
+func LoopMenu(c repo.Client) {
+	authorize(&c)
 
 	// GET OPTION STAGE
 	fmt.Printf("Choose action:\n" +
@@ -115,6 +125,19 @@ func LoopMenu(c repo.Client) {
 		"Update: type u\n" +
 		"Delete: type d\n" +
 		"Get data list: type g\n" +
+		"Show folder tree: type f\n" +
+		"Create folder: type n\n" +
+		"Move records to folder: type m\n" +
+		"Get one-time code for login: type o\n" +
+		"Reveal record (card details, hidden fields): type c\n" +
+		"Enable or disable two-factor authentication: type t\n" +
+		"List sessions (devices): type s\n" +
+		"Revoke session: type r\n" +
+		"View audit log: type v\n" +
+		"Change password: type p\n" +
+		"Change login: type l\n" +
+		"Regenerate or revoke recovery key: type k\n" +
+		"Delete account: type x\n" +
 		"Quit: type q\n")
 
 	finished := false
@@ -129,9 +152,40 @@ func LoopMenu(c repo.Client) {
 
 		switch action {
 		case "a":
+			var recordType string
+			err = scanValue("Type record type (text, login, card):", &recordType)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			record, err := askRecord(recordType)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			logic.StampPassword(&record, models.Record{}, time.Now())
+
+			err = askExtras(&record)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
 			var req models.UserData
-			fmt.Printf("Type your data:\n")
-			_, err = fmt.Scanf("%s\n", &req.Data)
+			req.Folder, err = askFolder(c, "Type folder")
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = askName(c, &record, req.Folder, "")
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = logic.EncodeRecord(&req, record)
 			if err != nil {
 				fmt.Printf("Please try again, error: %s\n", err.Error())
 				continue
@@ -155,16 +209,51 @@ func LoopMenu(c repo.Client) {
 			}
 
 		case "u":
-			var req models.UserData
-			fmt.Printf("Type data ID:\n")
-			_, err = fmt.Scanf("%s\n", &req.ID)
+			existing, err := askRecordRef(c, "Type record to update")
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			data, err := c.GetAll()
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			prev := models.ParseRecord(existing.Data)
+			if prev.Type == models.RecordFolder {
+				err = renameFolder(c, data, existing.ID)
+				if errors.Is(err, models.ErrExpiredToken) {
+					finished = true
+				}
+				if err != nil {
+					fmt.Printf("Please try again, error: %s\n", err.Error())
+				}
+				continue
+			}
+			req := models.UserData{ID: existing.ID, Folder: existing.Folder}
+			record, err := askRecord(prev.Type)
 			if err != nil {
 				fmt.Printf("Please try again, error: %s\n", err.Error())
 				continue
 			}
+			logic.StampPassword(&record, prev, time.Now())
 
-			fmt.Printf("Type your data:\n")
-			_, err = fmt.Scanf("%s\n", &req.Data)
+			record.Fields, record.Attachments = prev.Fields, prev.Attachments
+			err = askExtras(&record)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			record.Name = prev.Name
+			err = askName(c, &record, existing.Folder, existing.ID)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = logic.EncodeRecord(&req, record)
 			if err != nil {
 				fmt.Printf("Please try again, error: %s\n", err.Error())
 				continue
@@ -187,14 +276,13 @@ func LoopMenu(c repo.Client) {
 			}
 
 		case "d":
-			var req models.DeleteRequest
-			fmt.Printf("Type ID:\n")
-			_, err = fmt.Scanf("%s\n", &req.ID)
+			existing, err := askRecordRef(c, "Type record to delete")
 			if err != nil {
 				fmt.Printf("Please try again, error: %s\n", err.Error())
 				continue
 			}
 
+			req := models.DeleteRequest{ID: existing.ID}
 			err = logic.ActionProcessing(req, c, c.ActionAddr(), http.MethodDelete, logic.Delete)
 			if errors.Is(err, models.ErrExpiredToken) {
 				finished = true
@@ -210,7 +298,224 @@ func LoopMenu(c repo.Client) {
 				fmt.Printf("Please try again, error: %s\n", err.Error())
 				continue
 			}
-			logic.PrintData(data)
+			breached, err := checkBreaches(c, data)
+			if err != nil {
+				fmt.Printf("Can't check passwords for breaches: %s\n", err.Error())
+			}
+			logic.PrintData(data, breached)
+
+		case "f":
+			data, err := c.GetAll()
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			logic.PrintTree(data)
+
+		case "n":
+			err = createFolder(c)
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+		case "m":
+			err = moveRecords(c)
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+		case "o":
+			record, err := askRecordRef(c, "Type login")
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			code, remaining, err := logic.OneTimeCode(record, time.Now())
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			fmt.Printf("%s (valid for %s)\n", code, remaining)
+
+		case "c":
+			existing, err := askRecordRef(c, "Type record to reveal")
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			fmt.Println(logic.RevealRecord(models.ParseRecord(existing.Data)))
+
+		case "t":
+			err = toggleTOTP(c)
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+		case "s":
+			sessions, err := c.GetSessions()
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			logic.PrintSessions(sessions)
+
+		case "r":
+			var id string
+			err = scanValue("Type session ID (or \"all\" to revoke all sessions except current):", &id)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			req := models.RevokeSessionRequest{ID: id}
+			if id == "all" {
+				req = models.RevokeSessionRequest{All: true}
+			}
+			err = c.RevokeSession(req)
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+		case "v":
+			events, err := c.GetAudit(0)
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			logic.PrintAudit(events)
+
+		case "p":
+			var req models.ChangePasswordRequest
+			err = scanValue("Type your current password:", &req.Password)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = scanValue("Type new password:", &req.NewPassword)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = c.ChangePassword(req)
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			fmt.Println("Password changed, other sessions are signed out")
+
+		case "l":
+			var req models.ChangeLoginRequest
+			err = scanValue("Type new login:", &req.NewLogin)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = scanValue("Type your password:", &req.Password)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = c.ChangeLogin(req)
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+		case "k":
+			var choice string
+			err = scanValue("Type \"new\" to regenerate recovery key or \"revoke\" to revoke it:", &choice)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+			if choice != "new" && choice != "revoke" {
+				fmt.Printf("Unknown option. Please, try again\n")
+				continue
+			}
+
+			var req models.RecoveryKeyRequest
+			err = scanValue("Type your password:", &req.Password)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			var key string
+			if choice == "new" {
+				key, err = c.RegenerateRecoveryKey(req)
+			} else {
+				err = c.RevokeRecoveryKey(req)
+			}
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			if key != "" {
+				fmt.Printf("Your new recovery key: %s\nSave it, it will not be shown again\n", key)
+			} else {
+				fmt.Println("Recovery key revoked")
+			}
+
+		case "x":
+			var req models.DeleteAccountRequest
+			err = scanValue("All your data will be deleted. Type your password to confirm:", &req.Password)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = c.DeleteAccount(req)
+			if errors.Is(err, models.ErrExpiredToken) {
+				finished = true
+			}
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+				continue
+			}
+
+			err = c.ClearLocal()
+			if err != nil {
+				log.Println("can't clear local storage: " + err.Error())
+			}
+			fmt.Println("Account deleted")
+			finished = true
 
 		case "q":
 			finished = true
@@ -223,3 +528,100 @@ func LoopMenu(c repo.Client) {
 
 	fmt.Println("Bye!")
 }
+
+// authorize проводит регистрацию или авторизацию пользователя, пока клиент не получит токен.
+// Если у профиля есть действующая сохраненная сессия, вход не требуется
+func authorize(c *repo.Client) {
+	if c.RestoreSession() {
+		err := c.ActualizeStorage()
+		if err != nil {
+			log.Println("can't actualize storage: " + err.Error())
+		}
+		return
+	}
+
+	fmt.Println("Hello in our keeper\nChoose option")
+	fmt.Printf("Reqistration: type option \"r\"\n" +
+		"Authorization: type option \"a\"\n" +
+		"Reset password with recovery key: type option \"k\"\n" +
+		"Build version: type option \"b\"\n" +
+		"Build date: type option \"d\"\n")
+
+	for !c.ReadyForActions() {
+		var option string
+		fmt.Printf("Type option:\n")
+		_, err := fmt.Scanf("%s\n", &option)
+		if err != nil {
+			fmt.Printf("Please try again, error: %s\n", err.Error())
+			continue
+		}
+
+		if option == "b" {
+			fmt.Println(BuildVersion)
+			continue
+		}
+		if option == "d" {
+			fmt.Println(BuildDate)
+			continue
+		}
+		if option == "k" {
+			err = recoverAccount(*c)
+			if err != nil {
+				fmt.Printf("Please try again, error: %s\n", err.Error())
+			}
+			continue
+		}
+
+		var requestBody models.UserRequest
+		fmt.Printf("Type your login:\n")
+		_, err = fmt.Scanf("%s\n", &requestBody.Login)
+		if err != nil {
+			fmt.Printf("Please try again, error: %s\n", err.Error())
+			continue
+		}
+
+		fmt.Printf("Type your password:\n")
+		_, err = fmt.Scanf("%s\n", &requestBody.Password)
+		if err != nil {
+			fmt.Printf("Please try again, error: %s\n", err.Error())
+			continue
+		}
+
+		var reqAddr string
+		if option == "r" {
+			reqAddr = c.RegistrationAddress()
+		}
+		if option == "a" {
+			reqAddr = c.AuthorizationAddress()
+		}
+		if reqAddr == "" {
+			fmt.Printf("Unknown option. Please, try again\n")
+			continue
+		}
+
+		err = c.GetToken(requestBody, reqAddr)
+		if errors.Is(err, models.ErrSecondFactorRequired) {
+			fmt.Printf("Type one-time code from your authenticator app (or recovery code):\n")
+			_, err = fmt.Scanf("%s\n", &requestBody.Code)
+			if err == nil {
+				err = c.GetToken(requestBody, reqAddr)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Please try again, error: %s\n", err.Error())
+			continue
+		}
+
+		if key := c.RecoveryKey(); key != "" {
+			fmt.Printf("Your recovery key: %s\nSave it, it will not be shown again. It is the only way to reset a forgotten password\n", key)
+		}
+
+		if option == "a" {
+			err = c.ActualizeStorage()
+			if err != nil {
+				log.Println("can't actualize storage: " + err.Error())
+			}
+		}
+
+	}
+}
@@ -0,0 +1,117 @@
+package client
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+	"github.com/azazel3ooo/keeper/internal/models"
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+)
+
+// askFolder запрашивает путь папки вида work/dev и возвращает ее id. Пропуск означает корень
+func askFolder(c repo.Client, prompt string) (string, error) {
+	var path string
+	err := scanLine(fmt.Sprintf("%s, e.g. work/dev (or \"%s\" for root):", prompt, skipValue), &path)
+	if err != nil || path == skipValue {
+		return "", err
+	}
+
+	data, err := c.GetAll()
+	if err != nil {
+		return "", err
+	}
+
+	return logic.ResolveFolder(data, path)
+}
+
+// createFolder создает папку в выбранной пользователем папке
+func createFolder(c repo.Client) error {
+	var name string
+	err := scanLine("Type folder name:", &name)
+	if err != nil {
+		return err
+	}
+
+	parent, err := askFolder(c, "Type parent folder")
+	if err != nil {
+		return err
+	}
+
+	data, err := c.GetAll()
+	if err != nil {
+		return err
+	}
+	folder, err := logic.NewFolder(data, name, parent)
+	if err != nil {
+		return err
+	}
+
+	return saveFolders(c, []models.UserData{folder})
+}
+
+// renameFolder запрашивает новое имя папки и сохраняет ее
+func renameFolder(c repo.Client, data []models.UserData, id string) error {
+	var name string
+	err := scanLine("Type new folder name:", &name)
+	if err != nil {
+		return err
+	}
+
+	folder, err := logic.RenameFolder(data, id, name)
+	if err != nil {
+		return err
+	}
+
+	return logic.ActionProcessing(folder, c, c.ActionAddr(), http.MethodPatch, logic.Update)
+}
+
+// moveRecords переносит записи и папки, заданные именами, путями или id, в выбранную папку
+func moveRecords(c repo.Client) error {
+	var refs string
+	err := scanLine("Type records and folders to move (names, paths like work/github or IDs), separated by commas:", &refs)
+	if err != nil {
+		return err
+	}
+
+	var req models.MoveRequest
+	for _, ref := range strings.Split(refs, ",") {
+		if strings.TrimSpace(ref) == "" {
+			continue
+		}
+		el, err := findRecord(c, ref)
+		if err != nil {
+			return err
+		}
+		req.IDs = append(req.IDs, el.ID)
+	}
+
+	req.Folder, err = askFolder(c, "Type destination folder")
+	if err != nil {
+		return err
+	}
+
+	data, err := c.GetAll()
+	if err != nil {
+		return err
+	}
+	err = logic.CheckMove(data, req)
+	if err != nil {
+		return err
+	}
+
+	return logic.ActionProcessing(req, c, c.MoveAddr(), http.MethodPatch, logic.Move)
+}
+
+// saveFolders сохраняет созданные папки локально и на сервере. Родительские папки должны идти раньше вложенных
+func saveFolders(c repo.Client, folders []models.UserData) error {
+	for _, el := range folders {
+		err := logic.ActionProcessing(el, c, c.ActionAddr(), http.MethodPost, logic.Set)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+)
+
+// runGenerate генерирует пароль или фразу и выводит оценку энтропии.
+// Использование: generate [--length 20] [--no-symbols ...] [--exclude-ambiguous] | --passphrase [--words 6]
+func runGenerate(args []string) error {
+	def, defPhrase := logic.DefaultPasswordOptions, logic.DefaultPassphraseOptions
+
+	fs := flag.NewFlagSet("generate", flag.ContinueOnError)
+	length := fs.Int("length", def.Length, "password length")
+	noLower := fs.Bool("no-lower", false, "exclude lowercase letters")
+	noUpper := fs.Bool("no-upper", false, "exclude uppercase letters")
+	noDigits := fs.Bool("no-digits", false, "exclude digits")
+	noSymbols := fs.Bool("no-symbols", false, "exclude symbols")
+	ambiguous := fs.Bool("exclude-ambiguous", false, "exclude look-alike characters ("+logic.AmbiguousChars+")")
+	passphrase := fs.Bool("passphrase", false, "generate a passphrase of random words instead of a password")
+	words := fs.Int("words", defPhrase.Words, "number of passphrase words")
+	separator := fs.String("separator", defPhrase.Separator, "passphrase word separator")
+	capitalize := fs.Bool("capitalize", false, "capitalize passphrase words")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: generate [--length n] [--no-lower] [--no-upper] [--no-digits] [--no-symbols] " +
+			"[--exclude-ambiguous] | --passphrase [--words n] [--separator s] [--capitalize]")
+	}
+
+	var res logic.Generated
+	if *passphrase {
+		res, err = logic.GeneratePassphrase(logic.PassphraseOptions{Words: *words, Separator: *separator, Capitalize: *capitalize})
+	} else {
+		res, err = logic.GeneratePassword(logic.PasswordOptions{
+			Length:           *length,
+			Lower:            !*noLower,
+			Upper:            !*noUpper,
+			Digits:           !*noDigits,
+			Symbols:          !*noSymbols,
+			ExcludeAmbiguous: *ambiguous,
+		})
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Println(res.Value)
+	fmt.Printf("Entropy: ~%.0f bits\n", res.Entropy)
+	return nil
+}
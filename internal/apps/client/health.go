@@ -0,0 +1,72 @@
+package client
+
+import (
+	"encoding/json"
+	"errors"
+	"flag"
+	"os"
+	"time"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+	"github.com/azazel3ooo/keeper/internal/models"
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+)
+
+// runHealth проверяет пароли в локальной базе и выводит отчет таблицей или в json.
+// Использование: health [--min-entropy 60] [--max-age-days 180] [--json]
+func runHealth(c repo.Client, args []string) error {
+	def := logic.DefaultHealthOptions
+
+	fs := flag.NewFlagSet("health", flag.ContinueOnError)
+	minEntropy := fs.Float64("min-entropy", def.MinEntropy, "passwords with lower entropy estimate (bits) are weak")
+	maxAge := fs.Int("max-age-days", int(def.MaxAge/(24*time.Hour)), "passwords not changed for longer are old, 0 disables the check")
+	asJSON := fs.Bool("json", false, "print report as json")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: health [--min-entropy bits] [--max-age-days days] [--json]")
+	}
+
+	data, err := c.GetAll()
+	if err != nil {
+		return err
+	}
+
+	report := logic.CheckHealth(data, logic.HealthOptions{
+		MinEntropy: *minEntropy,
+		MaxAge:     time.Duration(*maxAge) * 24 * time.Hour,
+	}, time.Now())
+
+	breached, err := checkBreaches(c, data)
+	if err != nil {
+		return err
+	}
+	report.MarkBreached(breached)
+
+	if *asJSON {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(report)
+	}
+
+	logic.PrintHealth(report)
+	return nil
+}
+
+// checkBreaches проверяет пароли по локальному файлу утекших паролей, если он задан в настройках
+func checkBreaches(c repo.Client, data []models.UserData) (map[string]int, error) {
+	if c.BreachListPath() == "" {
+		return nil, nil
+	}
+
+	list, err := logic.OpenBreachList(c.BreachListPath())
+	if err != nil {
+		return nil, err
+	}
+	defer list.Close()
+
+	return logic.CheckBreaches(data, list)
+}
@@ -0,0 +1,307 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+	"github.com/azazel3ooo/keeper/internal/models"
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+)
+
+const (
+	// skipValue значение, которое пользователь вводит, чтобы оставить необязательное поле пустым
+	skipValue = "-"
+
+	// generateValue значение, которое пользователь вводит вместо пароля, чтобы сгенерировать его
+	generateValue = "gen"
+
+	// removeValue значение, которое пользователь вводит, чтобы удалить дополнительное поле или вложение
+	removeValue = "del"
+)
+
+// scanValue печатает подсказку и считывает введенное пользователем значение
+func scanValue(prompt string, dst *string) error {
+	fmt.Printf("%s\n", prompt)
+	_, err := fmt.Scanf("%s\n", dst)
+	return err
+}
+
+// scanNewPassword запрашивает новый пароль дважды и проверяет, что он введен одинаково
+func scanNewPassword(prompt string) (string, error) {
+	var password, repeated string
+	err := scanValue(prompt, &password)
+	if err != nil {
+		return "", err
+	}
+	err = scanValue("Repeat password:", &repeated)
+	if err != nil {
+		return "", err
+	}
+	if password != repeated {
+		return "", errors.New("passwords do not match")
+	}
+
+	return password, nil
+}
+
+// scanLine печатает подсказку и считывает строку целиком, включая пробелы
+func scanLine(prompt string, dst *string) error {
+	fmt.Printf("%s\n", prompt)
+	var (
+		line []rune
+		r    rune
+	)
+	for {
+		_, err := fmt.Scanf("%c", &r)
+		if err != nil {
+			return err
+		}
+		if r == '\n' {
+			break
+		}
+		line = append(line, r)
+	}
+
+	*dst = strings.TrimSpace(string(line))
+	return nil
+}
+
+// scanOptional работает как scanValue, но значение skipValue оставляет поле пустым
+func scanOptional(prompt string, dst *string) error {
+	err := scanValue(fmt.Sprintf("%s (or \"%s\" to skip):", prompt, skipValue), dst)
+	if *dst == skipValue {
+		*dst = ""
+	}
+	return err
+}
+
+// askRecord запрашивает у пользователя поля записи указанного типа
+func askRecord(recordType string) (models.Record, error) {
+	switch recordType {
+	case models.RecordText:
+		r := models.Record{Type: models.RecordText}
+		err := scanValue("Type your data:", &r.Text)
+		return r, err
+
+	case models.RecordLogin:
+		r := models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{}}
+		err := scanValue("Type login:", &r.Login.Login)
+		if err != nil {
+			return r, err
+		}
+
+		err = scanValue(fmt.Sprintf("Type password (or \"%s\" to generate):", generateValue), &r.Login.Password)
+		if err != nil {
+			return r, err
+		}
+		if r.Login.Password == generateValue {
+			generated, err := logic.GeneratePassword(logic.DefaultPasswordOptions)
+			if err != nil {
+				return r, err
+			}
+			r.Login.Password = generated.Value
+			fmt.Printf("Generated password: %s (entropy ~%.0f bits)\n", generated.Value, generated.Entropy)
+		}
+
+		err = scanOptional("Type url", &r.Login.URL)
+		if err != nil {
+			return r, err
+		}
+
+		err = scanOptional("Type one-time password secret (otpauth:// uri or base32 key)", &r.Login.OTP)
+		if err != nil {
+			return r, err
+		}
+		if r.Login.OTP != "" {
+			err = logic.ValidOTP(r.Login.OTP)
+			if err != nil {
+				return r, err
+			}
+		}
+
+		var expiry string
+		err = scanOptional("Type password rotation interval in days or expiry date YYYY-MM-DD", &expiry)
+		if err != nil {
+			return r, err
+		}
+		err = logic.SetExpiry(&r, expiry, time.Now())
+		return r, err
+
+	case models.RecordCard:
+		r := models.Record{Type: models.RecordCard, Card: &models.CardRecord{}}
+		err := scanLine("Type card number:", &r.Card.Number)
+		if err != nil {
+			return r, err
+		}
+
+		err = scanLine("Type card holder name:", &r.Card.Holder)
+		if err != nil {
+			return r, err
+		}
+
+		err = scanValue("Type expiry date MM/YY:", &r.Card.Expiry)
+		if err != nil {
+			return r, err
+		}
+
+		err = scanOptional("Type CVV", &r.Card.CVV)
+		if err != nil {
+			return r, err
+		}
+
+		err = scanOptional("Type PIN", &r.Card.PIN)
+		if err != nil {
+			return r, err
+		}
+
+		err = logic.ValidateCard(r.Card)
+		if err != nil {
+			return r, err
+		}
+		if logic.CardExpired(*r.Card, time.Now()) {
+			fmt.Println("Warning: this card has expired")
+		}
+		return r, nil
+	}
+
+	return models.Record{}, models.ErrUnknownRecordType
+}
+
+// askExtras по желанию пользователя изменяет дополнительные поля и вложения записи
+func askExtras(r *models.Record) error {
+	var answer string
+	err := scanValue(fmt.Sprintf("Edit custom fields and attachments (%d fields, %d attachments)? (yes/no)",
+		len(r.Fields), len(r.Attachments)), &answer)
+	if err != nil || answer != "yes" {
+		return err
+	}
+
+	for {
+		var name string
+		err = scanLine(fmt.Sprintf("Type custom field name (or \"%s\" to finish):", skipValue), &name)
+		if err != nil {
+			return err
+		}
+		if name == skipValue || name == "" {
+			break
+		}
+
+		var fieldType string
+		err = scanValue(fmt.Sprintf("Type field type (%s, %s, %s, %s) or \"%s\" to remove the field:", models.CustomFieldText,
+			models.CustomFieldHidden, models.CustomFieldURL, models.CustomFieldDate, removeValue), &fieldType)
+		if err != nil {
+			return err
+		}
+		if fieldType == removeValue {
+			r.Fields, err = logic.RemoveField(r.Fields, name)
+		} else {
+			f := models.CustomField{Name: name, Type: fieldType}
+			err = scanLine("Type field value:", &f.Value)
+			if err != nil {
+				return err
+			}
+			r.Fields, err = logic.SetField(r.Fields, f)
+		}
+		if err != nil {
+			fmt.Printf("Please try again, error: %s\n", err.Error())
+		}
+	}
+
+	for {
+		var ref string
+		err = scanLine(fmt.Sprintf("Type attachment file path or url, \"%s name\" to remove an attachment (or \"%s\" to finish):",
+			removeValue, skipValue), &ref)
+		if err != nil {
+			return err
+		}
+		if ref == skipValue || ref == "" {
+			break
+		}
+
+		if name, ok := strings.CutPrefix(ref, removeValue+" "); ok {
+			r.Attachments, err = logic.RemoveAttachment(r.Attachments, name)
+		} else {
+			var a models.Attachment
+			a, err = logic.NewAttachment(ref)
+			if err == nil {
+				r.Attachments = logic.SetAttachment(r.Attachments, a)
+			}
+		}
+		if err != nil {
+			fmt.Printf("Please try again, error: %s\n", err.Error())
+		}
+	}
+
+	return nil
+}
+
+// recoverAccount сбрасывает пароль по ключу восстановления и показывает выданный взамен новый ключ
+func recoverAccount(c repo.Client) error {
+	var req models.RecoveryRequest
+	err := scanValue("Type your login:", &req.Login)
+	if err != nil {
+		return err
+	}
+
+	err = scanValue("Type your recovery key:", &req.RecoveryKey)
+	if err != nil {
+		return err
+	}
+
+	err = scanValue("Type new password:", &req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	key, err := c.RecoverAccount(req)
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Password changed, all sessions are signed out. Your new recovery key: %s\nSave it, it will not be shown again\n", key)
+	return nil
+}
+
+// toggleTOTP подключает второй фактор, а если он уже подключен, отключает его после проверки кода
+func toggleTOTP(c repo.Client) error {
+	enroll, err := c.EnrollTOTP()
+	if errors.Is(err, models.ErrTOTPEnabled) {
+		var code string
+		err = scanValue("Two-factor authentication is enabled. To disable it type one-time code from your "+
+			"authenticator app (or recovery code):", &code)
+		if err != nil {
+			return err
+		}
+
+		err = c.DisableTOTP(code)
+		if err != nil {
+			return err
+		}
+		fmt.Println("Two-factor authentication disabled")
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Add this key to your authenticator app:\n%s\nor type the secret manually: %s\n", enroll.URI, enroll.Secret)
+	var code string
+	err = scanValue("Type one-time code from your authenticator app:", &code)
+	if err != nil {
+		return err
+	}
+
+	codes, err := c.ConfirmTOTP(code)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Two-factor authentication enabled. Save your recovery codes, they will not be shown again:")
+	for _, code := range codes {
+		fmt.Println(code)
+	}
+	return nil
+}
@@ -0,0 +1,111 @@
+package client
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"os"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+	"github.com/azazel3ooo/keeper/internal/models"
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+)
+
+// runImport импортирует записи из экспорта другого менеджера паролей.
+// Использование: import --format bitwarden|keepass|1password|csv [--map title=Name,...] [--dry-run] file
+func runImport(c repo.Client, args []string) error {
+	fs := flag.NewFlagSet("import", flag.ContinueOnError)
+	format := fs.String("format", "", "export format: bitwarden, keepass, 1password or csv")
+	columns := fs.String("map", "", "csv column mapping, e.g. title=Name,login=User,password=Pass,url=Site,otp=TOTP,notes=Extra")
+	dryRun := fs.Bool("dry-run", false, "only show what would be imported")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: import --format bitwarden|keepass|1password|csv [--map ...] [--dry-run] file")
+	}
+
+	mapping, err := logic.ParseMapping(*columns)
+	if err != nil {
+		return err
+	}
+
+	f, err := os.Open(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	entries, skipped, err := logic.ParseImport(*format, f, mapping)
+	if err != nil {
+		return err
+	}
+
+	authorize(&c)
+	err = c.ActualizeStorage()
+	if err != nil {
+		return err
+	}
+
+	existing, err := c.GetAll()
+	if err != nil {
+		return err
+	}
+
+	fresh, duplicates := logic.Deduplicate(entries, existing)
+	logic.PrintImport(fresh, duplicates)
+	fmt.Printf("new: %d, duplicates: %d, empty skipped: %d\n", len(fresh), len(duplicates), skipped)
+	if *dryRun {
+		return nil
+	}
+
+	return saveEntries(c, fresh)
+}
+
+// saveEntries сохраняет записи локально и на сервере с новыми id. Недостающие папки из путей записей создаются
+func saveEntries(c repo.Client, entries []logic.ImportEntry) error {
+	data, err := c.GetAll()
+	if err != nil {
+		return err
+	}
+
+	for i, entry := range entries {
+		path := entry.Folder
+		if entry.Record.Type == models.RecordFolder {
+			path += logic.FolderSeparator + entry.Record.Text
+		}
+		folder, created, err := logic.EnsureFolder(data, path)
+		if err == nil {
+			err = saveFolders(c, created)
+		}
+		if err != nil {
+			return fmt.Errorf("imported %d of %d records: %w", i, len(entries), err)
+		}
+		data = append(data, created...)
+		if entry.Record.Type == models.RecordFolder {
+			continue
+		}
+
+		if logic.ValidateName(data, entry.Record.Name, folder, "") != nil {
+			// имя уже занято в папке, запись сохраняется без имени и доступна по id
+			entry.Record.Name = ""
+		}
+		req, err := entry.UserData()
+		if err != nil {
+			return err
+		}
+		req.Folder = folder
+
+		err = logic.ActionProcessing(req, c, c.ActionAddr(), http.MethodPost, logic.Set)
+		if err != nil {
+			return fmt.Errorf("imported %d of %d records: %w", i, len(entries), err)
+		}
+		data = append(data, req)
+	}
+	fmt.Printf("Imported %d records\n", len(entries))
+
+	return nil
+}
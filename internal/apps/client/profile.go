@@ -0,0 +1,105 @@
+package client
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+	"github.com/azazel3ooo/keeper/internal/models"
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+)
+
+// profilePasswordEnv переменная окружения с паролем профиля для запуска команд без ввода пароля
+const profilePasswordEnv = models.EnvPrefix + "PROFILE_PASSWORD"
+
+// loadProfile загружает настройки из каталога профиля вместо client_settings.yml. При первом запуске профиля
+// его файл настроек создается из текущих флагов и переменных окружения, чтобы не указывать сервер каждый раз
+func loadProfile(name string) (models.ClientConfig, repo.Profile, []string, error) {
+	var cfg models.ClientConfig
+
+	profile, err := repo.OpenProfile(name)
+	if err != nil {
+		return cfg, profile, nil, err
+	}
+
+	args, err := models.LoadConfig(&cfg, "client", profile.ConfigFile(), os.Args[1:])
+	if err != nil {
+		return cfg, profile, nil, err
+	}
+
+	_, err = os.Stat(profile.ConfigFile())
+	if errors.Is(err, os.ErrNotExist) {
+		err = profile.Save(cfg)
+		if err != nil {
+			return cfg, profile, nil, err
+		}
+		fmt.Printf("Profile %s created in %s\n", profile.Name, profile.Dir)
+	}
+
+	profile.Resolve(&cfg)
+	return cfg, profile, args, nil
+}
+
+// openProfileStorage открывает зашифрованную локальную базу профиля. Ключ базы хранится в каталоге профиля
+// зашифрованным паролем профиля, при первом запуске пароль задается и ключ создается
+func openProfileStorage(s *repo.ClientStorage, profile repo.Profile, cfg models.ClientConfig) error {
+	password, fromEnv := os.LookupEnv(profilePasswordEnv)
+
+	f, err := os.Open(profile.KeyFile())
+	if errors.Is(err, os.ErrNotExist) {
+		if !fromEnv {
+			password, err = scanNewPassword("Set password for profile " + profile.Name + ":")
+			if err != nil {
+				return err
+			}
+		}
+
+		f, err = os.OpenFile(profile.KeyFile(), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		key, err := logic.NewProfileKey(f, password, logic.DefaultKDFParams)
+		if err != nil {
+			os.Remove(profile.KeyFile())
+			return err
+		}
+		return s.InitEncrypted(cfg.DbLocation, key)
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	if !fromEnv {
+		err = scanValue("Type password for profile "+profile.Name+":", &password)
+		if err != nil {
+			return err
+		}
+	}
+
+	key, err := logic.ReadProfileKey(f, password)
+	if err != nil {
+		return err
+	}
+	return s.InitEncrypted(cfg.DbLocation, key)
+}
+
+// printProfiles выводит созданные профили
+func printProfiles() error {
+	names, err := repo.ListProfiles()
+	if err != nil {
+		return err
+	}
+	if len(names) == 0 {
+		fmt.Println("No profiles, create one with --profile name")
+		return nil
+	}
+
+	for _, name := range names {
+		fmt.Println(name)
+	}
+	return nil
+}
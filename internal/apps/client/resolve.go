@@ -0,0 +1,82 @@
+package client
+
+import (
+	"fmt"
+	"strconv"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+	"github.com/azazel3ooo/keeper/internal/models"
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+)
+
+// findRecord ищет запись по id, префиксу id, имени, пути вида work/github или нечеткому совпадению с путем.
+// Если подходит несколько записей, пользователь выбирает нужную
+func findRecord(c repo.Client, query string) (models.UserData, error) {
+	data, err := c.GetAll()
+	if err != nil {
+		return models.UserData{}, err
+	}
+
+	found, err := logic.Resolve(data, query)
+	if err != nil {
+		return models.UserData{}, err
+	}
+	if len(found) == 1 {
+		return found[0], nil
+	}
+
+	fmt.Printf("Several records match %q:\n", query)
+	for i, el := range found {
+		fmt.Printf("%d) %s [%s] | %s\n", i+1, logic.RecordPath(data, el), el.ID, el.Comment)
+	}
+
+	var choice string
+	err = scanValue("Type number of the record:", &choice)
+	if err != nil {
+		return models.UserData{}, err
+	}
+	n, err := strconv.Atoi(choice)
+	if err != nil || n < 1 || n > len(found) {
+		return models.UserData{}, fmt.Errorf("%w: no record number %s", models.ErrNotFound, choice)
+	}
+
+	return found[n-1], nil
+}
+
+// askRecordRef запрашивает имя, путь или id записи и ищет ее через findRecord
+func askRecordRef(c repo.Client, prompt string) (models.UserData, error) {
+	var query string
+	err := scanLine(prompt+" (name, path like work/github or ID):", &query)
+	if err != nil {
+		return models.UserData{}, err
+	}
+
+	return findRecord(c, query)
+}
+
+// askName запрашивает имя записи, уникальное в папке folder. При изменении записи except пропуск сохраняет
+// текущее имя
+func askName(c repo.Client, r *models.Record, folder, except string) error {
+	prompt := fmt.Sprintf("Type record name, e.g. github (or \"%s\" to skip):", skipValue)
+	if r.Name != "" {
+		prompt = fmt.Sprintf("Type record name (or \"%s\" to keep %q):", skipValue, r.Name)
+	}
+
+	var name string
+	err := scanLine(prompt, &name)
+	if err != nil || name == skipValue || name == "" {
+		return err
+	}
+
+	data, err := c.GetAll()
+	if err != nil {
+		return err
+	}
+	err = logic.ValidateName(data, name, folder, except)
+	if err != nil {
+		return err
+	}
+
+	r.Name = name
+	return nil
+}
@@ -0,0 +1,115 @@
+package client
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/http"
+	"time"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/client"
+	"github.com/azazel3ooo/keeper/internal/models"
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+)
+
+// runDue выводит записи, пароли которых нужно сменить в ближайшие дни, и карты с истекающим сроком по данным сервера.
+// Использование: due [--days 30]
+func runDue(c repo.Client, args []string) error {
+	fs := flag.NewFlagSet("due", flag.ContinueOnError)
+	days := fs.Int("days", 0, "days ahead, server default is 30")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: due [--days n]")
+	}
+
+	authorize(&c)
+
+	data, err := c.GetDue(*days)
+	if err != nil {
+		return err
+	}
+	if len(data) == 0 {
+		fmt.Println("No passwords are due for rotation and no cards are expiring")
+		return nil
+	}
+
+	logic.PrintDue(data, time.Now())
+	return nil
+}
+
+// runRotate проводит смену пароля записи: генерирует новый пароль, ждет, пока пользователь сменит его на сайте,
+// и сохраняет запись с прежним паролем в истории.
+// Запись задается именем, путем вида work/github или id.
+// Использование: rotate [--length 20] [--passphrase] record
+func runRotate(c repo.Client, args []string) error {
+	fs := flag.NewFlagSet("rotate", flag.ContinueOnError)
+	length := fs.Int("length", logic.DefaultPasswordOptions.Length, "generated password length")
+	passphrase := fs.Bool("passphrase", false, "generate a passphrase instead of a password")
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		return errors.New("usage: rotate [--length n] [--passphrase] name|path|id")
+	}
+
+	authorize(&c)
+
+	existing, err := findRecord(c, fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	record := models.ParseRecord(existing.Data)
+	if record.Type != models.RecordLogin {
+		return models.ErrNotLoginRecord
+	}
+
+	var generated logic.Generated
+	if *passphrase {
+		generated, err = logic.GeneratePassphrase(logic.DefaultPassphraseOptions)
+	} else {
+		opts := logic.DefaultPasswordOptions
+		opts.Length = *length
+		generated, err = logic.GeneratePassword(opts)
+	}
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Rotating password for %s %s\n", record.Login.Login, record.Login.URL)
+	fmt.Printf("New password: %s (entropy ~%.0f bits)\n", generated.Value, generated.Entropy)
+
+	var answer string
+	err = scanValue("Change the password on the site, then type \"yes\" to save it (anything else cancels):", &answer)
+	if err != nil {
+		return err
+	}
+	if answer != "yes" {
+		fmt.Println("Rotation cancelled, the record is unchanged")
+		return nil
+	}
+
+	err = logic.RotatePassword(&record, generated.Value, time.Now())
+	if err != nil {
+		return err
+	}
+
+	req := models.UserData{ID: existing.ID, Comment: existing.Comment, Folder: existing.Folder}
+	err = logic.EncodeRecord(&req, record)
+	if err != nil {
+		return err
+	}
+
+	err = logic.ActionProcessing(req, c, c.ActionAddr(), http.MethodPatch, logic.Update)
+	if err != nil {
+		return err
+	}
+
+	fmt.Println("Password rotated, the previous one is kept in the record history")
+	return nil
+}
@@ -0,0 +1,57 @@
+package client
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	repo "github.com/azazel3ooo/keeper/internal/models/client_repo"
+	"github.com/azazel3ooo/keeper/internal/models/pb"
+)
+
+// runWatch держит локальную базу в актуальном состоянии, применяя изменения записей с других устройств по мере их
+// появления на сервере, и выводит каждое изменение. Работает только с transport: grpc.
+// Использование: watch
+func runWatch(c repo.Client, args []string) error {
+	fs := flag.NewFlagSet("watch", flag.ContinueOnError)
+
+	err := fs.Parse(args)
+	if err != nil {
+		return err
+	}
+	if fs.NArg() != 0 {
+		return errors.New("usage: watch")
+	}
+
+	authorize(&c)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	fmt.Println("Watching for changes, press Ctrl+C to stop")
+	return c.WatchChanges(ctx, printChange)
+}
+
+// printChange выводит изменение записей, полученное из потока
+func printChange(change *pb.Change) {
+	ids := change.GetIds()
+	if change.GetItem() != nil {
+		ids = []string{change.GetItem().GetId()}
+	}
+
+	line := fmt.Sprintf("%s %-6s %s", time.Now().Format(time.TimeOnly), change.GetKind(), strings.Join(ids, ", "))
+	if change.GetKind() == pb.Change_MOVE {
+		folder := change.GetFolder()
+		if folder == "" {
+			folder = "/"
+		}
+		line += " -> " + folder
+	}
+	fmt.Println(line)
+}
@@ -1,21 +1,62 @@
 package server
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"log"
+	"log/slog"
+	"os"
+	"os/signal"
 	"sync"
+	"syscall"
+	"time"
 
+	logic "github.com/azazel3ooo/keeper/internal/logic/server"
 	"github.com/azazel3ooo/keeper/internal/models"
 	repo "github.com/azazel3ooo/keeper/internal/models/server_repo"
 )
 
-// Start производит инициализацию сервера и запускает его на порту, указанном в конфигурационном файле
+// Start производит инициализацию сервера и запускает его на порту, указанном в конфигурационном файле.
+// Если в аргументах передана команда, выполняет ее вместо запуска сервера
 func Start() {
-	var cfg models.Config
-	err := cfg.Init("server_settings.yml")
+	var cfg models.ServerConfig
+	args, err := models.LoadConfig(&cfg, "server", "server_settings.yml", os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
 	if err != nil {
 		log.Fatal(err)
 	}
 
+	if len(args) > 0 {
+		err = runCommand(cfg, args[0], args[1:])
+		if err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	lg, err := repo.NewLogger(cfg.Log, os.Stderr)
+	if err != nil {
+		log.Fatal(err)
+	}
+	// сообщения сторонних пакетов через log тоже попадают в структурированный лог
+	slog.SetDefault(lg)
+
+	if cfg.JWT.Key == "" {
+		lg.Warn("jwt.key is not set, tokens are signed with a random key and will be invalid after restart")
+	}
+	logic.SetTokenSettings(cfg.JWT.Key, cfg.JWT.TokenTTL)
+
+	// блокировка не дает восстановить снимок поверх базы работающего сервера
+	lock, err := repo.LockDatabase(cfg.DbLocation)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer lock.Close()
+
 	var storage repo.ServerStorage
 	err = storage.Init(cfg.DbLocation)
 	if err != nil {
@@ -26,16 +67,97 @@ func Start() {
 	s := repo.NewServer(
 		repo.WithConfig(cfg),
 		repo.WithProcessingChan(processingChan),
-		repo.WithStorage(&storage))
+		repo.WithStorage(&storage),
+		repo.WithLogger(lg))
 
 	s.SetupApp()
+	if cfg.GRPCAddr != "" {
+		err = s.SetupGRPC()
+		if err != nil {
+			log.Fatal(err)
+		}
+	}
 
 	var watcherWG sync.WaitGroup
 	watcherWG.Add(1)
 	go s.ProcessingWatcher(&watcherWG)
 
-	log.Println(s.Listen())
+	stopBackups := make(chan struct{})
+	if cfg.Backup.Interval > 0 {
+		watcherWG.Add(1)
+		go storage.BackupWatcher(cfg.Backup, lg.With("component", "backup"), stopBackups, &watcherWG)
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	defer stop()
+
+	listenErr := make(chan error, 2)
+	go func() { listenErr <- s.Listen() }()
+	if cfg.GRPCAddr != "" {
+		go func() { listenErr <- s.ListenGRPC() }()
+	}
+	lg.Info("server started", "addr", cfg.HostAddr, "grpc_addr", cfg.GRPCAddr, "tls", cfg.TLS.Enabled())
+
+	select {
+	case <-ctx.Done():
+		lg.Info("shutdown signal received", "timeout", cfg.ShutdownTimeout)
+	case err = <-listenErr:
+		lg.Error("server stopped", "err", err)
+	}
+	stop()
+
+	close(stopBackups)
+	err = s.GracefulShutdown(cfg.ShutdownTimeout, &watcherWG)
+	if err != nil {
+		lg.Error("graceful shutdown failed", "err", err)
+		storage.Close()
+		os.Exit(1)
+	}
+
+	err = storage.Close()
+	if err != nil {
+		lg.Error("can't close storage", "err", err)
+	}
+	lg.Info("server stopped")
+}
+
+// runCommand выполняет служебную команду сервера
+func runCommand(cfg models.ServerConfig, command string, args []string) error {
+	switch command {
+	case "backup":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: backup file")
+		}
+
+		var storage repo.ServerStorage
+		err := storage.Init(cfg.DbLocation)
+		if err != nil {
+			return err
+		}
+		defer storage.Close()
+
+		sum, err := storage.Backup(args[0])
+		if err != nil {
+			return err
+		}
+		fmt.Printf("backup %s created, sha256 %s\n", args[0], sum)
+		return nil
+
+	case "restore":
+		if len(args) != 1 {
+			return fmt.Errorf("usage: restore file")
+		}
+
+		saved, err := repo.RestoreBackup(args[0], cfg.DbLocation, time.Now())
+		if err != nil {
+			return err
+		}
+		fmt.Printf("database %s restored from %s\n", cfg.DbLocation, args[0])
+		if saved != "" {
+			fmt.Printf("previous database saved to %s\n", saved)
+		}
+		return nil
+	}
 
-	close(processingChan)
-	watcherWG.Wait()
+	return fmt.Errorf("unknown command %q, available commands: backup, restore", command)
 }
@@ -0,0 +1,251 @@
+package client_logic
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"golang.org/x/crypto/argon2"
+)
+
+// Параметры формата зашифрованного архива keeper
+const (
+	ArchiveFormat  = "keeper-backup"
+	ArchiveVersion = 1
+	ArchiveKDF     = "argon2id"
+	ArchiveCipher  = "aes-256-gcm"
+
+	archiveKeySize  = 32
+	archiveSaltSize = 16
+
+	// пределы параметров argon2id из заголовка архива, чтобы чужой архив не мог занять всю память или время
+	maxKDFMemory  = 1024 * 1024 // KiB, 1 GiB
+	maxKDFTime    = 10
+	maxKDFThreads = 16
+)
+
+// DefaultKDFParams параметры argon2id для новых архивов (64 MiB памяти, 3 прохода)
+var DefaultKDFParams = KDFParams{Name: ArchiveKDF, Time: 3, Memory: 64 * 1024, Threads: 4}
+
+// Backup расшифрованное содержимое архива: все записи пользователя в формате хранения
+type Backup struct {
+	Version int               `json:"version"`
+	Created time.Time         `json:"created"`
+	Records []models.UserData `json:"records"`
+}
+
+// KDFParams параметры получения ключа шифрования из пароля архива. Memory задается в KiB
+type KDFParams struct {
+	Name    string `json:"name"`
+	Time    uint32 `json:"time"`
+	Memory  uint32 `json:"memory"`
+	Threads uint8  `json:"threads"`
+	Salt    []byte `json:"salt"`
+}
+
+// Archive зашифрованный архив. Data содержит Backup в json, зашифрованный AES-256-GCM ключом из KDF.
+// Все []byte поля сериализуются в base64
+type Archive struct {
+	Format  string    `json:"format"`
+	Version int       `json:"version"`
+	KDF     KDFParams `json:"kdf"`
+	Cipher  string    `json:"cipher"`
+	Nonce   []byte    `json:"nonce"`
+	Data    []byte    `json:"data"`
+}
+
+// NewBackup создает Backup из записей пользователя
+func NewBackup(records []models.UserData) Backup {
+	return Backup{Version: ArchiveVersion, Created: time.Now().UTC(), Records: records}
+}
+
+// EncryptBackup шифрует Backup паролем. Соль генерируется для каждого архива
+func EncryptBackup(b Backup, password string, params KDFParams) (Archive, error) {
+	plain, err := json.Marshal(b)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	return sealArchive(ArchiveFormat, plain, password, params)
+}
+
+// DecryptBackup расшифровывает архив. Неверный пароль и поврежденный архив не различаются
+func DecryptBackup(a Archive, password string) (Backup, error) {
+	plain, err := openArchive(a, ArchiveFormat, password)
+	if err != nil {
+		return Backup{}, err
+	}
+
+	var b Backup
+	err = json.Unmarshal(plain, &b)
+	return b, err
+}
+
+// WriteArchive шифрует записи паролем и записывает архив в w
+func WriteArchive(w io.Writer, records []models.UserData, password string, params KDFParams) error {
+	a, err := EncryptBackup(NewBackup(records), password, params)
+	if err != nil {
+		return err
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(a)
+}
+
+// ReadArchive читает и расшифровывает архив
+func ReadArchive(r io.Reader, password string) (Backup, error) {
+	var a Archive
+	err := json.NewDecoder(r).Decode(&a)
+	if err != nil {
+		return Backup{}, models.ErrUnsupportedArchive
+	}
+
+	return DecryptBackup(a, password)
+}
+
+// RestoreEntries преобразует записи архива для импорта. Записи получают новые id при сохранении,
+// поэтому архив можно восстановить в любой аккаунт. Папки записей восстанавливаются по их путям
+func RestoreEntries(b Backup) []ImportEntry {
+	entries := make([]ImportEntry, 0, len(b.Records))
+	for _, el := range b.Records {
+		entries = append(entries, ImportEntry{Title: el.Comment, Record: models.ParseRecord(el.Data),
+			Folder: FolderPath(b.Records, el.Folder)})
+	}
+
+	return entries
+}
+
+// ExportJSON записывает записи в открытом виде в json
+func ExportJSON(w io.Writer, records []models.UserData) error {
+	entries := make([]struct {
+		ID       string        `json:"id"`
+		Metadata string        `json:"metadata,omitempty"`
+		Record   models.Record `json:"record"`
+	}, len(records))
+	for i, el := range records {
+		entries[i].ID, entries[i].Metadata, entries[i].Record = el.ID, el.Comment, models.ParseRecord(el.Data)
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(entries)
+}
+
+// ExportCSV записывает записи в открытом виде в csv со столбцами, которые распознает ParseCSV.
+// Текст текстовых записей, данные карт, дополнительные поля и вложения записываются в столбец notes
+func ExportCSV(w io.Writer, records []models.UserData) error {
+	cw := csv.NewWriter(w)
+	err := cw.Write([]string{FieldTitle, FieldLogin, FieldPassword, FieldURL, FieldOTP, FieldNotes})
+	if err != nil {
+		return err
+	}
+
+	for _, el := range records {
+		r := models.ParseRecord(el.Data)
+		row := []string{el.Comment, "", "", "", "", r.Text}
+		if r.Login != nil {
+			row = []string{el.Comment, r.Login.Login, r.Login.Password, r.Login.URL, r.Login.OTP, r.Notes}
+		}
+		if r.Card != nil {
+			row[5] = RevealCard(*r.Card)
+			if r.Notes != "" {
+				row[5] += ", notes: " + r.Notes
+			}
+		}
+		if extras := formatExtras(r, true); extras != "" {
+			if row[5] == "" {
+				extras = strings.TrimPrefix(extras, ", ")
+			}
+			row[5] += extras
+		}
+
+		err = cw.Write(row)
+		if err != nil {
+			return err
+		}
+	}
+
+	cw.Flush()
+	return cw.Error()
+}
+
+// sealArchive шифрует plain паролем и возвращает архив формата format
+func sealArchive(format string, plain []byte, password string, params KDFParams) (Archive, error) {
+	params.Name = ArchiveKDF
+	params.Salt = make([]byte, archiveSaltSize)
+	_, err := rand.Read(params.Salt)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	aead, err := archiveCipher(password, params)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	_, err = rand.Read(nonce)
+	if err != nil {
+		return Archive{}, err
+	}
+
+	a := Archive{Format: format, Version: ArchiveVersion, KDF: params, Cipher: ArchiveCipher, Nonce: nonce}
+	a.Data = aead.Seal(nil, nonce, plain, a.additionalData())
+	return a, nil
+}
+
+// openArchive расшифровывает архив формата format
+func openArchive(a Archive, format, password string) ([]byte, error) {
+	if a.Format != format || a.Version != ArchiveVersion || a.Cipher != ArchiveCipher || a.KDF.Name != ArchiveKDF {
+		return nil, models.ErrUnsupportedArchive
+	}
+
+	aead, err := archiveCipher(password, a.KDF)
+	if err != nil {
+		return nil, err
+	}
+	if len(a.Nonce) != aead.NonceSize() {
+		return nil, models.ErrUnsupportedArchive
+	}
+
+	plain, err := aead.Open(nil, a.Nonce, a.Data, a.additionalData())
+	if err != nil {
+		return nil, models.ErrWrongArchivePassword
+	}
+
+	return plain, nil
+}
+
+func archiveCipher(password string, params KDFParams) (cipher.AEAD, error) {
+	if params.Time == 0 || params.Memory == 0 || params.Threads == 0 || len(params.Salt) == 0 {
+		return nil, models.ErrUnsupportedArchive
+	}
+	if params.Memory > maxKDFMemory || params.Time > maxKDFTime || params.Threads > maxKDFThreads {
+		return nil, fmt.Errorf("%w: memory %d KiB, time %d, threads %d, allowed at most %d KiB, %d, %d",
+			models.ErrKDFParamsTooHigh, params.Memory, params.Time, params.Threads, maxKDFMemory, maxKDFTime, maxKDFThreads)
+	}
+
+	key := argon2.IDKey([]byte(password), params.Salt, params.Time, params.Memory, params.Threads, archiveKeySize)
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// additionalData привязывает шифротекст к заголовку архива, чтобы параметры нельзя было подменить
+func (a Archive) additionalData() []byte {
+	header := a
+	header.Data = nil
+	b, _ := json.Marshal(header)
+	return b
+}
@@ -0,0 +1,106 @@
+package client_logic
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+// testKDFParams облегченные параметры argon2id, чтобы тесты выполнялись быстро
+var testKDFParams = KDFParams{Time: 1, Memory: 64, Threads: 1}
+
+func TestArchive(t *testing.T) {
+	loginData, _ := login("", "octocat", "pas", "https://github.com", "", "work").Record.Encode()
+	records := []models.UserData{
+		{ID: "1", Data: loginData, Comment: "GitHub"},
+		{ID: "2", Data: "legacy text"},
+	}
+
+	var buf bytes.Buffer
+	err := WriteArchive(&buf, records, "archive password", testKDFParams)
+	assert.Nil(t, err)
+	assert.Equalf(t, false, strings.Contains(buf.String(), "octocat"), "archive is encrypted")
+
+	tests := []struct {
+		description string
+		archive     string
+		password    string
+		wantErr     error
+	}{
+		{description: "success", archive: buf.String(), password: "archive password"},
+		{description: "wrong password", archive: buf.String(), password: "wrong", wantErr: models.ErrWrongArchivePassword},
+		{
+			description: "tampered parameters",
+			archive:     strings.Replace(buf.String(), `"time": 1`, `"time": 2`, 1),
+			password:    "archive password",
+			wantErr:     models.ErrWrongArchivePassword,
+		},
+		{description: "not an archive", archive: "[]", password: "archive password", wantErr: models.ErrUnsupportedArchive},
+		{
+			description: "memory above limit",
+			archive:     strings.Replace(buf.String(), `"memory": 64`, `"memory": 4194304`, 1),
+			password:    "archive password",
+			wantErr:     models.ErrKDFParamsTooHigh,
+		},
+		{
+			description: "time above limit",
+			archive:     strings.Replace(buf.String(), `"time": 1`, `"time": 4294967295`, 1),
+			password:    "archive password",
+			wantErr:     models.ErrKDFParamsTooHigh,
+		},
+		{
+			description: "threads above limit",
+			archive:     strings.Replace(buf.String(), `"threads": 1`, `"threads": 255`, 1),
+			password:    "archive password",
+			wantErr:     models.ErrKDFParamsTooHigh,
+		},
+	}
+	for _, tt := range tests {
+		b, err := ReadArchive(strings.NewReader(tt.archive), tt.password)
+		assert.Truef(t, errors.Is(err, tt.wantErr), "%s: %v", tt.description, err)
+		if tt.wantErr == nil {
+			assert.Equalf(t, records, b.Records, tt.description)
+		}
+	}
+}
+
+func TestRestoreEntries(t *testing.T) {
+	loginData, _ := login("", "octocat", "pas", "", "", "").Record.Encode()
+	b := NewBackup([]models.UserData{
+		{ID: "1", Data: loginData, Comment: "GitHub"},
+		{ID: "2", Data: "legacy text", Comment: "note"},
+	})
+
+	entries := RestoreEntries(b)
+	assert.Equal(t, []ImportEntry{
+		login("GitHub", "octocat", "pas", "", "", ""),
+		{Title: "note", Record: models.Record{Type: models.RecordText, Text: "legacy text"}},
+	}, entries)
+
+	fresh, duplicates := Deduplicate(entries, b.Records)
+	assert.Equalf(t, 0, len(fresh), "restore into the same account")
+	assert.Equalf(t, 2, len(duplicates), "restore into the same account")
+}
+
+func TestExportCSV(t *testing.T) {
+	loginData, _ := login("", "octocat", "p,as", "https://github.com", "", "work").Record.Encode()
+	records := []models.UserData{
+		{ID: "1", Data: loginData, Comment: "GitHub"},
+		{ID: "2", Data: "legacy text", Comment: "note"},
+	}
+
+	var buf bytes.Buffer
+	err := ExportCSV(&buf, records)
+	assert.Nil(t, err)
+	assert.Equal(t, "title,login,password,url,otp,notes\n"+
+		"GitHub,octocat,\"p,as\",https://github.com,,work\n"+
+		"note,,,,,legacy text\n", buf.String())
+
+	entries, _, err := ParseCSV(&buf, nil)
+	assert.Nil(t, err)
+	assert.Equalf(t, RestoreEntries(NewBackup(records)), entries, "csv export can be imported back")
+}
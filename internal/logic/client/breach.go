@@ -0,0 +1,163 @@
+package client_logic
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"errors"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+const (
+	breachHashLen = sha1.Size * 2
+	breachMaxLine = 128 // максимальная длина строки файла, "HASH:COUNT\r\n" заметно короче
+)
+
+// BreachList файл утекших паролей в формате Have I Been Pwned: строки "SHA1:COUNT", отсортированные по хэшу
+// (pwned-passwords-sha1-ordered-by-hash). Поиск выполняется двоичным поиском по файлу без загрузки его в память
+type BreachList struct {
+	r    io.ReaderAt
+	size int64
+	file *os.File
+}
+
+// OpenBreachList открывает локальный файл утекших паролей
+func OpenBreachList(path string) (*BreachList, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	list := NewBreachList(f, info.Size())
+	list.file = f
+	return list, nil
+}
+
+// NewBreachList возвращает BreachList для данных размера size
+func NewBreachList(r io.ReaderAt, size int64) *BreachList {
+	return &BreachList{r: r, size: size}
+}
+
+// Close закрывает файл списка, если он был открыт через OpenBreachList
+func (b *BreachList) Close() error {
+	if b.file == nil {
+		return nil
+	}
+	return b.file.Close()
+}
+
+// Count возвращает, сколько раз пароль встречался в утечках (0 - не найден)
+func (b *BreachList) Count(password string) (int, error) {
+	sum := sha1.Sum([]byte(password))
+	return b.lookup(strings.ToUpper(hex.EncodeToString(sum[:])))
+}
+
+// CheckBreaches проверяет пароли логинов по списку и возвращает число утечек для найденных записей по их id
+func CheckBreaches(data []models.UserData, list *BreachList) (map[string]int, error) {
+	res := make(map[string]int)
+	for _, el := range data {
+		r := models.ParseRecord(el.Data)
+		if r.Type != models.RecordLogin || r.Login.Password == "" {
+			continue
+		}
+
+		n, err := list.Count(r.Login.Password)
+		if err != nil {
+			return nil, err
+		}
+		if n > 0 {
+			res[el.ID] = n
+		}
+	}
+
+	return res, nil
+}
+
+// lookup ищет строку с хэшем. Инвариант: искомая строка, если она есть, начинается в [lo, hi)
+func (b *BreachList) lookup(hash string) (int, error) {
+	lo, hi := int64(0), b.size
+	for lo < hi {
+		mid := lo + (hi-lo)/2
+		start, err := b.lineStart(mid)
+		if err != nil {
+			return 0, err
+		}
+		if start >= hi {
+			hi = mid
+			continue
+		}
+
+		line, next, err := b.readLine(start)
+		if err != nil {
+			return 0, err
+		}
+		if len(line) < breachHashLen {
+			return 0, models.ErrInvalidBreachList
+		}
+
+		switch cmp := strings.Compare(strings.ToUpper(string(line[:breachHashLen])), hash); {
+		case cmp == 0:
+			return parseBreachCount(line)
+		case cmp < 0:
+			lo = next
+		default:
+			hi = mid
+		}
+	}
+
+	return 0, nil
+}
+
+// lineStart возвращает начало первой строки, начинающейся не раньше pos
+func (b *BreachList) lineStart(pos int64) (int64, error) {
+	if pos == 0 {
+		return 0, nil
+	}
+
+	_, next, err := b.readLine(pos - 1)
+	return next, err
+}
+
+// readLine читает строку с позиции off без перевода строки и возвращает позицию следующей строки. Строка длиннее
+// breachMaxLine означает, что файл не в формате HIBP
+func (b *BreachList) readLine(off int64) ([]byte, int64, error) {
+	buf := make([]byte, breachMaxLine)
+	n, err := b.r.ReadAt(buf, off)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return nil, 0, err
+	}
+
+	i := bytes.IndexByte(buf[:n], '\n')
+	if i >= 0 {
+		return bytes.TrimRight(buf[:i], "\r"), off + int64(i) + 1, nil
+	}
+	if off+int64(n) < b.size {
+		return nil, 0, models.ErrInvalidBreachList
+	}
+
+	return bytes.TrimRight(buf[:n], "\r"), b.size, nil
+}
+
+func parseBreachCount(line []byte) (int, error) {
+	_, count, ok := strings.Cut(string(line), ":")
+	if !ok {
+		return 1, nil
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(count))
+	if err != nil {
+		return 0, models.ErrInvalidBreachList
+	}
+	return n, nil
+}
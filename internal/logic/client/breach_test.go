@@ -0,0 +1,90 @@
+package client_logic
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func breachHash(password string) string {
+	sum := sha1.Sum([]byte(password))
+	return strings.ToUpper(hex.EncodeToString(sum[:]))
+}
+
+// breachFile собирает отсортированный список в формате HIBP из утекших паролей и случайных хэшей
+func breachFile(breached map[string]int) []byte {
+	var lines []string
+	for password, count := range breached {
+		lines = append(lines, fmt.Sprintf("%s:%d", breachHash(password), count))
+	}
+	for i := 0; i < 500; i++ {
+		lines = append(lines, fmt.Sprintf("%s:%d", breachHash(fmt.Sprintf("filler-%d", i)), i+1))
+	}
+	sort.Strings(lines)
+
+	return []byte(strings.Join(lines, "\r\n") + "\r\n")
+}
+
+func TestBreachList_Count(t *testing.T) {
+	breached := map[string]int{"password": 9545824, "123456": 37359195, "qwerty": 10556095}
+	data := breachFile(breached)
+	list := NewBreachList(bytes.NewReader(data), int64(len(data)))
+
+	for password, count := range breached {
+		got, err := list.Count(password)
+		assert.Nil(t, err)
+		assert.Equal(t, count, got, password)
+	}
+	for _, password := range []string{"filler-0", "filler-499"} {
+		got, err := list.Count(password)
+		assert.Nil(t, err)
+		assert.NotZero(t, got, password)
+	}
+
+	got, err := list.Count("k7#Qz!p2Lx@9Vw$e")
+	assert.Nil(t, err)
+	assert.Zero(t, got)
+
+	empty := NewBreachList(bytes.NewReader(nil), 0)
+	got, err = empty.Count("password")
+	assert.Nil(t, err)
+	assert.Zero(t, got)
+
+	// файл без переводов строк не читается в память целиком, а отклоняется
+	garbage := bytes.Repeat([]byte("A"), 1<<20)
+	invalid := NewBreachList(bytes.NewReader(garbage), int64(len(garbage)))
+	_, err = invalid.Count("password")
+	assert.ErrorIs(t, err, models.ErrInvalidBreachList)
+}
+
+func TestCheckBreaches(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "pwned.txt")
+	assert.Nil(t, os.WriteFile(path, breachFile(map[string]int{"qwerty": 42}), 0600))
+
+	list, err := OpenBreachList(path)
+	assert.Nil(t, err)
+	defer list.Close()
+
+	data := []models.UserData{
+		loginData(t, "breached", "qwerty", nil),
+		loginData(t, "safe", "k7#Qz!p2Lx@9Vw$e", nil),
+		{ID: "text", Data: "qwerty"},
+	}
+	breached, err := CheckBreaches(data, list)
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]int{"breached": 42}, breached)
+
+	report := CheckHealth(data, DefaultHealthOptions, time.Now())
+	report.MarkBreached(breached)
+	assert.Equal(t, 1, report.Breached)
+}
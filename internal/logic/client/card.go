@@ -0,0 +1,143 @@
+package client_logic
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+const (
+	minCardNumberLength = 12
+	maxCardNumberLength = 19
+)
+
+// cardBrands диапазоны префиксов номеров платежных систем
+var cardBrands = []struct {
+	brand    string
+	prefixes [][2]int // диапазоны префиксов включительно, префиксы одной длины
+}{
+	{brand: "amex", prefixes: [][2]int{{34, 34}, {37, 37}}},
+	{brand: "mir", prefixes: [][2]int{{2200, 2204}}},
+	{brand: "jcb", prefixes: [][2]int{{3528, 3589}}},
+	{brand: "mastercard", prefixes: [][2]int{{51, 55}, {2221, 2720}}},
+	{brand: "discover", prefixes: [][2]int{{6011, 6011}, {644, 649}, {65, 65}}},
+	{brand: "unionpay", prefixes: [][2]int{{62, 62}}},
+	{brand: "maestro", prefixes: [][2]int{{50, 50}, {56, 58}, {63, 63}, {67, 67}}},
+	{brand: "visa", prefixes: [][2]int{{4, 4}}},
+}
+
+// NormalizeCardNumber убирает из номера карты пробелы и дефисы
+func NormalizeCardNumber(number string) string {
+	return strings.NewReplacer(" ", "", "-", "").Replace(number)
+}
+
+// ValidLuhn проверяет контрольную цифру номера по алгоритму Луна
+func ValidLuhn(number string) bool {
+	if number == "" || !onlyDigits(number) {
+		return false
+	}
+
+	sum := 0
+	for i := 0; i < len(number); i++ {
+		d := int(number[len(number)-1-i] - '0')
+		if i%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+
+	return sum%10 == 0
+}
+
+// CardBrand определяет платежную систему по префиксу номера. Для неизвестных префиксов возвращает "unknown"
+func CardBrand(number string) string {
+	for _, el := range cardBrands {
+		for _, r := range el.prefixes {
+			width := len(strconv.Itoa(r[0]))
+			if len(number) < width {
+				continue
+			}
+
+			prefix, err := strconv.Atoi(number[:width])
+			if err == nil && prefix >= r[0] && prefix <= r[1] {
+				return el.brand
+			}
+		}
+	}
+
+	return "unknown"
+}
+
+// MaskPAN скрывает номер карты кроме последних 4 цифр: **** **** **** 1234
+func MaskPAN(number string) string {
+	last := number
+	if len(number) > 4 {
+		last = number[len(number)-4:]
+	}
+
+	return "**** **** **** " + last
+}
+
+// ValidateCard нормализует номер и срок действия карты и проверяет поля: номер по длине и алгоритму Луна,
+// срок в формате MM/YY (или MM/YYYY), CVV из 3 цифр (4 для amex) и PIN из 4-12 цифр. CVV и PIN необязательны
+func ValidateCard(c *models.CardRecord) error {
+	c.Number = NormalizeCardNumber(c.Number)
+	if len(c.Number) < minCardNumberLength || len(c.Number) > maxCardNumberLength || !ValidLuhn(c.Number) {
+		return models.ErrInvalidCardNumber
+	}
+
+	if len(c.Expiry) == len("01/2006") && strings.HasPrefix(c.Expiry[3:], "20") {
+		c.Expiry = c.Expiry[:3] + c.Expiry[5:]
+	}
+	_, err := c.ExpiryDate()
+	if err != nil {
+		return err
+	}
+
+	cvvLength := 3
+	if CardBrand(c.Number) == "amex" {
+		cvvLength = 4
+	}
+	if c.CVV != "" && (len(c.CVV) != cvvLength || !onlyDigits(c.CVV)) {
+		return models.ErrInvalidCVV
+	}
+
+	if c.PIN != "" && (len(c.PIN) < 4 || len(c.PIN) > 12 || !onlyDigits(c.PIN)) {
+		return models.ErrInvalidPIN
+	}
+
+	return nil
+}
+
+// CardExpired сообщает, истек ли срок действия карты. Карта с неверным сроком считается действующей
+func CardExpired(c models.CardRecord, now time.Time) bool {
+	expiry, err := c.ExpiryDate()
+	return err == nil && !now.Before(expiry)
+}
+
+// RevealCard возвращает все данные карты в открытом виде. Используется только по явному запросу пользователя
+func RevealCard(c models.CardRecord) string {
+	res := fmt.Sprintf("number: %s, holder: %s, expires: %s", c.Number, c.Holder, c.Expiry)
+	if c.CVV != "" {
+		res += ", cvv: " + c.CVV
+	}
+	if c.PIN != "" {
+		res += ", pin: " + c.PIN
+	}
+	return res
+}
+
+func onlyDigits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
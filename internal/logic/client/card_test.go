@@ -0,0 +1,94 @@
+package client_logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidLuhn(t *testing.T) {
+	tests := []struct {
+		number string
+		valid  bool
+	}{
+		{number: "4111111111111111", valid: true},
+		{number: "378282246310005", valid: true},
+		{number: "2200000000000004", valid: true},
+		{number: "4111111111111112", valid: false},
+		{number: "4111-1111", valid: false},
+		{number: "", valid: false},
+	}
+	for _, tt := range tests {
+		assert.Equal(t, tt.valid, ValidLuhn(tt.number), tt.number)
+	}
+}
+
+func TestCardBrand(t *testing.T) {
+	tests := map[string]string{
+		"4111111111111111": "visa",
+		"5555555555554444": "mastercard",
+		"2221000000000009": "mastercard",
+		"378282246310005":  "amex",
+		"6011111111111117": "discover",
+		"3530111333300000": "jcb",
+		"2200000000000004": "mir",
+		"6200000000000005": "unionpay",
+		"6759649826438453": "maestro",
+		"9999999999999995": "unknown",
+	}
+	for number, brand := range tests {
+		assert.Equal(t, brand, CardBrand(number), number)
+	}
+}
+
+func TestMaskPAN(t *testing.T) {
+	assert.Equal(t, "**** **** **** 1111", MaskPAN("4111111111111111"))
+	assert.Equal(t, "**** **** **** 0005", MaskPAN("378282246310005"))
+}
+
+func TestValidateCard(t *testing.T) {
+	tests := []struct {
+		name   string
+		card   models.CardRecord
+		number string
+		expiry string
+		err    error
+	}{
+		{name: "spaces", card: models.CardRecord{Number: "4111 1111 1111 1111", Expiry: "12/30", CVV: "123", PIN: "1234"},
+			number: "4111111111111111", expiry: "12/30"},
+		{name: "long year", card: models.CardRecord{Number: "4111-1111-1111-1111", Expiry: "01/2031"},
+			number: "4111111111111111", expiry: "01/31"},
+		{name: "amex cvv", card: models.CardRecord{Number: "378282246310005", Expiry: "05/29", CVV: "1234"},
+			number: "378282246310005", expiry: "05/29"},
+		{name: "luhn", card: models.CardRecord{Number: "4111111111111112", Expiry: "12/30"}, err: models.ErrInvalidCardNumber},
+		{name: "short", card: models.CardRecord{Number: "42", Expiry: "12/30"}, err: models.ErrInvalidCardNumber},
+		{name: "month", card: models.CardRecord{Number: "4111111111111111", Expiry: "13/30"}, err: models.ErrInvalidCardExpiry},
+		{name: "format", card: models.CardRecord{Number: "4111111111111111", Expiry: "1230"}, err: models.ErrInvalidCardExpiry},
+		{name: "cvv", card: models.CardRecord{Number: "4111111111111111", Expiry: "12/30", CVV: "1234"}, err: models.ErrInvalidCVV},
+		{name: "pin", card: models.CardRecord{Number: "4111111111111111", Expiry: "12/30", PIN: "12a4"}, err: models.ErrInvalidPIN},
+	}
+	for _, tt := range tests {
+		err := ValidateCard(&tt.card)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.name)
+			continue
+		}
+		assert.Nil(t, err, tt.name)
+		assert.Equal(t, tt.number, tt.card.Number, tt.name)
+		assert.Equal(t, tt.expiry, tt.card.Expiry, tt.name)
+	}
+}
+
+func TestCardExpired(t *testing.T) {
+	card := models.CardRecord{Number: "4111111111111111", Expiry: "06/24"}
+
+	assert.False(t, CardExpired(card, time.Date(2024, 6, 30, 23, 0, 0, 0, time.UTC)))
+	assert.True(t, CardExpired(card, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)))
+
+	r := models.Record{Type: models.RecordCard, Card: &card}
+	assert.Equal(t, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC), *r.DueDate())
+	assert.Contains(t, FormatRecord(r), "visa **** **** **** 1111, expires: 06/24 (EXPIRED)")
+	assert.NotContains(t, FormatRecord(r), "4111111111111111")
+}
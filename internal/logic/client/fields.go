@@ -0,0 +1,150 @@
+package client_logic
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// hiddenValue заменяет значения скрытых полей при выводе записи
+const hiddenValue = "******"
+
+// SetField проверяет поле и добавляет его в fields или заменяет поле с тем же именем.
+// Значение url должно быть абсолютным адресом, даты приводятся к виду YYYY-MM-DD
+func SetField(fields []models.CustomField, f models.CustomField) ([]models.CustomField, error) {
+	f.Name = strings.TrimSpace(f.Name)
+	if f.Name == "" || f.Value == "" {
+		return fields, fmt.Errorf("%w: name and value are required", models.ErrInvalidField)
+	}
+
+	switch f.Type {
+	case models.CustomFieldText, models.CustomFieldHidden:
+	case models.CustomFieldURL:
+		u, err := url.Parse(f.Value)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			return fields, fmt.Errorf("%w: %q is not an absolute url", models.ErrInvalidField, f.Value)
+		}
+	case models.CustomFieldDate:
+		date, err := time.Parse(dateLayout, f.Value)
+		if err != nil {
+			return fields, fmt.Errorf("%w: %q is not a date YYYY-MM-DD", models.ErrInvalidField, f.Value)
+		}
+		f.Value = date.Format(dateLayout)
+	default:
+		return fields, fmt.Errorf("%w: unknown type %q, expected text, hidden, url or date", models.ErrInvalidField, f.Type)
+	}
+
+	for i, el := range fields {
+		if el.Name == f.Name {
+			fields[i] = f
+			return fields, nil
+		}
+	}
+
+	return append(fields, f), nil
+}
+
+// RemoveField удаляет поле с переданным именем
+func RemoveField(fields []models.CustomField, name string) ([]models.CustomField, error) {
+	for i, el := range fields {
+		if el.Name == name {
+			return append(fields[:i:i], fields[i+1:]...), nil
+		}
+	}
+
+	return fields, models.ErrNotFound
+}
+
+// NewAttachment создает ссылку на вложение. Для http(s) адреса сохраняется только адрес, для локального файла -
+// абсолютный путь, размер и SHA256 содержимого
+func NewAttachment(ref string) (models.Attachment, error) {
+	u, err := url.Parse(ref)
+	if err == nil && (u.Scheme == "http" || u.Scheme == "https") && u.Host != "" {
+		name := path.Base(u.Path)
+		if name == "/" || name == "." {
+			name = u.Host
+		}
+		return models.Attachment{Name: name, Ref: ref}, nil
+	}
+
+	abs, err := filepath.Abs(ref)
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("%w: %s", models.ErrInvalidAttachment, err)
+	}
+	f, err := os.Open(abs)
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("%w: %s", models.ErrInvalidAttachment, err)
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("%w: %s", models.ErrInvalidAttachment, err)
+	}
+	if !info.Mode().IsRegular() {
+		return models.Attachment{}, fmt.Errorf("%w: %s is not a regular file", models.ErrInvalidAttachment, ref)
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return models.Attachment{}, fmt.Errorf("%w: %s", models.ErrInvalidAttachment, err)
+	}
+
+	return models.Attachment{Name: filepath.Base(abs), Ref: abs, Size: size, SHA256: hex.EncodeToString(h.Sum(nil))}, nil
+}
+
+// SetAttachment добавляет вложение в attachments или заменяет вложение с той же ссылкой
+func SetAttachment(attachments []models.Attachment, a models.Attachment) []models.Attachment {
+	for i, el := range attachments {
+		if el.Ref == a.Ref {
+			attachments[i] = a
+			return attachments
+		}
+	}
+
+	return append(attachments, a)
+}
+
+// RemoveAttachment удаляет вложение с переданным именем или ссылкой
+func RemoveAttachment(attachments []models.Attachment, name string) ([]models.Attachment, error) {
+	for i, el := range attachments {
+		if el.Name == name || el.Ref == name {
+			return append(attachments[:i:i], attachments[i+1:]...), nil
+		}
+	}
+
+	return attachments, models.ErrNotFound
+}
+
+// formatExtras возвращает дополнительные поля и вложения записи для вывода. Значения скрытых полей выводятся
+// только при reveal
+func formatExtras(r models.Record, reveal bool) string {
+	var res string
+	for _, el := range r.Fields {
+		value := el.Value
+		if el.Type == models.CustomFieldHidden && !reveal {
+			value = hiddenValue
+		}
+		res += fmt.Sprintf(", %s: %s", el.Name, value)
+	}
+
+	if len(r.Attachments) > 0 {
+		names := make([]string, 0, len(r.Attachments))
+		for _, el := range r.Attachments {
+			names = append(names, el.Name)
+		}
+		res += ", attachments: " + strings.Join(names, ", ")
+	}
+
+	return res
+}
@@ -0,0 +1,109 @@
+package client_logic
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetField(t *testing.T) {
+	tests := []struct {
+		name  string
+		field models.CustomField
+		value string
+		err   error
+	}{
+		{name: "text", field: models.CustomField{Name: " question ", Type: models.CustomFieldText, Value: "first pet"}, value: "first pet"},
+		{name: "hidden", field: models.CustomField{Name: "answer", Type: models.CustomFieldHidden, Value: "rex"}, value: "rex"},
+		{name: "url", field: models.CustomField{Name: "console", Type: models.CustomFieldURL, Value: "https://console.example.com"},
+			value: "https://console.example.com"},
+		{name: "date", field: models.CustomField{Name: "issued", Type: models.CustomFieldDate, Value: "2024-03-01"}, value: "2024-03-01"},
+		{name: "relative url", field: models.CustomField{Name: "console", Type: models.CustomFieldURL, Value: "example.com"},
+			err: models.ErrInvalidField},
+		{name: "bad date", field: models.CustomField{Name: "issued", Type: models.CustomFieldDate, Value: "01.03.2024"},
+			err: models.ErrInvalidField},
+		{name: "unknown type", field: models.CustomField{Name: "x", Type: "number", Value: "1"}, err: models.ErrInvalidField},
+		{name: "empty value", field: models.CustomField{Name: "x", Type: models.CustomFieldText}, err: models.ErrInvalidField},
+	}
+	for _, tt := range tests {
+		fields, err := SetField(nil, tt.field)
+		if tt.err != nil {
+			assert.ErrorIs(t, err, tt.err, tt.name)
+			assert.Empty(t, fields, tt.name)
+			continue
+		}
+		assert.Nil(t, err, tt.name)
+		assert.Len(t, fields, 1, tt.name)
+		assert.Equal(t, tt.value, fields[0].Value, tt.name)
+	}
+}
+
+func TestSetField_replaceAndRemove(t *testing.T) {
+	fields, err := SetField(nil, models.CustomField{Name: "key id", Type: models.CustomFieldText, Value: "AKIA1"})
+	assert.Nil(t, err)
+	fields, err = SetField(fields, models.CustomField{Name: "codes", Type: models.CustomFieldHidden, Value: "1111 2222"})
+	assert.Nil(t, err)
+	fields, err = SetField(fields, models.CustomField{Name: "key id", Type: models.CustomFieldText, Value: "AKIA2"})
+	assert.Nil(t, err)
+	assert.Equal(t, []models.CustomField{
+		{Name: "key id", Type: models.CustomFieldText, Value: "AKIA2"},
+		{Name: "codes", Type: models.CustomFieldHidden, Value: "1111 2222"},
+	}, fields)
+
+	rest, err := RemoveField(fields, "key id")
+	assert.Nil(t, err)
+	assert.Equal(t, []models.CustomField{{Name: "codes", Type: models.CustomFieldHidden, Value: "1111 2222"}}, rest)
+	assert.Len(t, fields, 2)
+
+	_, err = RemoveField(fields, "missing")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+}
+
+func TestNewAttachment(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "recovery.txt")
+	assert.Nil(t, os.WriteFile(file, []byte("hello"), 0600))
+
+	a, err := NewAttachment(file)
+	assert.Nil(t, err)
+	assert.Equal(t, models.Attachment{Name: "recovery.txt", Ref: file, Size: 5,
+		SHA256: "2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824"}, a)
+
+	a, err = NewAttachment("https://files.example.com/docs/passport.pdf")
+	assert.Nil(t, err)
+	assert.Equal(t, models.Attachment{Name: "passport.pdf", Ref: "https://files.example.com/docs/passport.pdf"}, a)
+
+	_, err = NewAttachment(dir)
+	assert.ErrorIs(t, err, models.ErrInvalidAttachment)
+	_, err = NewAttachment(filepath.Join(dir, "missing"))
+	assert.ErrorIs(t, err, models.ErrInvalidAttachment)
+
+	attachments := SetAttachment(nil, a)
+	attachments = SetAttachment(attachments, a)
+	assert.Len(t, attachments, 1)
+	attachments, err = RemoveAttachment(attachments, "passport.pdf")
+	assert.Nil(t, err)
+	assert.Empty(t, attachments)
+}
+
+func TestFormatRecord_extras(t *testing.T) {
+	r := models.Record{
+		Type: models.RecordText,
+		Text: "aws",
+		Fields: []models.CustomField{
+			{Name: "key id", Type: models.CustomFieldText, Value: "AKIA1"},
+			{Name: "secret", Type: models.CustomFieldHidden, Value: "s3cr3t"},
+		},
+		Attachments: []models.Attachment{{Name: "codes.txt", Ref: "/tmp/codes.txt"}},
+	}
+
+	assert.Equal(t, "aws, key id: AKIA1, secret: ******, attachments: codes.txt", FormatRecord(r))
+	assert.Equal(t, "aws, key id: AKIA1, secret: s3cr3t, attachments: codes.txt", RevealRecord(r))
+
+	data, err := r.Encode()
+	assert.Nil(t, err)
+	assert.Equal(t, r, models.ParseRecord(data))
+}
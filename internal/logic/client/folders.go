@@ -0,0 +1,253 @@
+package client_logic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// FolderSeparator разделитель имен папок в пути, например work/dev
+const FolderSeparator = "/"
+
+// NewFolder создает запись-папку name внутри папки parent (пустая строка - в корне).
+// Имена в одной папке не повторяются (см. ValidateName), поэтому путь однозначно определяет папку
+func NewFolder(data []models.UserData, name, parent string) (models.UserData, error) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.UserData{}, models.ErrInvalidName
+	}
+
+	if parent != "" {
+		_, err := findFolder(data, parent)
+		if err != nil {
+			return models.UserData{}, err
+		}
+	}
+	err := ValidateName(data, name, parent, "")
+	if err != nil {
+		return models.UserData{}, err
+	}
+
+	encoded, err := models.Record{Type: models.RecordFolder, Text: name}.Encode()
+	if err != nil {
+		return models.UserData{}, err
+	}
+
+	return models.UserData{ID: GenerateID(), Data: encoded, Folder: parent}, nil
+}
+
+// RenameFolder возвращает папку с переданным id под новым именем
+func RenameFolder(data []models.UserData, id, name string) (models.UserData, error) {
+	folder, err := findFolder(data, id)
+	if err != nil {
+		return models.UserData{}, err
+	}
+
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return models.UserData{}, models.ErrInvalidName
+	}
+	err = ValidateName(data, name, folder.Folder, folder.ID)
+	if err != nil {
+		return models.UserData{}, err
+	}
+
+	folder.Data, err = models.Record{Type: models.RecordFolder, Text: name}.Encode()
+	return folder, err
+}
+
+// ResolveFolder возвращает id папки по пути вида work/dev. Пустой путь и "/" соответствуют корню
+func ResolveFolder(data []models.UserData, path string) (string, error) {
+	var id string
+	for _, name := range strings.Split(strings.Trim(path, FolderSeparator), FolderSeparator) {
+		if name == "" {
+			continue
+		}
+
+		child, ok := childFolder(data, id, name)
+		if !ok {
+			return "", fmt.Errorf("%w: folder %s", models.ErrNotFound, path)
+		}
+		id = child
+	}
+
+	return id, nil
+}
+
+// EnsureFolder работает как ResolveFolder, но создает недостающие папки пути. Созданные папки возвращаются
+// в порядке создания (родители раньше вложенных), их нужно сохранить
+func EnsureFolder(data []models.UserData, path string) (string, []models.UserData, error) {
+	var (
+		id      string
+		created []models.UserData
+	)
+	for _, name := range strings.Split(strings.Trim(path, FolderSeparator), FolderSeparator) {
+		if name == "" {
+			continue
+		}
+
+		child, ok := childFolder(data, id, name)
+		if !ok {
+			folder, err := NewFolder(data, name, id)
+			if err != nil {
+				return "", nil, err
+			}
+			data = append(data, folder)
+			created = append(created, folder)
+			child = folder.ID
+		}
+		id = child
+	}
+
+	return id, created, nil
+}
+
+// FolderPath возвращает путь папки с переданным id. Для пустого id возвращает пустую строку
+func FolderPath(data []models.UserData, id string) string {
+	var names []string
+	// глубина ограничена числом записей, чтобы испорченные связи не зациклили поиск
+	for i := 0; id != "" && i <= len(data); i++ {
+		folder, err := findFolder(data, id)
+		if err != nil {
+			break
+		}
+		names = append([]string{models.ParseRecord(folder.Data).Text}, names...)
+		id = folder.Folder
+	}
+
+	return strings.Join(names, FolderSeparator)
+}
+
+// CheckMove проверяет перемещение до отправки на сервер: записи существуют, folder является папкой,
+// имена перемещаемых записей в ней свободны и папка не переносится в саму себя или во вложенную в нее папку
+func CheckMove(data []models.UserData, req models.MoveRequest) error {
+	if len(req.IDs) == 0 {
+		return models.ErrNotFound
+	}
+	names := make(map[string]bool, len(req.IDs))
+	for _, id := range req.IDs {
+		el, err := FindRecord(data, id)
+		if err != nil {
+			return fmt.Errorf("%w: %s", err, id)
+		}
+
+		name := RecordName(el)
+		if el.Folder == req.Folder || name == "" {
+			continue
+		}
+		err = ValidateName(data, name, req.Folder, id)
+		if err == nil && names[name] {
+			err = fmt.Errorf("%w: %s", models.ErrNameExists, name)
+		}
+		if err != nil {
+			return err
+		}
+		names[name] = true
+	}
+	if req.Folder == "" {
+		return nil
+	}
+
+	folder, err := findFolder(data, req.Folder)
+	if err != nil {
+		return err
+	}
+	for i := 0; i <= len(data); i++ {
+		for _, id := range req.IDs {
+			if id == folder.ID {
+				return models.ErrFolderCycle
+			}
+		}
+		if folder.Folder == "" {
+			break
+		}
+		folder, err = findFolder(data, folder.Folder)
+		if err != nil {
+			break
+		}
+	}
+
+	return nil
+}
+
+// PrintTree печатает папки и записи деревом: папки в виде "name/ [id]", записи в виде "name [id] | metadata (type)"
+// или "id | metadata (type)" для записей без имени. Содержимое записей не печатается
+func PrintTree(data []models.UserData) {
+	children := make(map[string][]models.UserData)
+	ids := make(map[string]bool, len(data))
+	for _, el := range data {
+		ids[el.ID] = true
+	}
+	for _, el := range data {
+		parent := el.Folder
+		if !ids[parent] {
+			// запись из папки, которой нет в локальных данных, показывается в корне
+			parent = ""
+		}
+		children[parent] = append(children[parent], el)
+	}
+
+	var walk func(parent string, depth int)
+	walk = func(parent string, depth int) {
+		items := children[parent]
+		sort.SliceStable(items, func(i, j int) bool {
+			ri, rj := models.ParseRecord(items[i].Data), models.ParseRecord(items[j].Data)
+			if (ri.Type == models.RecordFolder) != (rj.Type == models.RecordFolder) {
+				return ri.Type == models.RecordFolder
+			}
+			if ni, nj := RecordName(items[i]), RecordName(items[j]); ni != nj {
+				return ni < nj
+			}
+			return items[i].Comment < items[j].Comment
+		})
+
+		indent := strings.Repeat("  ", depth)
+		for _, el := range items {
+			r := models.ParseRecord(el.Data)
+			if r.Type == models.RecordFolder {
+				fmt.Printf("%s%s%s [%s]\n", indent, r.Text, FolderSeparator, el.ID)
+				if depth < len(data) {
+					walk(el.ID, depth+1)
+				}
+				continue
+			}
+			label := el.ID
+			if r.Name != "" {
+				label = fmt.Sprintf("%s [%s]", r.Name, el.ID)
+			}
+			fmt.Printf("%s%s | %s (%s)\n", indent, label, el.Comment, r.Type)
+		}
+	}
+	walk("", 0)
+	fmt.Println()
+}
+
+// findFolder ищет запись-папку с переданным id
+func findFolder(data []models.UserData, id string) (models.UserData, error) {
+	folder, err := FindRecord(data, id)
+	if err != nil {
+		return models.UserData{}, err
+	}
+	if models.ParseRecord(folder.Data).Type != models.RecordFolder {
+		return models.UserData{}, models.ErrNotFolder
+	}
+
+	return folder, nil
+}
+
+// childFolder ищет папку name непосредственно внутри папки parent
+func childFolder(data []models.UserData, parent, name string) (string, bool) {
+	for _, el := range data {
+		if el.Folder != parent {
+			continue
+		}
+		r := models.ParseRecord(el.Data)
+		if r.Type == models.RecordFolder && r.Text == name {
+			return el.ID, true
+		}
+	}
+
+	return "", false
+}
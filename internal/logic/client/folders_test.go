@@ -0,0 +1,115 @@
+package client_logic
+
+import (
+	"testing"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func folder(id, name, parent string) models.UserData {
+	data, _ := models.Record{Type: models.RecordFolder, Text: name}.Encode()
+	return models.UserData{ID: id, Data: data, Folder: parent}
+}
+
+func TestFolders(t *testing.T) {
+	data := []models.UserData{
+		folder("work", "work", ""),
+		folder("dev", "dev", "work"),
+		folder("home-dev", "dev", "home"),
+		folder("home", "home", ""),
+		{ID: "github", Data: "token", Folder: "dev"},
+	}
+
+	id, err := ResolveFolder(data, "work/dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", id)
+	id, err = ResolveFolder(data, "/")
+	assert.Nil(t, err)
+	assert.Equal(t, "", id)
+	_, err = ResolveFolder(data, "work/ops")
+	assert.ErrorIs(t, err, models.ErrNotFound)
+
+	assert.Equal(t, "home/dev", FolderPath(data, "home-dev"))
+	assert.Equal(t, "", FolderPath(data, ""))
+
+	_, err = NewFolder(data, "dev", "work")
+	assert.ErrorIs(t, err, models.ErrNameExists)
+	_, err = NewFolder(data, "a/b", "")
+	assert.ErrorIs(t, err, models.ErrInvalidName)
+	_, err = NewFolder(data, "ops", "github")
+	assert.ErrorIs(t, err, models.ErrNotFolder)
+
+	ops, err := NewFolder(data, " ops ", "work")
+	assert.Nil(t, err)
+	assert.Equal(t, "work", ops.Folder)
+	assert.Equal(t, models.Record{Type: models.RecordFolder, Text: "ops"}, models.ParseRecord(ops.Data))
+
+	renamed, err := RenameFolder(data, "home-dev", "projects")
+	assert.Nil(t, err)
+	assert.Equal(t, "home-dev", renamed.ID)
+	assert.Equal(t, "home", renamed.Folder)
+	assert.Equal(t, "projects", models.ParseRecord(renamed.Data).Text)
+
+	id, created, err := EnsureFolder(data, "work/ops/ci")
+	assert.Nil(t, err)
+	assert.Len(t, created, 2)
+	assert.Equal(t, "work", created[0].Folder)
+	assert.Equal(t, created[0].ID, created[1].Folder)
+	assert.Equal(t, created[1].ID, id)
+
+	id, created, err = EnsureFolder(data, "work/dev")
+	assert.Nil(t, err)
+	assert.Equal(t, "dev", id)
+	assert.Empty(t, created)
+}
+
+func TestCheckMove(t *testing.T) {
+	data := []models.UserData{
+		folder("work", "work", ""),
+		folder("dev", "dev", "work"),
+		{ID: "github", Data: "token", Folder: "dev"},
+	}
+
+	tests := []struct {
+		description string
+		req         models.MoveRequest
+		err         error
+	}{
+		{description: "to root", req: models.MoveRequest{IDs: []string{"dev", "github"}}},
+		{description: "to folder", req: models.MoveRequest{IDs: []string{"github"}, Folder: "work"}},
+		{description: "into itself", req: models.MoveRequest{IDs: []string{"work"}, Folder: "work"}, err: models.ErrFolderCycle},
+		{description: "into subfolder", req: models.MoveRequest{IDs: []string{"work"}, Folder: "dev"}, err: models.ErrFolderCycle},
+		{description: "into record", req: models.MoveRequest{IDs: []string{"dev"}, Folder: "github"}, err: models.ErrNotFolder},
+		{description: "unknown record", req: models.MoveRequest{IDs: []string{"gitlab"}}, err: models.ErrNotFound},
+	}
+	for _, tt := range tests {
+		err := CheckMove(data, tt.req)
+		if tt.err != nil {
+			assert.ErrorIsf(t, err, tt.err, tt.description)
+			continue
+		}
+		assert.Nilf(t, err, tt.description)
+	}
+}
+
+func TestRestoreEntries_folders(t *testing.T) {
+	backup := NewBackup([]models.UserData{
+		folder("work", "work", ""),
+		folder("dev", "dev", "work"),
+		{ID: "github", Data: "token", Comment: "github", Folder: "dev"},
+	})
+
+	entries := RestoreEntries(backup)
+	assert.Equal(t, []string{"", "work", "work/dev"}, []string{entries[0].Folder, entries[1].Folder, entries[2].Folder})
+
+	// папка с тем же именем в другом месте не считается дубликатом
+	existing := []models.UserData{folder("home", "home", ""), folder("home-dev", "dev", "home")}
+	fresh, duplicates := Deduplicate(entries, existing)
+	assert.Len(t, fresh, 3)
+	assert.Empty(t, duplicates)
+
+	fresh, duplicates = Deduplicate(entries, backup.Records)
+	assert.Empty(t, fresh)
+	assert.Len(t, duplicates, 3)
+}
@@ -0,0 +1,167 @@
+package client_logic
+
+import (
+	"crypto/rand"
+	_ "embed"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// Классы символов генератора паролей
+const (
+	LowerChars     = "abcdefghijklmnopqrstuvwxyz"
+	UpperChars     = "ABCDEFGHIJKLMNOPQRSTUVWXYZ"
+	DigitChars     = "0123456789"
+	SymbolChars    = "!#$%&*+-=?@^_~"
+	AmbiguousChars = "Il1O0o"
+)
+
+// wordlistData список слов EFF для diceware фраз (https://www.eff.org/dice), 7776 слов по одному в строке
+//
+//go:embed wordlist.txt
+var wordlistData string
+
+var wordlist = strings.Fields(wordlistData)
+
+// PasswordOptions параметры генерации пароля. Пароль содержит хотя бы один символ каждого включенного класса
+type PasswordOptions struct {
+	Length           int
+	Lower            bool
+	Upper            bool
+	Digits           bool
+	Symbols          bool
+	ExcludeAmbiguous bool // исключить похожие символы (I, l, 1, O, 0, o)
+}
+
+// PassphraseOptions параметры генерации фразы из случайных слов
+type PassphraseOptions struct {
+	Words      int
+	Separator  string
+	Capitalize bool
+}
+
+// Generated сгенерированный секрет и оценка его энтропии в битах
+type Generated struct {
+	Value   string
+	Entropy float64
+}
+
+var (
+	DefaultPasswordOptions   = PasswordOptions{Length: 20, Lower: true, Upper: true, Digits: true, Symbols: true}
+	DefaultPassphraseOptions = PassphraseOptions{Words: 6, Separator: "-"}
+)
+
+// GeneratePassword генерирует пароль из включенных классов символов. Энтропия оценивается как
+// Length * log2(размер алфавита), обязательные символы классов снижают ее незначительно
+func GeneratePassword(opts PasswordOptions) (Generated, error) {
+	var classes []string
+	for _, el := range []struct {
+		enabled bool
+		chars   string
+	}{
+		{opts.Lower, LowerChars},
+		{opts.Upper, UpperChars},
+		{opts.Digits, DigitChars},
+		{opts.Symbols, SymbolChars},
+	} {
+		if !el.enabled {
+			continue
+		}
+		if opts.ExcludeAmbiguous {
+			el.chars = removeChars(el.chars, AmbiguousChars)
+		}
+		classes = append(classes, el.chars)
+	}
+	if len(classes) == 0 {
+		return Generated{}, fmt.Errorf("%w: enable at least one character class", models.ErrInvalidGeneratorOptions)
+	}
+	if opts.Length < len(classes) {
+		return Generated{}, fmt.Errorf("%w: length must be at least %d", models.ErrInvalidGeneratorOptions, len(classes))
+	}
+
+	alphabet := strings.Join(classes, "")
+	res := make([]byte, 0, opts.Length)
+	for _, class := range classes {
+		c, err := randomChar(class)
+		if err != nil {
+			return Generated{}, err
+		}
+		res = append(res, c)
+	}
+	for len(res) < opts.Length {
+		c, err := randomChar(alphabet)
+		if err != nil {
+			return Generated{}, err
+		}
+		res = append(res, c)
+	}
+
+	// обязательные символы не должны всегда стоять в начале
+	for i := len(res) - 1; i > 0; i-- {
+		j, err := randomIndex(i + 1)
+		if err != nil {
+			return Generated{}, err
+		}
+		res[i], res[j] = res[j], res[i]
+	}
+
+	return Generated{
+		Value:   string(res),
+		Entropy: float64(opts.Length) * math.Log2(float64(len(alphabet))),
+	}, nil
+}
+
+// GeneratePassphrase генерирует фразу из случайных слов встроенного списка (diceware)
+func GeneratePassphrase(opts PassphraseOptions) (Generated, error) {
+	if opts.Words < 1 {
+		return Generated{}, fmt.Errorf("%w: at least one word is required", models.ErrInvalidGeneratorOptions)
+	}
+
+	words := make([]string, opts.Words)
+	for i := range words {
+		j, err := randomIndex(len(wordlist))
+		if err != nil {
+			return Generated{}, err
+		}
+
+		words[i] = wordlist[j]
+		if opts.Capitalize {
+			words[i] = strings.ToUpper(words[i][:1]) + words[i][1:]
+		}
+	}
+
+	return Generated{
+		Value:   strings.Join(words, opts.Separator),
+		Entropy: float64(opts.Words) * math.Log2(float64(len(wordlist))),
+	}, nil
+}
+
+func randomChar(chars string) (byte, error) {
+	i, err := randomIndex(len(chars))
+	if err != nil {
+		return 0, err
+	}
+	return chars[i], nil
+}
+
+// randomIndex возвращает равномерно распределенное случайное число из [0, n) на основе crypto/rand
+func randomIndex(n int) (int, error) {
+	i, err := rand.Int(rand.Reader, big.NewInt(int64(n)))
+	if err != nil {
+		return 0, err
+	}
+	return int(i.Int64()), nil
+}
+
+func removeChars(s, chars string) string {
+	return strings.Map(func(r rune) rune {
+		if strings.ContainsRune(chars, r) {
+			return -1
+		}
+		return r
+	}, s)
+}
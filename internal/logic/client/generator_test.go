@@ -0,0 +1,79 @@
+package client_logic
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeneratePassword(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    PasswordOptions
+		classes []string
+		absent  string
+		err     error
+	}{
+		{
+			name:    "default",
+			opts:    DefaultPasswordOptions,
+			classes: []string{LowerChars, UpperChars, DigitChars, SymbolChars},
+		},
+		{
+			name:    "digits only",
+			opts:    PasswordOptions{Length: 6, Digits: true},
+			classes: []string{DigitChars},
+			absent:  LowerChars + UpperChars + SymbolChars,
+		},
+		{
+			name:    "without ambiguous",
+			opts:    PasswordOptions{Length: 64, Lower: true, Upper: true, Digits: true, ExcludeAmbiguous: true},
+			classes: []string{LowerChars, UpperChars, DigitChars},
+			absent:  AmbiguousChars + SymbolChars,
+		},
+		{name: "no classes", opts: PasswordOptions{Length: 10}, err: models.ErrInvalidGeneratorOptions},
+		{name: "too short", opts: PasswordOptions{Length: 2, Lower: true, Upper: true, Digits: true}, err: models.ErrInvalidGeneratorOptions},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := GeneratePassword(tt.opts)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.Len(t, res.Value, tt.opts.Length)
+
+			for _, class := range tt.classes {
+				assert.Truef(t, strings.ContainsAny(res.Value, removeChars(class, tt.absent)), "%s has no chars of %s", res.Value, class)
+			}
+			if tt.absent != "" {
+				assert.Falsef(t, strings.ContainsAny(res.Value, tt.absent), "%s contains excluded chars", res.Value)
+			}
+		})
+	}
+
+	res, err := GeneratePassword(PasswordOptions{Length: 10, Digits: true})
+	assert.Nil(t, err)
+	assert.InDelta(t, 10*math.Log2(10), res.Entropy, 0.001)
+}
+
+func TestGeneratePassphrase(t *testing.T) {
+	assert.Len(t, wordlist, 7776)
+
+	res, err := GeneratePassphrase(PassphraseOptions{Words: 5, Separator: " ", Capitalize: true})
+	assert.Nil(t, err)
+
+	words := strings.Split(res.Value, " ")
+	assert.Len(t, words, 5)
+	for _, w := range words {
+		assert.Equal(t, strings.ToUpper(w[:1]), w[:1])
+	}
+	assert.InDelta(t, 5*math.Log2(7776), res.Entropy, 0.001)
+
+	_, err = GeneratePassphrase(PassphraseOptions{})
+	assert.ErrorIs(t, err, models.ErrInvalidGeneratorOptions)
+}
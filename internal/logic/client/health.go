@@ -0,0 +1,230 @@
+package client_logic
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// HealthOptions пороги отчета о паролях: пароль слабый при энтропии ниже MinEntropy и старый, если не менялся дольше MaxAge
+type HealthOptions struct {
+	MinEntropy float64
+	MaxAge     time.Duration
+}
+
+var DefaultHealthOptions = HealthOptions{MinEntropy: 60, MaxAge: 180 * 24 * time.Hour}
+
+// PasswordHealth результат проверки пароля одной записи. Сам пароль в отчет не попадает
+type PasswordHealth struct {
+	ID         string   `json:"id"`
+	Metadata   string   `json:"metadata,omitempty"`
+	Login      string   `json:"login"`
+	URL        string   `json:"url,omitempty"`
+	Entropy    float64  `json:"entropy"`
+	Weak       bool     `json:"weak"`
+	ReusedIn   []string `json:"reused_in,omitempty"` // id других записей с тем же паролем
+	AgeDays    int      `json:"age_days"`
+	AgeUnknown bool     `json:"age_unknown,omitempty"` // время смены пароля не записано
+	Old        bool     `json:"old"`
+	Breached   int      `json:"breached,omitempty"` // сколько раз пароль встречался в утечках
+}
+
+// HealthReport отчет о паролях хранилища
+type HealthReport struct {
+	Checked  int              `json:"checked"`
+	Weak     int              `json:"weak"`
+	Reused   int              `json:"reused"`
+	Old      int              `json:"old"`
+	Breached int              `json:"breached"`
+	Records  []PasswordHealth `json:"records"`
+}
+
+// Issues возвращает найденные проблемы пароля
+func (h PasswordHealth) Issues() []string {
+	var res []string
+	if h.Weak {
+		res = append(res, "weak")
+	}
+	if len(h.ReusedIn) > 0 {
+		res = append(res, "reused")
+	}
+	if h.Old {
+		res = append(res, "old")
+	}
+	if h.Breached > 0 {
+		res = append(res, "breached")
+	}
+	return res
+}
+
+// MarkBreached отмечает записи, пароли которых найдены в утечках (результат CheckBreaches)
+func (r *HealthReport) MarkBreached(breached map[string]int) {
+	for i, el := range r.Records {
+		if n := breached[el.ID]; n > 0 && el.Breached == 0 {
+			r.Records[i].Breached = n
+			r.Breached++
+		}
+	}
+}
+
+// CheckHealth проверяет пароли логинов: слабые по оценке энтропии, повторяющиеся в нескольких записях
+// и не менявшиеся дольше opts.MaxAge. Проверка выполняется локально
+func CheckHealth(data []models.UserData, opts HealthOptions, now time.Time) HealthReport {
+	report := HealthReport{Records: []PasswordHealth{}}
+
+	// пароли группируются по хэшу, чтобы не держать их в ключах лишней копией
+	byPassword := make(map[[sha256.Size]byte][]int)
+	for _, el := range data {
+		r := models.ParseRecord(el.Data)
+		if r.Type != models.RecordLogin || r.Login.Password == "" {
+			continue
+		}
+
+		h := PasswordHealth{
+			ID:       el.ID,
+			Metadata: el.Comment,
+			Login:    r.Login.Login,
+			URL:      r.Login.URL,
+			Entropy:  PasswordEntropy(r.Login.Password),
+		}
+		h.Weak = h.Entropy < opts.MinEntropy
+
+		if r.Login.PasswordChanged == nil {
+			h.AgeUnknown = true
+		} else {
+			age := now.Sub(*r.Login.PasswordChanged)
+			h.AgeDays = int(age / (24 * time.Hour))
+			h.Old = opts.MaxAge > 0 && age > opts.MaxAge
+		}
+
+		key := sha256.Sum256([]byte(r.Login.Password))
+		byPassword[key] = append(byPassword[key], len(report.Records))
+		report.Records = append(report.Records, h)
+	}
+
+	for _, group := range byPassword {
+		if len(group) < 2 {
+			continue
+		}
+		for _, i := range group {
+			for _, j := range group {
+				if i != j {
+					report.Records[i].ReusedIn = append(report.Records[i].ReusedIn, report.Records[j].ID)
+				}
+			}
+		}
+	}
+
+	for _, el := range report.Records {
+		report.Checked++
+		if el.Weak {
+			report.Weak++
+		}
+		if len(el.ReusedIn) > 0 {
+			report.Reused++
+		}
+		if el.Old {
+			report.Old++
+		}
+	}
+
+	// сначала записи с большим числом проблем, затем более слабые
+	sort.SliceStable(report.Records, func(i, j int) bool {
+		a, b := report.Records[i], report.Records[j]
+		if len(a.Issues()) != len(b.Issues()) {
+			return len(a.Issues()) > len(b.Issues())
+		}
+		return a.Entropy < b.Entropy
+	})
+
+	return report
+}
+
+// PasswordEntropy оценивает энтропию пароля в битах по размеру алфавита использованных классов символов.
+// Символ, повторяющий предыдущий или продолжающий последовательность (abc, 321), добавляет только 1 бит
+func PasswordEntropy(password string) float64 {
+	var lower, upper, digit, symbol, other bool
+	for _, r := range password {
+		switch {
+		case r >= 'a' && r <= 'z':
+			lower = true
+		case r >= 'A' && r <= 'Z':
+			upper = true
+		case r >= '0' && r <= '9':
+			digit = true
+		case r < unicode.MaxASCII && unicode.IsPrint(r):
+			symbol = true
+		default:
+			other = true
+		}
+	}
+
+	var pool int
+	for _, el := range []struct {
+		used bool
+		size int
+	}{{lower, 26}, {upper, 26}, {digit, 10}, {symbol, 33}, {other, 100}} {
+		if el.used {
+			pool += el.size
+		}
+	}
+	if pool == 0 {
+		return 0
+	}
+
+	perChar := math.Log2(float64(pool))
+	var res float64
+	prev := rune(-10)
+	for _, r := range password {
+		if d := r - prev; d >= -1 && d <= 1 {
+			res++
+		} else {
+			res += perChar
+		}
+		prev = r
+	}
+
+	return res
+}
+
+// PrintHealth печатает отчет в формате "id | metadata | login | entropy | age | issues\n" и итоговые числа
+func PrintHealth(report HealthReport) {
+	for _, el := range report.Records {
+		age := fmt.Sprintf("%dd", el.AgeDays)
+		if el.AgeUnknown {
+			age = "unknown"
+		}
+
+		issues := strings.Join(el.Issues(), ", ")
+		if len(el.ReusedIn) > 0 {
+			issues += fmt.Sprintf(" (with %s)", strings.Join(el.ReusedIn, ", "))
+		}
+		if issues == "" {
+			issues = "ok"
+		}
+
+		fmt.Printf("%s | %s | %s | %.0f bits | %s | %s\n", el.ID, el.Metadata, el.Login, el.Entropy, age, issues)
+	}
+	fmt.Printf("\nChecked: %d, weak: %d, reused: %d, old: %d, breached: %d\n", report.Checked, report.Weak, report.Reused,
+		report.Old, report.Breached)
+}
+
+// StampPassword отмечает время смены пароля логина. Если пароль совпадает с паролем prev, сохраняется прежнее время
+func StampPassword(r *models.Record, prev models.Record, now time.Time) {
+	if r.Login == nil {
+		return
+	}
+	if prev.Login != nil && prev.Login.Password == r.Login.Password && prev.Login.PasswordChanged != nil {
+		r.Login.PasswordChanged = prev.Login.PasswordChanged
+		return
+	}
+
+	now = now.UTC().Truncate(time.Second)
+	r.Login.PasswordChanged = &now
+}
@@ -0,0 +1,82 @@
+package client_logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func loginData(t *testing.T, id, password string, changed *time.Time) models.UserData {
+	data, err := models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{
+		Login:           id + "@example.com",
+		Password:        password,
+		PasswordChanged: changed,
+	}}.Encode()
+	assert.Nil(t, err)
+
+	return models.UserData{ID: id, Data: data}
+}
+
+func TestPasswordEntropy(t *testing.T) {
+	tests := []struct {
+		password string
+		min, max float64
+	}{
+		{password: "", min: 0, max: 0},
+		{password: "aaaaaaaaaaaa", min: 0, max: 20},
+		{password: "abcdef123456", min: 0, max: 25},
+		{password: "password", min: 30, max: 40},
+		{password: "k7#Qz!p2Lx@9Vw$e", min: 100, max: 110},
+	}
+	for _, tt := range tests {
+		got := PasswordEntropy(tt.password)
+		assert.Truef(t, got >= tt.min && got <= tt.max, "%q: %.1f bits", tt.password, got)
+	}
+}
+
+func TestCheckHealth(t *testing.T) {
+	now := time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	recent, old := now.Add(-24*time.Hour), now.Add(-365*24*time.Hour)
+
+	data := []models.UserData{
+		loginData(t, "weak", "qwerty", &recent),
+		loginData(t, "reused1", "k7#Qz!p2Lx@9Vw$e", &recent),
+		loginData(t, "reused2", "k7#Qz!p2Lx@9Vw$e", &recent),
+		loginData(t, "old", "Zp4!xR8#mQ2$wL6@", &old),
+		loginData(t, "unknown", "Hy7&uJ3*kD9(sF1)", nil),
+		{ID: "text", Data: "just a note"},
+	}
+
+	report := CheckHealth(data, DefaultHealthOptions, now)
+	assert.Equal(t, 5, report.Checked)
+	assert.Equal(t, 1, report.Weak)
+	assert.Equal(t, 2, report.Reused)
+	assert.Equal(t, 1, report.Old)
+
+	byID := make(map[string]PasswordHealth)
+	for _, el := range report.Records {
+		byID[el.ID] = el
+	}
+	assert.Equal(t, []string{"weak"}, byID["weak"].Issues())
+	assert.Equal(t, []string{"reused2"}, byID["reused1"].ReusedIn)
+	assert.Equal(t, []string{"reused1"}, byID["reused2"].ReusedIn)
+	assert.Equal(t, []string{"old"}, byID["old"].Issues())
+	assert.Equal(t, 365, byID["old"].AgeDays)
+	assert.True(t, byID["unknown"].AgeUnknown)
+	assert.Empty(t, byID["unknown"].Issues())
+}
+
+func TestStampPassword(t *testing.T) {
+	before, now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC)
+	prev := models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{Password: "old", PasswordChanged: &before}}
+
+	same := models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{Password: "old"}}
+	StampPassword(&same, prev, now)
+	assert.Equal(t, before, *same.Login.PasswordChanged)
+
+	changed := models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{Password: "new"}}
+	StampPassword(&changed, prev, now)
+	assert.Equal(t, now, *changed.Login.PasswordChanged)
+}
@@ -2,6 +2,7 @@ package client_logic
 
 import (
 	"fmt"
+	"time"
 
 	"github.com/azazel3ooo/keeper/internal/models"
 	"github.com/google/uuid"
@@ -12,10 +13,125 @@ func GenerateID() string {
 	return uuid.New().String()
 }
 
-// PrintData печатает переданные данные в формате "record_id | record_data with metadata: record_metadata\n"
-func PrintData(data []models.UserData) {
+// PrintData печатает переданные данные в формате "record_id (path) | record_data with metadata: record_metadata\n",
+// путь выводится только у записей с именем. Записи из breached (id -> число утечек) отмечаются предупреждением
+func PrintData(data []models.UserData, breached map[string]int) {
 	for _, el := range data {
-		fmt.Printf("%s | %s with metadata: %s\n", el.ID, el.Data, el.Comment)
+		id := el.ID
+		if path := RecordPath(data, el); path != "" {
+			id += " (" + path + ")"
+		}
+		warning := ""
+		if n := breached[el.ID]; n > 0 {
+			warning = fmt.Sprintf(" | WARNING: password found in breaches %d times", n)
+		}
+		fmt.Printf("%s | %s with metadata: %s%s\n", id, FormatRecord(models.ParseRecord(el.Data)), el.Comment, warning)
 	}
 	fmt.Println()
 }
+
+// PrintSessions печатает сессии в формате "session_id | device (os) | ip | last seen: time\n", отмечая текущую
+func PrintSessions(sessions []models.Session) {
+	for _, el := range sessions {
+		current := ""
+		if el.Current {
+			current = " (current)"
+		}
+		fmt.Printf("%s | %s (%s) | %s | last seen: %s%s\n", el.ID, el.Device, el.OS, el.IP,
+			el.LastSeen.Local().Format("2006-01-02 15:04:05"), current)
+	}
+	fmt.Println()
+}
+
+// PrintAudit печатает события журнала аудита в формате "time | event | device | ip | details\n"
+func PrintAudit(events []models.AuditEvent) {
+	for _, el := range events {
+		fmt.Printf("%s | %s | %s | %s | %s\n", el.Time.Local().Format("2006-01-02 15:04:05"), el.Event, el.Device,
+			el.IP, el.Details)
+	}
+	fmt.Println()
+}
+
+// PrintImport печатает записи импорта в формате "status | folder/title | type | login | url\n". Пароли не печатаются
+func PrintImport(fresh, duplicates []ImportEntry) {
+	printEntries := func(status string, entries []ImportEntry) {
+		for _, el := range entries {
+			var login, url string
+			if el.Record.Login != nil {
+				login, url = el.Record.Login.Login, el.Record.Login.URL
+			}
+			title := el.Title
+			if el.Folder != "" {
+				title = el.Folder + FolderSeparator + title
+			}
+			fmt.Printf("%s | %s | %s | %s | %s\n", status, title, el.Record.Type, login, url)
+		}
+	}
+
+	printEntries("new", fresh)
+	printEntries("duplicate", duplicates)
+	fmt.Println()
+}
+
+// FormatRecord возвращает строковое представление записи в зависимости от ее типа.
+// Номер карты маскируется, CVV, PIN и значения скрытых полей не выводятся, их показывает RevealRecord
+func FormatRecord(r models.Record) string {
+	return formatRecord(r, false) + formatExtras(r, false)
+}
+
+// RevealRecord работает как FormatRecord, но выводит данные карты и скрытые поля в открытом виде.
+// Используется только по явному запросу пользователя
+func RevealRecord(r models.Record) string {
+	return formatRecord(r, true) + formatExtras(r, true)
+}
+
+func formatRecord(r models.Record, reveal bool) string {
+	switch r.Type {
+	case models.RecordFolder:
+		return "folder: " + r.Text
+	case models.RecordCard:
+		res := fmt.Sprintf("card: %s %s, expires: %s", CardBrand(r.Card.Number), MaskPAN(r.Card.Number), r.Card.Expiry)
+		if reveal {
+			res = "card: " + RevealCard(*r.Card)
+		}
+		if CardExpired(*r.Card, time.Now()) {
+			res += " (EXPIRED)"
+		}
+		if r.Card.Holder != "" && !reveal {
+			res += ", holder: " + r.Card.Holder
+		}
+		if r.Notes != "" {
+			res += ", notes: " + r.Notes
+		}
+		return res
+
+	case models.RecordLogin:
+		res := fmt.Sprintf("login: %s, password: %s", r.Login.Login, r.Login.Password)
+		if r.Login.URL != "" {
+			res += ", url: " + r.Login.URL
+		}
+		if r.Login.OTP != "" {
+			res += ", one-time codes: on"
+		}
+		if due := r.DueDate(); due != nil {
+			res += ", change password by: " + due.Local().Format(dateLayout)
+		}
+		if r.Notes != "" {
+			res += ", notes: " + r.Notes
+		}
+		return res
+	}
+
+	return r.Text
+}
+
+// FindRecord ищет запись с переданным id
+func FindRecord(data []models.UserData, id string) (models.UserData, error) {
+	for _, el := range data {
+		if el.ID == id {
+			return el, nil
+		}
+	}
+
+	return models.UserData{}, models.ErrNotFound
+}
@@ -0,0 +1,378 @@
+package client_logic
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// Форматы, поддерживаемые ParseImport
+const (
+	FormatBitwarden = "bitwarden"
+	FormatKeePass   = "keepass"
+	Format1Password = "1password"
+	FormatCSV       = "csv"
+)
+
+// Поля записи keeper, на которые отображаются столбцы csv файла
+const (
+	FieldTitle    = "title"
+	FieldLogin    = "login"
+	FieldPassword = "password"
+	FieldURL      = "url"
+	FieldOTP      = "otp"
+	FieldNotes    = "notes"
+)
+
+// OnePasswordMapping соответствие полей keeper столбцам csv экспорта 1Password
+var OnePasswordMapping = map[string]string{
+	FieldTitle:    "Title",
+	FieldLogin:    "Username",
+	FieldPassword: "Password",
+	FieldURL:      "Url",
+	FieldOTP:      "OTPAuth",
+	FieldNotes:    "Notes",
+}
+
+// defaultColumns названия столбцов, которые распознаются в csv без явного указания соответствия
+var defaultColumns = map[string][]string{
+	FieldTitle:    {"title", "name"},
+	FieldLogin:    {"login", "username", "user", "login_username"},
+	FieldPassword: {"password", "login_password"},
+	FieldURL:      {"url", "uri", "website", "login_uri"},
+	FieldOTP:      {"otp", "totp", "otpauth", "login_totp"},
+	FieldNotes:    {"notes", "note", "comment", "extra"},
+}
+
+// ImportEntry запись, полученная из экспорта другого менеджера паролей. Title сохраняется в метаданные записи,
+// Folder - путь папки, в которую попадет запись (пустой для корня)
+type ImportEntry struct {
+	Title  string
+	Record models.Record
+	Folder string
+}
+
+// UserData преобразует запись в формат хранения keeper с новым id
+func (e ImportEntry) UserData() (models.UserData, error) {
+	d := models.UserData{ID: GenerateID(), Comment: e.Title}
+	err := EncodeRecord(&d, e.Record)
+	if err != nil {
+		return models.UserData{}, err
+	}
+
+	return d, nil
+}
+
+// ParseImport разбирает экспорт указанного формата. mapping используется только для csv
+// и задает соответствие полей keeper названиям столбцов. Возвращает записи и число пропущенных пустых записей
+func ParseImport(format string, r io.Reader, mapping map[string]string) ([]ImportEntry, int, error) {
+	switch format {
+	case FormatBitwarden:
+		return ParseBitwarden(r)
+	case FormatKeePass:
+		return ParseKeePass(r)
+	case Format1Password:
+		if len(mapping) == 0 {
+			mapping = OnePasswordMapping
+		}
+		return ParseCSV(r, mapping)
+	case FormatCSV:
+		return ParseCSV(r, mapping)
+	}
+
+	return nil, 0, models.ErrUnknownImportFormat
+}
+
+// ParseMapping разбирает соответствие столбцов вида "title=Name,login=User"
+func ParseMapping(s string) (map[string]string, error) {
+	mapping := make(map[string]string)
+	if s == "" {
+		return mapping, nil
+	}
+
+	for _, pair := range strings.Split(s, ",") {
+		field, column, ok := strings.Cut(pair, "=")
+		field = strings.ToLower(strings.TrimSpace(field))
+		if !ok || column == "" {
+			return nil, fmt.Errorf("%w: %q", models.ErrInvalidColumnMapping, pair)
+		}
+		if _, known := defaultColumns[field]; !known {
+			return nil, fmt.Errorf("%w: unknown field %q", models.ErrInvalidColumnMapping, field)
+		}
+		mapping[field] = strings.TrimSpace(column)
+	}
+
+	return mapping, nil
+}
+
+// newEntry собирает запись из общих для всех форматов полей. Запись без логина и пароля становится текстовой
+func newEntry(title, login, password, url, otp, notes string) (ImportEntry, bool) {
+	if login == "" && password == "" {
+		if notes == "" {
+			return ImportEntry{}, false
+		}
+		return ImportEntry{Title: title, Record: models.Record{Type: models.RecordText, Text: notes}}, true
+	}
+
+	return ImportEntry{
+		Title: title,
+		Record: models.Record{
+			Type:  models.RecordLogin,
+			Login: &models.LoginRecord{Login: login, Password: password, URL: url, OTP: otp},
+			Notes: notes,
+		},
+	}, true
+}
+
+type bitwardenExport struct {
+	Encrypted bool `json:"encrypted"`
+	Folders   []struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	} `json:"folders"`
+	Items []struct {
+		Name     string `json:"name"`
+		Notes    string `json:"notes"`
+		FolderID string `json:"folderId"`
+		Login    *struct {
+			Username string `json:"username"`
+			Password string `json:"password"`
+			TOTP     string `json:"totp"`
+			URIs     []struct {
+				URI string `json:"uri"`
+			} `json:"uris"`
+		} `json:"login"`
+	} `json:"items"`
+}
+
+// ParseBitwarden разбирает незашифрованный json экспорт Bitwarden. Записи попадают в папки с именами папок
+// Bitwarden, вложенность в которых, как и в keeper, задается разделителем / (например Work/Dev)
+func ParseBitwarden(r io.Reader) ([]ImportEntry, int, error) {
+	var export bitwardenExport
+	err := json.NewDecoder(r).Decode(&export)
+	if err != nil {
+		return nil, 0, err
+	}
+	if export.Encrypted {
+		return nil, 0, models.ErrEncryptedExport
+	}
+
+	folders := make(map[string]string, len(export.Folders))
+	for _, el := range export.Folders {
+		folders[el.ID] = cleanFolderPath(strings.Split(el.Name, FolderSeparator))
+	}
+
+	var (
+		entries []ImportEntry
+		skipped int
+	)
+	for _, item := range export.Items {
+		var login, password, url, otp string
+		if item.Login != nil {
+			login, password, otp = item.Login.Username, item.Login.Password, item.Login.TOTP
+			if len(item.Login.URIs) > 0 {
+				url = item.Login.URIs[0].URI
+			}
+		}
+
+		entry, ok := newEntry(item.Name, login, password, url, otp, item.Notes)
+		if !ok {
+			skipped++
+			continue
+		}
+		entry.Folder = folders[item.FolderID]
+		entries = append(entries, entry)
+	}
+
+	return entries, skipped, nil
+}
+
+type keepassGroup struct {
+	Name    string         `xml:"Name"`
+	Entries []keepassEntry `xml:"Entry"`
+	Groups  []keepassGroup `xml:"Group"`
+}
+
+type keepassEntry struct {
+	Strings []struct {
+		Key   string `xml:"Key"`
+		Value string `xml:"Value"`
+	} `xml:"String"`
+}
+
+// keepassRecycleBin группа удаленных записей KeePass, которая не импортируется
+const keepassRecycleBin = "Recycle Bin"
+
+// ParseKeePass разбирает xml экспорт KeePass 2.x (и KeePassXC). Группы внутри корневой группы базы становятся
+// папками, / в их именах заменяется на -. История записей и корзина не импортируются
+func ParseKeePass(r io.Reader) ([]ImportEntry, int, error) {
+	var file struct {
+		Root struct {
+			Groups []keepassGroup `xml:"Group"`
+		} `xml:"Root"`
+	}
+	err := xml.NewDecoder(r).Decode(&file)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	var (
+		entries []ImportEntry
+		skipped int
+		walk    func(g keepassGroup, path []string)
+	)
+	walk = func(g keepassGroup, path []string) {
+		if g.Name == keepassRecycleBin {
+			return
+		}
+
+		for _, e := range g.Entries {
+			values := make(map[string]string, len(e.Strings))
+			for _, s := range e.Strings {
+				values[s.Key] = s.Value
+			}
+
+			entry, ok := newEntry(values["Title"], values["UserName"], values["Password"], values["URL"],
+				values["otp"], values["Notes"])
+			if !ok {
+				skipped++
+				continue
+			}
+			entry.Folder = cleanFolderPath(path)
+			entries = append(entries, entry)
+		}
+
+		for _, child := range g.Groups {
+			walk(child, append(path[:len(path):len(path)], strings.ReplaceAll(child.Name, FolderSeparator, "-")))
+		}
+	}
+	// корневая группа - сама база, ее имя в путь не входит
+	for _, g := range file.Root.Groups {
+		walk(g, nil)
+	}
+
+	return entries, skipped, nil
+}
+
+// cleanFolderPath собирает путь папки из имен, убирая пробелы по краям имен и пустые имена
+func cleanFolderPath(names []string) string {
+	var res []string
+	for _, name := range names {
+		name = strings.TrimSpace(name)
+		if name != "" {
+			res = append(res, name)
+		}
+	}
+
+	return strings.Join(res, FolderSeparator)
+}
+
+// ParseCSV разбирает csv файл с заголовком. Поля, отсутствующие в mapping, ищутся по распространенным названиям столбцов
+func ParseCSV(r io.Reader, mapping map[string]string) ([]ImportEntry, int, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, 0, err
+	}
+
+	index := make(map[string]int, len(header))
+	for i, column := range header {
+		index[strings.ToLower(strings.TrimSpace(column))] = i
+	}
+
+	columns := make(map[string]int)
+	for field, names := range defaultColumns {
+		if column, ok := mapping[field]; ok {
+			i, found := index[strings.ToLower(column)]
+			if !found {
+				return nil, 0, fmt.Errorf("%w: no column %q in file", models.ErrInvalidColumnMapping, column)
+			}
+			columns[field] = i
+			continue
+		}
+
+		for _, name := range names {
+			if i, found := index[name]; found {
+				columns[field] = i
+				break
+			}
+		}
+	}
+
+	var (
+		entries []ImportEntry
+		skipped int
+	)
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, 0, err
+		}
+
+		value := func(field string) string {
+			i, ok := columns[field]
+			if !ok || i >= len(row) {
+				return ""
+			}
+			return strings.TrimSpace(row[i])
+		}
+
+		entry, ok := newEntry(value(FieldTitle), value(FieldLogin), value(FieldPassword), value(FieldURL),
+			value(FieldOTP), value(FieldNotes))
+		if !ok {
+			skipped++
+			continue
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, skipped, nil
+}
+
+// fingerprint возвращает ключ для поиска дубликатов. Название и заметки не учитываются, папка (путь folder)
+// учитывается только для самих папок: папки с одинаковым именем в разных местах разные
+func fingerprint(r models.Record, folder string) string {
+	if r.Type == models.RecordFolder {
+		return r.Type + "\x00" + folder + FolderSeparator + r.Text
+	}
+	if r.Type == models.RecordLogin && r.Login != nil {
+		return strings.Join([]string{r.Type, strings.ToLower(r.Login.Login), r.Login.Password,
+			strings.ToLower(strings.TrimRight(r.Login.URL, "/"))}, "\x00")
+	}
+	if r.Type == models.RecordCard && r.Card != nil {
+		return r.Type + "\x00" + r.Card.Number
+	}
+
+	return r.Type + "\x00" + r.Text
+}
+
+// Deduplicate отбрасывает записи, которые уже есть среди existing или повторяются в самом импорте.
+// Возвращает новые записи и дубликаты
+func Deduplicate(entries []ImportEntry, existing []models.UserData) (fresh []ImportEntry, duplicates []ImportEntry) {
+	seen := make(map[string]bool, len(existing)+len(entries))
+	for _, el := range existing {
+		seen[fingerprint(models.ParseRecord(el.Data), FolderPath(existing, el.Folder))] = true
+	}
+
+	for _, entry := range entries {
+		key := fingerprint(entry.Record, entry.Folder)
+		if seen[key] {
+			duplicates = append(duplicates, entry)
+			continue
+		}
+		seen[key] = true
+		fresh = append(fresh, entry)
+	}
+
+	return fresh, duplicates
+}
@@ -0,0 +1,157 @@
+package client_logic
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func login(title, user, password, url, otp, notes string) ImportEntry {
+	return ImportEntry{Title: title, Record: models.Record{
+		Type:  models.RecordLogin,
+		Login: &models.LoginRecord{Login: user, Password: password, URL: url, OTP: otp},
+		Notes: notes,
+	}}
+}
+
+func inFolder(e ImportEntry, folder string) ImportEntry {
+	e.Folder = folder
+	return e
+}
+
+func TestParseImport(t *testing.T) {
+	bitwarden := `{"encrypted":false,"folders":[{"id":"f1","name":"Work / Dev"},{"id":"f2","name":"Home"}],"items":[
+		{"type":1,"name":"GitHub","notes":"work","folderId":"f1","login":{"username":"octocat","password":"pas",
+			"totp":"JBSWY3DPEHPK3PXP","uris":[{"uri":"https://github.com"}]}},
+		{"type":2,"name":"Wifi","notes":"guest network","folderId":null},
+		{"type":2,"name":"Empty"}
+	]}`
+	keepass := `<?xml version="1.0" encoding="utf-8"?>
+<KeePassFile><Root><Group><Name>Root</Name>
+	<Entry>
+		<String><Key>Title</Key><Value>Mail</Value></String>
+		<String><Key>UserName</Key><Value>me@example.com</Value></String>
+		<String><Key>Password</Key><Value ProtectInMemory="True">secret</Value></String>
+		<String><Key>URL</Key><Value>https://mail.example.com</Value></String>
+		<History><Entry><String><Key>Password</Key><Value>old</Value></String></Entry></History>
+	</Entry>
+	<Group><Name>Servers</Name>
+		<Entry>
+			<String><Key>Title</Key><Value>db</Value></String>
+			<String><Key>UserName</Key><Value>root</Value></String>
+			<String><Key>Password</Key><Value>toor</Value></String>
+		</Entry>
+		<Group><Name>Web/Prod</Name>
+			<Entry>
+				<String><Key>Title</Key><Value>nginx</Value></String>
+				<String><Key>Password</Key><Value>n</Value></String>
+			</Entry>
+		</Group>
+	</Group>
+	<Group><Name>Recycle Bin</Name>
+		<Entry><String><Key>Title</Key><Value>deleted</Value></String><String><Key>Password</Key><Value>x</Value></String></Entry>
+	</Group>
+</Group></Root></KeePassFile>`
+	onePassword := "Title,Url,Username,Password,OTPAuth,Favorite,Archived,Tags,Notes\n" +
+		"Bank,https://bank.example.com,client,\"p,a\"\"s\",,false,false,,\n"
+	generic := "Site,User,Pass,Extra\nexample.com,admin,qwerty,note\n,,,\n"
+
+	tests := []struct {
+		description string
+		format      string
+		input       string
+		mapping     map[string]string
+		want        []ImportEntry
+		wantSkipped int
+		wantErr     error
+	}{
+		{
+			description: "bitwarden",
+			format:      FormatBitwarden,
+			input:       bitwarden,
+			want: []ImportEntry{
+				inFolder(login("GitHub", "octocat", "pas", "https://github.com", "JBSWY3DPEHPK3PXP", "work"), "Work/Dev"),
+				{Title: "Wifi", Record: models.Record{Type: models.RecordText, Text: "guest network"}},
+			},
+			wantSkipped: 1,
+		},
+		{
+			description: "encrypted bitwarden",
+			format:      FormatBitwarden,
+			input:       `{"encrypted":true,"items":[]}`,
+			wantErr:     models.ErrEncryptedExport,
+		},
+		{
+			description: "keepass",
+			format:      FormatKeePass,
+			input:       keepass,
+			want: []ImportEntry{
+				login("Mail", "me@example.com", "secret", "https://mail.example.com", "", ""),
+				inFolder(login("db", "root", "toor", "", "", ""), "Servers"),
+				inFolder(login("nginx", "", "n", "", "", ""), "Servers/Web-Prod"),
+			},
+		},
+		{
+			description: "1password",
+			format:      Format1Password,
+			input:       onePassword,
+			want:        []ImportEntry{login("Bank", "client", "p,a\"s", "https://bank.example.com", "", "")},
+		},
+		{
+			description: "csv with mapping",
+			format:      FormatCSV,
+			input:       generic,
+			mapping:     map[string]string{FieldTitle: "Site", FieldLogin: "User", FieldPassword: "Pass", FieldNotes: "Extra"},
+			want:        []ImportEntry{login("example.com", "admin", "qwerty", "", "", "note")},
+			wantSkipped: 1,
+		},
+		{
+			description: "csv with unknown column",
+			format:      FormatCSV,
+			input:       generic,
+			mapping:     map[string]string{FieldLogin: "Username"},
+			wantErr:     models.ErrInvalidColumnMapping,
+		},
+		{
+			description: "unknown format",
+			format:      "lastpass",
+			wantErr:     models.ErrUnknownImportFormat,
+		},
+	}
+	for _, tt := range tests {
+		entries, skipped, err := ParseImport(tt.format, strings.NewReader(tt.input), tt.mapping)
+		assert.Equalf(t, true, errors.Is(err, tt.wantErr), tt.description)
+		assert.Equalf(t, tt.want, entries, tt.description)
+		assert.Equalf(t, tt.wantSkipped, skipped, tt.description)
+	}
+}
+
+func TestParseMapping(t *testing.T) {
+	mapping, err := ParseMapping("title=Name, login=User")
+	assert.Nil(t, err)
+	assert.Equal(t, map[string]string{FieldTitle: "Name", FieldLogin: "User"}, mapping)
+
+	_, err = ParseMapping("color=Red")
+	assert.ErrorIs(t, err, models.ErrInvalidColumnMapping)
+
+	_, err = ParseMapping("title")
+	assert.ErrorIs(t, err, models.ErrInvalidColumnMapping)
+}
+
+func TestDeduplicate(t *testing.T) {
+	existing, _ := login("old title", "octocat", "pas", "https://github.com/", "", "").UserData()
+
+	entries := []ImportEntry{
+		login("GitHub", "Octocat", "pas", "https://github.com", "", ""),
+		login("Mail", "me", "secret", "", "", ""),
+		login("Mail copy", "me", "secret", "", "", ""),
+		login("Mail new password", "me", "changed", "", "", ""),
+	}
+
+	fresh, duplicates := Deduplicate(entries, []models.UserData{existing})
+	assert.Equal(t, []ImportEntry{entries[1], entries[3]}, fresh)
+	assert.Equal(t, []ImportEntry{entries[0], entries[2]}, duplicates)
+}
@@ -26,6 +26,10 @@ func Set(c client_repo.Client, r models.Validatable) error { return c.SetLocal(r
 // реализовано для передачи в ActionProcessing в качестве обертки над реальным действием
 func Update(c client_repo.Client, r models.Validatable) error { return c.UpdateLocal(r) }
 
+// Move выполняет MoveLocal для переданного client_repo.Client с аргументом models.Validatable
+// реализовано для передачи в ActionProcessing в качестве обертки над реальным действием
+func Move(c client_repo.Client, r models.Validatable) error { return c.MoveLocal(r) }
+
 // Delete выполняет DeleteLocal для переданного client_repo.Client с аргументом models.Validatable
 // реализовано для передачи в ActionProcessing в качестве обертки над реальным действием
 func Delete(c client_repo.Client, r models.Validatable) error { return c.DeleteLocal(r) }
@@ -0,0 +1,34 @@
+package client_logic
+
+import (
+	"time"
+
+	otp "github.com/azazel3ooo/keeper/internal/logic/otp"
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// OneTimeCode возвращает текущий одноразовый код для записи с OTP секретом и оставшееся время его действия
+func OneTimeCode(data models.UserData, t time.Time) (string, time.Duration, error) {
+	r := models.ParseRecord(data.Data)
+	if r.Type != models.RecordLogin || r.Login.OTP == "" {
+		return "", 0, models.ErrNoOTP
+	}
+
+	key, err := otp.ParseURI(r.Login.OTP)
+	if err != nil {
+		return "", 0, err
+	}
+
+	code, err := key.Code(t)
+	if err != nil {
+		return "", 0, err
+	}
+
+	return code, key.Remaining(t), nil
+}
+
+// ValidOTP проверяет, что из переданной строки можно генерировать одноразовые коды
+func ValidOTP(uri string) error {
+	_, err := otp.ParseURI(uri)
+	return err
+}
@@ -0,0 +1,53 @@
+package client_logic
+
+import (
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOneTimeCode(t *testing.T) {
+	encode := func(r models.Record) models.UserData {
+		data, _ := r.Encode()
+		return models.UserData{ID: "id", Data: data}
+	}
+
+	withOTP := encode(models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{
+		Login: "bot",
+		OTP:   "otpauth://totp/GitHub:bot?secret=GEZDGNBVGY3TQOJQGEZDGNBVGY3TQOJQ&issuer=GitHub",
+	}})
+	withoutOTP := encode(models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{Login: "bot", Password: "pas"}})
+
+	tests := []struct {
+		description   string
+		data          models.UserData
+		wantCode      string
+		wantRemaining time.Duration
+		wantErr       error
+	}{
+		{
+			description:   "login with otp",
+			data:          withOTP,
+			wantCode:      "287082", // RFC 6238, T = 59
+			wantRemaining: time.Second,
+		},
+		{
+			description: "login without otp",
+			data:        withoutOTP,
+			wantErr:     models.ErrNoOTP,
+		},
+		{
+			description: "legacy text record",
+			data:        models.UserData{ID: "id", Data: "plain text"},
+			wantErr:     models.ErrNoOTP,
+		},
+	}
+	for _, tt := range tests {
+		code, remaining, err := OneTimeCode(tt.data, time.Unix(59, 0))
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+		assert.Equalf(t, tt.wantCode, code, tt.description)
+		assert.Equalf(t, tt.wantRemaining, remaining, tt.description)
+	}
+}
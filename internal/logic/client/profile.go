@@ -0,0 +1,51 @@
+package client_logic
+
+import (
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"io"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// ProfileKeyFormat формат файла ключа локальной базы профиля
+const ProfileKeyFormat = "keeper-profile-key"
+
+const profileKeySize = 32
+
+// NewProfileKey создает случайный ключ локальной базы профиля и записывает его в w зашифрованным паролем профиля
+// так же, как архивы. Смена пароля профиля не требует перешифровывать базу
+func NewProfileKey(w io.Writer, password string, params KDFParams) ([]byte, error) {
+	key := make([]byte, profileKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := sealArchive(ProfileKeyFormat, key, password, params)
+	if err != nil {
+		return nil, err
+	}
+
+	return key, json.NewEncoder(w).Encode(a)
+}
+
+// ReadProfileKey читает и расшифровывает ключ локальной базы профиля
+func ReadProfileKey(r io.Reader, password string) ([]byte, error) {
+	var a Archive
+	err := json.NewDecoder(r).Decode(&a)
+	if err != nil {
+		return nil, models.ErrUnsupportedArchive
+	}
+
+	key, err := openArchive(a, ProfileKeyFormat, password)
+	if errors.Is(err, models.ErrWrongArchivePassword) {
+		return nil, models.ErrWrongProfilePassword
+	}
+	if err == nil && len(key) != profileKeySize {
+		return nil, models.ErrUnsupportedArchive
+	}
+
+	return key, err
+}
@@ -0,0 +1,41 @@
+package client_logic
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProfileKey(t *testing.T) {
+	var buf bytes.Buffer
+	key, err := NewProfileKey(&buf, "profile password", testKDFParams)
+	assert.Nil(t, err)
+	assert.Len(t, key, 32)
+	assert.Equalf(t, false, bytes.Contains(buf.Bytes(), key), "key is encrypted")
+
+	var archive bytes.Buffer
+	err = WriteArchive(&archive, nil, "profile password", testKDFParams)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		description string
+		file        string
+		password    string
+		wantErr     error
+	}{
+		{description: "success", file: buf.String(), password: "profile password"},
+		{description: "wrong password", file: buf.String(), password: "wrong", wantErr: models.ErrWrongProfilePassword},
+		{description: "backup instead of key", file: archive.String(), password: "profile password", wantErr: models.ErrUnsupportedArchive},
+		{description: "not a key", file: "{", password: "profile password", wantErr: models.ErrUnsupportedArchive},
+	}
+	for _, tt := range tests {
+		res, err := ReadProfileKey(strings.NewReader(tt.file), tt.password)
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+		if tt.wantErr == nil {
+			assert.Equalf(t, key, res, tt.description)
+		}
+	}
+}
@@ -0,0 +1,109 @@
+package client_logic
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// MinIDPrefix минимальная длина префикса id, по которому ищется запись
+const MinIDPrefix = 4
+
+// RecordName возвращает имя записи: для папок их имя, для остальных записей Record.Name
+func RecordName(el models.UserData) string {
+	r := models.ParseRecord(el.Data)
+	if r.Type == models.RecordFolder {
+		return r.Text
+	}
+
+	return r.Name
+}
+
+// RecordPath возвращает полный путь записи вида work/github. Для записей без имени возвращает пустую строку
+func RecordPath(data []models.UserData, el models.UserData) string {
+	name := RecordName(el)
+	if name == "" {
+		return ""
+	}
+
+	if folder := FolderPath(data, el.Folder); folder != "" {
+		return folder + FolderSeparator + name
+	}
+	return name
+}
+
+// ValidateName проверяет, что имя можно дать записи except в папке folder: оно не содержит разделитель пути
+// и не занято другой записью или папкой в этой папке. Пустое имя допустимо для всех записей, кроме папок
+func ValidateName(data []models.UserData, name, folder, except string) error {
+	if name == "" {
+		return nil
+	}
+	if strings.TrimSpace(name) != name || strings.Contains(name, FolderSeparator) {
+		return models.ErrInvalidName
+	}
+
+	for _, el := range data {
+		if el.ID != except && el.Folder == folder && RecordName(el) == name {
+			return fmt.Errorf("%w: %s", models.ErrNameExists, name)
+		}
+	}
+
+	return nil
+}
+
+// Resolve ищет записи по запросу пользователя. Проверяются по очереди: точное совпадение id, пути или имени,
+// префикс id (не короче MinIDPrefix) и нечеткое совпадение с путем (символы запроса встречаются в пути по порядку).
+// Возвращает совпадения первой сработавшей проверки, несколько записей означают неоднозначный запрос
+func Resolve(data []models.UserData, query string) ([]models.UserData, error) {
+	query = strings.TrimSpace(query)
+	if query == "" {
+		return nil, models.ErrNotFound
+	}
+	lower := strings.ToLower(strings.Trim(query, FolderSeparator))
+
+	paths := make([]string, len(data))
+	for i, el := range data {
+		paths[i] = strings.ToLower(RecordPath(data, el))
+	}
+
+	checks := []func(i int) bool{
+		func(i int) bool { return data[i].ID == query },
+		func(i int) bool { return paths[i] != "" && paths[i] == lower },
+		func(i int) bool { return paths[i] != "" && strings.ToLower(RecordName(data[i])) == lower },
+		func(i int) bool { return len(query) >= MinIDPrefix && strings.HasPrefix(data[i].ID, query) },
+		func(i int) bool { return paths[i] != "" && fuzzyMatch(paths[i], lower) },
+	}
+	for _, check := range checks {
+		var found []models.UserData
+		for i := range data {
+			if check(i) {
+				found = append(found, data[i])
+			}
+		}
+		if len(found) == 0 {
+			continue
+		}
+
+		sort.SliceStable(found, func(i, j int) bool {
+			return RecordPath(data, found[i]) < RecordPath(data, found[j])
+		})
+		return found, nil
+	}
+
+	return nil, fmt.Errorf("%w: %s", models.ErrNotFound, query)
+}
+
+// fuzzyMatch сообщает, встречаются ли символы query в s в том же порядке
+func fuzzyMatch(s, query string) bool {
+	for _, r := range query {
+		i := strings.IndexRune(s, r)
+		if i < 0 {
+			return false
+		}
+		s = s[i+len(string(r)):]
+	}
+
+	return true
+}
@@ -0,0 +1,80 @@
+package client_logic
+
+import (
+	"testing"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func named(id, name, folder string) models.UserData {
+	data, _ := models.Record{Type: models.RecordText, Text: "secret", Name: name}.Encode()
+	return models.UserData{ID: id, Data: data, Folder: folder}
+}
+
+func TestResolve(t *testing.T) {
+	data := []models.UserData{
+		folder("f-work", "work", ""),
+		folder("f-home", "home", ""),
+		named("3f2a9c1e-aaaa", "github", "f-work"),
+		named("3f2a9c1e-bbbb", "github", "f-home"),
+		named("7c01d2e4-cccc", "gitlab", "f-work"),
+		named("9e4b7a10-dddd", "", ""),
+	}
+
+	ids := func(found []models.UserData) []string {
+		var res []string
+		for _, el := range found {
+			res = append(res, el.ID)
+		}
+		return res
+	}
+
+	tests := []struct {
+		query string
+		want  []string
+		err   error
+	}{
+		{query: "9e4b7a10-dddd", want: []string{"9e4b7a10-dddd"}},
+		{query: "work/github", want: []string{"3f2a9c1e-aaaa"}},
+		{query: "/Work/GitHub/", want: []string{"3f2a9c1e-aaaa"}},
+		{query: "github", want: []string{"3f2a9c1e-bbbb", "3f2a9c1e-aaaa"}},
+		{query: "work", want: []string{"f-work"}},
+		{query: "7c01", want: []string{"7c01d2e4-cccc"}},
+		{query: "3f2a9c1e", want: []string{"3f2a9c1e-bbbb", "3f2a9c1e-aaaa"}},
+		{query: "wrkglab", want: []string{"7c01d2e4-cccc"}},
+		{query: "bitbucket", err: models.ErrNotFound},
+		{query: " ", err: models.ErrNotFound},
+	}
+	for _, tt := range tests {
+		found, err := Resolve(data, tt.query)
+		if tt.err != nil {
+			assert.ErrorIsf(t, err, tt.err, tt.query)
+			continue
+		}
+		assert.Nilf(t, err, tt.query)
+		assert.Equalf(t, tt.want, ids(found), tt.query)
+	}
+}
+
+func TestValidateName(t *testing.T) {
+	data := []models.UserData{
+		folder("f-work", "work", ""),
+		named("github", "github", "f-work"),
+	}
+
+	assert.Equal(t, "work/github", RecordPath(data, data[1]))
+	assert.Equal(t, "", RecordPath(data, named("x", "", "f-work")))
+
+	assert.Nil(t, ValidateName(data, "github", "", ""))
+	assert.Nil(t, ValidateName(data, "github", "f-work", "github"))
+	assert.Nil(t, ValidateName(data, "", "f-work", ""))
+	assert.ErrorIs(t, ValidateName(data, "github", "f-work", ""), models.ErrNameExists)
+	assert.ErrorIs(t, ValidateName(data, "work", "", ""), models.ErrNameExists)
+	assert.ErrorIs(t, ValidateName(data, "a/b", "", ""), models.ErrInvalidName)
+
+	// запись с тем же именем нельзя перенести в папку
+	data = append(data, named("other", "github", ""))
+	assert.ErrorIs(t, CheckMove(data, models.MoveRequest{IDs: []string{"other"}, Folder: "f-work"}), models.ErrNameExists)
+	assert.ErrorIs(t, CheckMove(data, models.MoveRequest{IDs: []string{"github"}}), models.ErrNameExists)
+}
@@ -0,0 +1,120 @@
+package client_logic
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// MaxPasswordHistory число прежних паролей, которые хранятся в записи
+const MaxPasswordHistory = 10
+
+const dateLayout = "2006-01-02"
+
+// SetExpiry задает срок смены пароля логина: число дней задает интервал смены, дата YYYY-MM-DD - конкретный срок.
+// Пустая строка убирает срок
+func SetExpiry(r *models.Record, value string, now time.Time) error {
+	if r.Login == nil {
+		return models.ErrNotLoginRecord
+	}
+	r.Login.ExpiresAt, r.Login.RotationDays = nil, 0
+	if value == "" {
+		return nil
+	}
+
+	days, err := strconv.Atoi(value)
+	if err == nil {
+		if days <= 0 {
+			return models.ErrInvalidExpiry
+		}
+		r.Login.RotationDays = days
+		return nil
+	}
+
+	date, err := time.ParseInLocation(dateLayout, value, time.Local)
+	if err != nil || date.Before(now.AddDate(0, 0, -1)) {
+		return models.ErrInvalidExpiry
+	}
+	date = date.UTC()
+	r.Login.ExpiresAt = &date
+	return nil
+}
+
+// RotatePassword заменяет пароль логина, сохраняя прежний в истории (не более MaxPasswordHistory паролей).
+// Срок, заданный датой, относится к прежнему паролю и снимается, интервал смены продолжает действовать
+func RotatePassword(r *models.Record, password string, now time.Time) error {
+	if r.Type != models.RecordLogin || r.Login == nil {
+		return models.ErrNotLoginRecord
+	}
+
+	if r.Login.Password != "" {
+		r.Login.History = append([]models.PasswordHistory{{Password: r.Login.Password, Replaced: now.UTC()}},
+			r.Login.History...)
+		if len(r.Login.History) > MaxPasswordHistory {
+			r.Login.History = r.Login.History[:MaxPasswordHistory]
+		}
+	}
+
+	r.Login.Password = password
+	r.Login.ExpiresAt = nil
+	StampPassword(r, models.Record{}, now)
+	return nil
+}
+
+// EncodeRecord записывает запись в d.Data. Срок смены пароля или окончания действия карты копируется в d.ExpiresAt,
+// который передается серверу открыто, чтобы он мог напоминать о записях, которые пора обновить
+func EncodeRecord(d *models.UserData, r models.Record) error {
+	data, err := r.Encode()
+	if err != nil {
+		return err
+	}
+
+	d.Data, d.ExpiresAt = data, r.DueDate()
+	return nil
+}
+
+// PrintDue печатает записи со сроком смены пароля или окончания действия карты в формате
+// "id | metadata | login | url | due: date (status)\n". Для карт вместо логина выводится маскированный номер
+func PrintDue(data []models.UserData, now time.Time) {
+	for _, el := range data {
+		r := models.ParseRecord(el.Data)
+		due := el.ExpiresAt
+		if d := r.DueDate(); d != nil {
+			due = d
+		}
+		if due == nil {
+			continue
+		}
+
+		var login, url string
+		if r.Login != nil {
+			login, url = r.Login.Login, r.Login.URL
+		}
+		if r.Card != nil {
+			login = MaskPAN(r.Card.Number)
+		}
+		fmt.Printf("%s | %s | %s | %s | due: %s (%s)\n", el.ID, el.Comment, login, url,
+			due.Local().Format(dateLayout), dueStatus(*due, now))
+	}
+	fmt.Println()
+}
+
+// dueStatus считает срок в календарных днях по местному времени, как он и выводится
+func dueStatus(due, now time.Time) string {
+	days := int(math.Round(localDay(due).Sub(localDay(now)).Hours() / 24))
+	switch {
+	case days < 0:
+		return fmt.Sprintf("overdue by %d days", -days)
+	case days == 0:
+		return "today"
+	}
+	return fmt.Sprintf("in %d days", days)
+}
+
+func localDay(t time.Time) time.Time {
+	y, m, d := t.Local().Date()
+	return time.Date(y, m, d, 0, 0, 0, 0, time.Local)
+}
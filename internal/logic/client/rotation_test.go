@@ -0,0 +1,115 @@
+package client_logic
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSetExpiry(t *testing.T) {
+	now := time.Date(2024, 6, 1, 12, 0, 0, 0, time.Local)
+
+	tests := []struct {
+		value   string
+		days    int
+		expires string
+		err     error
+	}{
+		{value: "", days: 0},
+		{value: "90", days: 90},
+		{value: "2024-12-31", expires: "2024-12-31"},
+		{value: "0", err: models.ErrInvalidExpiry},
+		{value: "2020-01-01", err: models.ErrInvalidExpiry},
+		{value: "next year", err: models.ErrInvalidExpiry},
+	}
+	for _, tt := range tests {
+		r := models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{Password: "p", RotationDays: 7}}
+		err := SetExpiry(&r, tt.value, now)
+		if tt.err != nil {
+			assert.ErrorIsf(t, err, tt.err, tt.value)
+			continue
+		}
+		assert.Nil(t, err)
+		assert.Equal(t, tt.days, r.Login.RotationDays, tt.value)
+
+		if tt.expires == "" {
+			assert.Nil(t, r.Login.ExpiresAt, tt.value)
+		} else {
+			assert.Equal(t, tt.expires, r.Login.ExpiresAt.Local().Format(dateLayout))
+		}
+	}
+}
+
+func TestRotatePassword(t *testing.T) {
+	start := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	expires := start.AddDate(0, 1, 0)
+	r := models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{
+		Password:        "password-0",
+		PasswordChanged: &start,
+		ExpiresAt:       &expires,
+	}}
+	assert.Equal(t, expires, *r.DueDate())
+
+	for i := 1; i <= MaxPasswordHistory+2; i++ {
+		err := RotatePassword(&r, fmt.Sprintf("password-%d", i), start.AddDate(0, 0, i))
+		assert.Nil(t, err)
+	}
+
+	assert.Equal(t, fmt.Sprintf("password-%d", MaxPasswordHistory+2), r.Login.Password)
+	assert.Len(t, r.Login.History, MaxPasswordHistory)
+	assert.Equal(t, fmt.Sprintf("password-%d", MaxPasswordHistory+1), r.Login.History[0].Password)
+	assert.Nil(t, r.DueDate(), "expiry date belongs to the replaced password")
+
+	// интервал смены отсчитывается от последней смены
+	r.Login.RotationDays = 30
+	assert.Equal(t, r.Login.PasswordChanged.AddDate(0, 0, 30), *r.DueDate())
+
+	err := RotatePassword(&models.Record{Type: models.RecordText, Text: "note"}, "new", start)
+	assert.ErrorIs(t, err, models.ErrNotLoginRecord)
+}
+
+func TestEncodeRecord(t *testing.T) {
+	expires := time.Date(2024, 2, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		description string
+		record      models.Record
+		expires     *time.Time
+	}{
+		{
+			description: "login with expiry",
+			record:      models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{Login: "octocat", ExpiresAt: &expires}},
+			expires:     &expires,
+		},
+		{description: "login without expiry", record: models.Record{Type: models.RecordLogin, Login: &models.LoginRecord{Login: "octocat"}}},
+		{description: "text", record: models.Record{Type: models.RecordText, Text: "note"}},
+	}
+	for _, tt := range tests {
+		// срок прежней версии записи не сохраняется
+		d := models.UserData{ID: "1", ExpiresAt: &time.Time{}}
+		err := EncodeRecord(&d, tt.record)
+		assert.Nilf(t, err, tt.description)
+		assert.Equalf(t, tt.expires, d.ExpiresAt, tt.description)
+		assert.Equalf(t, tt.record, models.ParseRecord(d.Data), tt.description)
+	}
+}
+
+func TestDueStatus(t *testing.T) {
+	now := time.Date(2024, 3, 10, 15, 0, 0, 0, time.Local)
+	tests := []struct {
+		due  time.Time
+		want string
+	}{
+		{due: time.Date(2024, 3, 10, 9, 0, 0, 0, time.Local), want: "today"},
+		{due: time.Date(2024, 3, 10, 23, 0, 0, 0, time.Local), want: "today"},
+		{due: time.Date(2024, 3, 11, 1, 0, 0, 0, time.Local), want: "in 1 days"},
+		{due: time.Date(2024, 3, 9, 23, 0, 0, 0, time.Local), want: "overdue by 1 days"},
+		{due: time.Date(2024, 3, 1, 0, 0, 0, 0, time.Local), want: "overdue by 9 days"},
+		{due: time.Date(2024, 4, 10, 0, 0, 0, 0, time.Local), want: "in 31 days"},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.want, dueStatus(tt.due, now), tt.due.String())
+	}
+}
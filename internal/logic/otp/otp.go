@@ -0,0 +1,258 @@
+package otp_logic
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base32"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	DefaultDigits    = 6
+	DefaultPeriod    = 30
+	DefaultAlgorithm = "SHA1"
+
+	// допустимая длина кода по RFC 4226, длинные коды не помещаются в 31 бит усеченного HMAC
+	minDigits = 6
+	maxDigits = 8
+
+	secretSize = 20 // рекомендуемая RFC 4226 длина секрета в байтах
+)
+
+var (
+	ErrInvalidSecret   = errors.New("invalid otp secret")
+	ErrUnsupportedType = errors.New("only totp keys are supported")
+)
+
+var algorithms = map[string]func() hash.Hash{
+	"SHA1":   sha1.New,
+	"SHA256": sha256.New,
+	"SHA512": sha512.New,
+}
+
+// Key содержит параметры генерации одноразовых кодов по RFC 6238
+type Key struct {
+	Secret    string
+	Issuer    string
+	Account   string
+	Algorithm string
+	Digits    int
+	Period    int
+}
+
+// NewKey создает Key со случайным секретом и параметрами по умолчанию
+func NewKey(issuer, account string) (Key, error) {
+	b := make([]byte, secretSize)
+	_, err := rand.Read(b)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return Key{
+		Secret:    base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b),
+		Issuer:    issuer,
+		Account:   account,
+		Algorithm: DefaultAlgorithm,
+		Digits:    DefaultDigits,
+		Period:    DefaultPeriod,
+	}, nil
+}
+
+// URI возвращает otpauth:// адрес для добавления ключа в приложение-аутентификатор
+func (k Key) URI() string {
+	label := k.Account
+	if k.Issuer != "" {
+		label = k.Issuer + ":" + k.Account
+	}
+
+	v := url.Values{}
+	v.Set("secret", k.Secret)
+	if k.Issuer != "" {
+		v.Set("issuer", k.Issuer)
+	}
+	v.Set("algorithm", k.algorithm())
+	v.Set("digits", fmt.Sprint(k.digits()))
+	v.Set("period", fmt.Sprint(k.period()))
+
+	u := url.URL{
+		Scheme:   "otpauth",
+		Host:     "totp",
+		Path:     "/" + label,
+		RawQuery: v.Encode(),
+	}
+	return u.String()
+}
+
+// Code возвращает одноразовый код для момента времени t
+func (k Key) Code(t time.Time) (string, error) {
+	if k.digits() < minDigits || k.digits() > maxDigits {
+		return "", ErrInvalidSecret
+	}
+
+	secret, err := decodeSecret(k.Secret)
+	if err != nil {
+		return "", err
+	}
+
+	newHash, ok := algorithms[k.algorithm()]
+	if !ok {
+		return "", ErrInvalidSecret
+	}
+
+	var counter [8]byte
+	binary.BigEndian.PutUint64(counter[:], uint64(k.Step(t)))
+
+	mac := hmac.New(newHash, secret)
+	mac.Write(counter[:])
+	sum := mac.Sum(nil)
+
+	offset := sum[len(sum)-1] & 0x0f
+	value := binary.BigEndian.Uint32(sum[offset:offset+4]) & 0x7fffffff
+
+	mod := uint32(1)
+	for i := 0; i < k.digits(); i++ {
+		mod *= 10
+	}
+
+	return fmt.Sprintf("%0*d", k.digits(), value%mod), nil
+}
+
+// Validate проверяет код с допуском в один период в обе стороны (рассинхронизация часов)
+func (k Key) Validate(code string, t time.Time) bool {
+	_, ok := k.Match(code, t)
+	return ok
+}
+
+// Match проверяет код так же, как Validate, и возвращает номер периода, которому он соответствует.
+// Номер нужен, чтобы не принимать один и тот же код повторно
+func (k Key) Match(code string, t time.Time) (int64, bool) {
+	if len(code) != k.digits() {
+		return 0, false
+	}
+
+	step := time.Duration(k.period()) * time.Second
+	for _, moment := range []time.Time{t, t.Add(-step), t.Add(step)} {
+		expected, err := k.Code(moment)
+		if err != nil {
+			return 0, false
+		}
+		if hmac.Equal([]byte(expected), []byte(code)) {
+			return k.Step(moment), true
+		}
+	}
+
+	return 0, false
+}
+
+// Step возвращает номер периода для момента времени t
+func (k Key) Step(t time.Time) int64 {
+	return t.Unix() / int64(k.period())
+}
+
+func (k Key) algorithm() string {
+	if k.Algorithm == "" {
+		return DefaultAlgorithm
+	}
+	return strings.ToUpper(k.Algorithm)
+}
+
+func (k Key) digits() int {
+	if k.Digits <= 0 {
+		return DefaultDigits
+	}
+	return k.Digits
+}
+
+func (k Key) period() int {
+	if k.Period <= 0 {
+		return DefaultPeriod
+	}
+	return k.Period
+}
+
+// decodeSecret декодирует base32 секрет, допуская пробелы, нижний регистр и отсутствие паддинга
+func decodeSecret(secret string) ([]byte, error) {
+	s := strings.ToUpper(strings.ReplaceAll(secret, " ", ""))
+	s = strings.TrimRight(s, "=")
+	if s == "" {
+		return nil, ErrInvalidSecret
+	}
+
+	b, err := base32.StdEncoding.WithPadding(base32.NoPadding).DecodeString(s)
+	if err != nil {
+		return nil, ErrInvalidSecret
+	}
+	return b, nil
+}
+
+// ParseURI разбирает otpauth://totp/... адрес. Строка без схемы считается base32 секретом с параметрами по умолчанию
+func ParseURI(uri string) (Key, error) {
+	uri = strings.TrimSpace(uri)
+	if !strings.HasPrefix(strings.ToLower(uri), "otpauth://") {
+		_, err := decodeSecret(uri)
+		if err != nil {
+			return Key{}, err
+		}
+		return Key{Secret: uri}, nil
+	}
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return Key{}, err
+	}
+	if !strings.EqualFold(u.Host, "totp") {
+		return Key{}, ErrUnsupportedType
+	}
+
+	q := u.Query()
+	key := Key{
+		Secret:    q.Get("secret"),
+		Issuer:    q.Get("issuer"),
+		Account:   strings.TrimPrefix(u.Path, "/"),
+		Algorithm: q.Get("algorithm"),
+	}
+	if i := strings.Index(key.Account, ":"); i >= 0 {
+		if key.Issuer == "" {
+			key.Issuer = key.Account[:i]
+		}
+		key.Account = strings.TrimSpace(key.Account[i+1:])
+	}
+	if v := q.Get("digits"); v != "" {
+		key.Digits, err = strconv.Atoi(v)
+		if err != nil || key.Digits < minDigits || key.Digits > maxDigits {
+			return Key{}, ErrInvalidSecret
+		}
+	}
+	if v := q.Get("period"); v != "" {
+		key.Period, err = strconv.Atoi(v)
+		if err != nil {
+			return Key{}, ErrInvalidSecret
+		}
+	}
+	if _, ok := algorithms[key.algorithm()]; !ok {
+		return Key{}, ErrInvalidSecret
+	}
+
+	_, err = decodeSecret(key.Secret)
+	if err != nil {
+		return Key{}, err
+	}
+
+	return key, nil
+}
+
+// Remaining возвращает время, в течение которого код для момента t остается действительным
+func (k Key) Remaining(t time.Time) time.Duration {
+	period := int64(k.period())
+	return time.Duration(period-t.Unix()%period) * time.Second
+}
@@ -0,0 +1,157 @@
+package otp_logic
+
+import (
+	"encoding/base32"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// тестовые векторы из приложения B RFC 6238
+func TestKey_Code(t *testing.T) {
+	seed := func(n int) string {
+		return base32.StdEncoding.EncodeToString([]byte(strings.Repeat("1234567890", 7)[:n]))
+	}
+	sha1Key := Key{Secret: seed(20), Digits: 8, Algorithm: "SHA1"}
+	sha256Key := Key{Secret: seed(32), Digits: 8, Algorithm: "SHA256"}
+	sha512Key := Key{Secret: seed(64), Digits: 8, Algorithm: "SHA512"}
+
+	tests := []struct {
+		description string
+		key         Key
+		unix        int64
+		want        string
+	}{
+		{description: "sha1 59", key: sha1Key, unix: 59, want: "94287082"},
+		{description: "sha256 59", key: sha256Key, unix: 59, want: "46119246"},
+		{description: "sha512 59", key: sha512Key, unix: 59, want: "90693936"},
+		{description: "sha1 1111111109", key: sha1Key, unix: 1111111109, want: "07081804"},
+		{description: "sha256 1111111111", key: sha256Key, unix: 1111111111, want: "67062674"},
+		{description: "sha512 1234567890", key: sha512Key, unix: 1234567890, want: "93441116"},
+		{description: "sha1 20000000000", key: sha1Key, unix: 20000000000, want: "65353130"},
+	}
+	for _, tt := range tests {
+		code, err := tt.key.Code(time.Unix(tt.unix, 0))
+		assert.Equalf(t, nil, err, tt.description)
+		assert.Equalf(t, tt.want, code, tt.description)
+	}
+
+	// ключ, созданный без ParseURI, с недопустимой длиной кода
+	for _, digits := range []int{9, 10, 32} {
+		key := Key{Secret: seed(20), Digits: digits}
+		_, err := key.Code(time.Unix(59, 0))
+		assert.Equalf(t, ErrInvalidSecret, err, "%d digits", digits)
+		assert.Falsef(t, key.Validate(strings.Repeat("0", digits), time.Unix(59, 0)), "%d digits", digits)
+	}
+}
+
+func TestKey_Validate(t *testing.T) {
+	key, err := NewKey("Keeper", "user")
+	assert.Nil(t, err)
+
+	now := time.Now()
+	current, _ := key.Code(now)
+	previous, _ := key.Code(now.Add(-30 * time.Second))
+	old, _ := key.Code(now.Add(-5 * time.Minute))
+	if old == current || old == previous {
+		old = "000000"
+	}
+
+	tests := []struct {
+		description string
+		code        string
+		want        bool
+	}{
+		{description: "current code", code: current, want: true},
+		{description: "previous period", code: previous, want: true},
+		{description: "old code", code: old, want: false},
+		{description: "wrong length", code: current[:len(current)-1], want: false},
+		{description: "empty code", code: "", want: false},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.want, key.Validate(tt.code, now), tt.description)
+	}
+}
+
+func TestParseURI(t *testing.T) {
+	tests := []struct {
+		description string
+		uri         string
+		want        Key
+		wantErr     error
+	}{
+		{
+			description: "full uri",
+			uri:         "otpauth://totp/ACME%20Co:john@example.com?secret=HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ&issuer=ACME%20Co&algorithm=SHA256&digits=8&period=60",
+			want: Key{
+				Secret:    "HXDMVJECJJWSRB3HWIZR4IFUGFTMXBOZ",
+				Issuer:    "ACME Co",
+				Account:   "john@example.com",
+				Algorithm: "SHA256",
+				Digits:    8,
+				Period:    60,
+			},
+		},
+		{
+			description: "issuer from label",
+			uri:         "otpauth://totp/GitHub:octocat?secret=JBSWY3DPEHPK3PXP",
+			want:        Key{Secret: "JBSWY3DPEHPK3PXP", Issuer: "GitHub", Account: "octocat"},
+		},
+		{
+			description: "bare secret",
+			uri:         "jbsw y3dp ehpk 3pxp",
+			want:        Key{Secret: "jbsw y3dp ehpk 3pxp"},
+		},
+		{
+			description: "hotp is not supported",
+			uri:         "otpauth://hotp/GitHub:octocat?secret=JBSWY3DPEHPK3PXP&counter=1",
+			wantErr:     ErrUnsupportedType,
+		},
+		{
+			description: "invalid secret",
+			uri:         "otpauth://totp/GitHub:octocat?secret=1",
+			wantErr:     ErrInvalidSecret,
+		},
+		{
+			description: "9 digits",
+			uri:         "otpauth://totp/GitHub:octocat?secret=JBSWY3DPEHPK3PXP&digits=9",
+			wantErr:     ErrInvalidSecret,
+		},
+		{
+			description: "10 digits",
+			uri:         "otpauth://totp/GitHub:octocat?secret=JBSWY3DPEHPK3PXP&digits=10",
+			wantErr:     ErrInvalidSecret,
+		},
+		{
+			description: "32 digits",
+			uri:         "otpauth://totp/GitHub:octocat?secret=JBSWY3DPEHPK3PXP&digits=32",
+			wantErr:     ErrInvalidSecret,
+		},
+		{
+			description: "5 digits",
+			uri:         "otpauth://totp/GitHub:octocat?secret=JBSWY3DPEHPK3PXP&digits=5",
+			wantErr:     ErrInvalidSecret,
+		},
+	}
+	for _, tt := range tests {
+		key, err := ParseURI(tt.uri)
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+		assert.Equalf(t, tt.want, key, tt.description)
+	}
+}
+
+func TestKey_URI(t *testing.T) {
+	key, _ := NewKey("Keeper", "user@example.com")
+
+	parsed, err := ParseURI(key.URI())
+	assert.Nil(t, err)
+	assert.Equal(t, key, parsed)
+}
+
+func TestKey_Remaining(t *testing.T) {
+	key := Key{Period: 30}
+	assert.Equal(t, 30*time.Second, key.Remaining(time.Unix(60, 0)))
+	assert.Equal(t, 1*time.Second, key.Remaining(time.Unix(89, 0)))
+}
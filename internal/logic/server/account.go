@@ -0,0 +1,53 @@
+package server_logic
+
+import (
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// CheckPassword проверяет пароль уже авторизованного пользователя
+func CheckPassword(user, password string, s models.Storable4Server) error {
+	login, err := s.GetLogin(user)
+	if err != nil {
+		return err
+	}
+
+	id, err := CheckUser(models.UserRequest{Login: login, Password: password}, s)
+	if err != nil {
+		return err
+	}
+	if id != user {
+		return models.ErrUserDataConflict
+	}
+
+	return nil
+}
+
+// ChangePassword меняет пароль пользователя и отзывает все его сессии, кроме текущей
+func ChangePassword(req models.ChangePasswordRequest, user, session string, s models.Storable4Server) error {
+	err := CheckPassword(user, req.Password, s)
+	if err != nil {
+		return err
+	}
+
+	err = s.UpdatePassword(user, req.NewPassword)
+	if err != nil {
+		return err
+	}
+
+	return s.RevokeSessions(user, session)
+}
+
+// ChangeLogin меняет логин пользователя. Возвращает models.ErrUserConflict, если логин занят
+func ChangeLogin(req models.ChangeLoginRequest, user string, s models.Storable4Server) error {
+	err := CheckPassword(user, req.Password, s)
+	if err != nil {
+		return err
+	}
+
+	return s.UpdateLogin(user, req.NewLogin)
+}
+
+// DeleteAccount удаляет пользователя вместе со всеми его данными, сессиями и настройками второго фактора
+func DeleteAccount(user string, s models.Storable4Server) error {
+	return s.DeleteUser(user)
+}
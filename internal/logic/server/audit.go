@@ -0,0 +1,49 @@
+package server_logic
+
+import (
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+const (
+	AuditDefaultLimit = 100
+	AuditMaxLimit     = 1000
+)
+
+// RecordEvent добавляет событие в журнал аудита. Время события по умолчанию - текущее
+func RecordEvent(e models.AuditEvent, s models.Storable4Server) error {
+	if e.Time.IsZero() {
+		e.Time = time.Now()
+	}
+
+	return s.AddAuditEvent(e)
+}
+
+// FailedLoginEvent создает событие неудачного входа. Если логин существует, событие попадает в журнал его владельца,
+// иначе сохраняется без пользователя с логином в Details
+func FailedLoginEvent(login, reason string, s models.Storable4Server) (models.AuditEvent, error) {
+	id, _, err := s.CheckUser(login)
+	if err != nil {
+		return models.AuditEvent{}, err
+	}
+
+	e := models.AuditEvent{User: id, Event: models.AuditLoginFailed, Details: reason}
+	if id == "" {
+		e.Details = "unknown login " + login
+	}
+
+	return e, nil
+}
+
+// GetAuditEvents возвращает последние события пользователя, начиная с новых. Значение limit ограничивается AuditMaxLimit
+func GetAuditEvents(user string, limit int, s models.Storable4Server) ([]models.AuditEvent, error) {
+	if limit <= 0 {
+		limit = AuditDefaultLimit
+	}
+	if limit > AuditMaxLimit {
+		limit = AuditMaxLimit
+	}
+
+	return s.GetAuditEvents(user, limit)
+}
@@ -0,0 +1,25 @@
+package server_logic
+
+import (
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+const (
+	DueDefaultDays = 30
+	DueMaxDays     = 3650
+)
+
+// GetDue возвращает записи пользователя, пароли которых нужно сменить в ближайшие days дней, включая просроченные.
+// Значение days ограничивается DueMaxDays
+func GetDue(user string, days int, now time.Time, s models.Storable4Server) ([]models.UserData, error) {
+	if days <= 0 {
+		days = DueDefaultDays
+	}
+	if days > DueMaxDays {
+		days = DueMaxDays
+	}
+
+	return s.GetDueData(user, now.AddDate(0, 0, days))
+}
@@ -0,0 +1,48 @@
+package server_logic
+
+import (
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// GetFolder возвращает записи пользователя, лежащие непосредственно в папке folder. models.RootFolder
+// соответствует записям вне папок
+func GetFolder(user, folder string, s models.Storable4Server) ([]models.UserData, error) {
+	if folder == models.RootFolder {
+		folder = ""
+	}
+
+	return s.GetFolderData(user, folder)
+}
+
+// Move переносит записи пользователя в папку req.Folder. Папка должна принадлежать пользователю, а папку нельзя
+// перенести в саму себя или во вложенную в нее папку. Сервер не разбирает содержимое записей, поэтому то, что
+// req.Folder является папкой, проверяет клиент
+func Move(req models.MoveRequest, s models.Storable4Server, user string) error {
+	if req.Folder != "" {
+		data, err := s.GetData(user)
+		if err != nil {
+			return err
+		}
+
+		parents := make(map[string]string, len(data))
+		for _, el := range data {
+			parents[el.ID] = el.Folder
+		}
+		if _, ok := parents[req.Folder]; !ok {
+			return models.ErrNotFound
+		}
+
+		moved := make(map[string]bool, len(req.IDs))
+		for _, id := range req.IDs {
+			moved[id] = true
+		}
+		// родители проверяются не больше len(data) раз, чтобы уже испорченные связи не зациклили проверку
+		for id, i := req.Folder, 0; id != "" && i <= len(data); id, i = parents[id], i+1 {
+			if moved[id] {
+				return models.ErrFolderCycle
+			}
+		}
+	}
+
+	return s.MoveData(req, user)
+}
@@ -0,0 +1,156 @@
+package server_logic
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+const (
+	LoginFailuresThreshold = 5  // неудачных попыток входа для одного логина до блокировки
+	IPFailuresThreshold    = 20 // неудачных попыток входа с одного ip до блокировки
+	RegistrationThreshold  = 10 // попыток регистрации с одного ip до блокировки
+
+	LockoutBase    = 30 * time.Second // длительность первой блокировки, каждая следующая вдвое дольше
+	LockoutMax     = time.Hour
+	FailuresWindow = 15 * time.Minute // после этого времени без ошибок счетчик сбрасывается
+)
+
+// attemptsMu делает чтение и изменение счетчиков попыток атомарным: без него параллельные запросы теряют
+// увеличения счетчика и проходят проверку лимита одновременно
+var attemptsMu sync.Mutex
+
+// Limit ключ, по которому считаются попытки, и допустимое число попыток до блокировки
+type Limit struct {
+	Key       string
+	Threshold int
+}
+
+// IPLimit возвращает ограничение неудачных попыток входа с одного ip
+func IPLimit(ip string) Limit {
+	return Limit{Key: "ip:" + ip, Threshold: IPFailuresThreshold}
+}
+
+// LoginLimit возвращает ограничение неудачных попыток входа для одного логина
+func LoginLimit(login string) Limit {
+	return Limit{Key: "login:" + strings.ToLower(login), Threshold: LoginFailuresThreshold}
+}
+
+// AccountLimit возвращает ограничение неудачных проверок пароля при изменении аккаунта авторизованным пользователем
+func AccountLimit(user string) Limit {
+	return Limit{Key: "user:" + user, Threshold: LoginFailuresThreshold}
+}
+
+// RegistrationLimit возвращает ограничение попыток регистрации с одного ip. Учитываются все попытки, включая
+// успешные, через TakeAttempt: лимит ограничивает и массовое создание аккаунтов, и перебор занятых логинов
+func RegistrationLimit(ip string) Limit {
+	return Limit{Key: "reg:" + ip, Threshold: RegistrationThreshold}
+}
+
+// CheckLimits возвращает *models.RateLimitError, если хотя бы один из ключей заблокирован на момент now
+func CheckLimits(s models.Storable4Server, now time.Time, limits ...Limit) error {
+	attemptsMu.Lock()
+	defer attemptsMu.Unlock()
+
+	return checkLimits(s, now, limits...)
+}
+
+// TakeAttempt проверяет лимиты и учитывает попытку так же, как RegisterFailure, под одной блокировкой.
+// Используется для ограничений, в которых считаются все попытки, а не только неудачные
+func TakeAttempt(s models.Storable4Server, now time.Time, limits ...Limit) error {
+	attemptsMu.Lock()
+	defer attemptsMu.Unlock()
+
+	err := checkLimits(s, now, limits...)
+	if err != nil {
+		return err
+	}
+	return registerFailure(s, now, limits...)
+}
+
+func checkLimits(s models.Storable4Server, now time.Time, limits ...Limit) error {
+	var retryAfter time.Duration
+	for _, l := range limits {
+		a, err := s.GetAttempts(l.Key)
+		if err != nil {
+			return err
+		}
+
+		if d := a.LockedUntil.Sub(now); d > retryAfter {
+			retryAfter = d
+		}
+	}
+
+	if retryAfter > 0 {
+		return &models.RateLimitError{RetryAfter: retryAfter.Round(time.Second)}
+	}
+	return nil
+}
+
+// RegisterFailure учитывает неудачную попытку для каждого ключа. При достижении порога ключ блокируется,
+// длительность блокировки растет экспоненциально с каждой следующей ошибкой
+func RegisterFailure(s models.Storable4Server, now time.Time, limits ...Limit) error {
+	attemptsMu.Lock()
+	defer attemptsMu.Unlock()
+
+	return registerFailure(s, now, limits...)
+}
+
+func registerFailure(s models.Storable4Server, now time.Time, limits ...Limit) error {
+	for _, l := range limits {
+		a, err := s.GetAttempts(l.Key)
+		if err != nil {
+			return err
+		}
+
+		last := a.LastFailure
+		if a.LockedUntil.After(last) {
+			last = a.LockedUntil
+		}
+		if now.Sub(last) > FailuresWindow {
+			a = models.AuthAttempts{}
+		}
+
+		a.Failures++
+		a.LastFailure = now
+		if a.Failures >= l.Threshold {
+			a.LockedUntil = now.Add(lockoutDuration(a.Failures - l.Threshold))
+		}
+
+		err = s.SetAttempts(l.Key, a)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ResetLimits сбрасывает счетчики попыток (например, после успешного входа)
+func ResetLimits(s models.Storable4Server, limits ...Limit) error {
+	attemptsMu.Lock()
+	defer attemptsMu.Unlock()
+
+	for _, l := range limits {
+		err := s.ResetAttempts(l.Key)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func lockoutDuration(exceeded int) time.Duration {
+	d := LockoutBase
+	for i := 0; i < exceeded && d < LockoutMax; i++ {
+		d *= 2
+	}
+	if d > LockoutMax {
+		d = LockoutMax
+	}
+
+	return d
+}
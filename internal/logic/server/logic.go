@@ -1,13 +1,58 @@
 package server_logic
 
 import (
+	"crypto/rand"
+	"sync"
 	"time"
 
 	"github.com/azazel3ooo/keeper/internal/models"
 	"github.com/golang-jwt/jwt"
 )
 
-const JWTSalt = "super_secret_salt"
+// tokenKey и tokenTTL ключ подписи и время жизни токенов доступа. Без заданного ключа используется случайный,
+// и после перезапуска сервера потребуется повторный вход
+var (
+	tokenMu  sync.RWMutex
+	tokenKey = randomKey()
+	tokenTTL = 5 * time.Minute
+)
+
+// SetTokenSettings задает ключ подписи и время жизни токенов. Пустые значения не меняют текущие
+func SetTokenSettings(key string, ttl time.Duration) {
+	tokenMu.Lock()
+	defer tokenMu.Unlock()
+
+	if key != "" {
+		tokenKey = []byte(key)
+	}
+	if ttl > 0 {
+		tokenTTL = ttl
+	}
+}
+
+// TokenTTL возвращает время жизни токенов доступа
+func TokenTTL() time.Duration {
+	tokenMu.RLock()
+	defer tokenMu.RUnlock()
+
+	return tokenTTL
+}
+
+func signingKey() []byte {
+	tokenMu.RLock()
+	defer tokenMu.RUnlock()
+
+	return tokenKey
+}
+
+func randomKey() []byte {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	if err != nil {
+		panic(err)
+	}
+	return key
+}
 
 // Registration выполняет регистрацию пользователя по данным, переданным в models.UserRequest, в хранилище models.Storable4Server
 // возвращает id созданного пользователя
@@ -20,22 +65,22 @@ func Registration(request models.UserRequest, s models.Storable4Server) (string,
 	return id, err
 }
 
-// GenerateToken по переданному id создает JWT. Опционально можно задать необходимую длительность жизни токена (по умолчанию 5 минут)
-func GenerateToken(id string, duration ...float64) (string, error) {
-	var val float64
+// GenerateToken по переданному id пользователя и id сессии создает JWT. Опционально можно задать необходимую
+// длительность жизни токена в минутах (по умолчанию из SetTokenSettings, 5 минут)
+func GenerateToken(id, session string, duration ...float64) (string, error) {
+	ttl := TokenTTL()
 	if len(duration) == 1 {
-		val = duration[0]
-	} else {
-		val = 5
+		ttl = time.Duration(duration[0] * float64(time.Minute))
 	}
 
 	claims := jwt.MapClaims{
 		"id":  id,
-		"exp": time.Now().Add(time.Duration(val) * time.Minute).Unix(),
+		"sid": session,
+		"exp": time.Now().Add(ttl).Unix(),
 	}
 
 	at := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	token, err := at.SignedString([]byte(JWTSalt)) // salt for jwt
+	token, err := at.SignedString(signingKey())
 	if err != nil {
 		return "", err
 	}
@@ -43,11 +88,13 @@ func GenerateToken(id string, duration ...float64) (string, error) {
 	return token, nil
 }
 
-// CheckToken проверяет корректность переданного JWT и возвращает значение поля id из этого токена
-func CheckToken(token string) (string, error) {
+// CheckToken проверяет корректность переданного JWT и то, что его сессия не отозвана.
+// Возвращает id пользователя и id сессии из этого токена
+func CheckToken(token string, s models.Storable4Server) (string, string, error) {
 	type myCl struct {
 		jwt.StandardClaims
-		Id string `json:"id"`
+		Id  string `json:"id"`
+		Sid string `json:"sid"`
 	}
 
 	cl := myCl{}
@@ -56,21 +103,29 @@ func CheckToken(token string) (string, error) {
 		if token.Method != jwt.SigningMethodHS256 {
 			return "", models.ErrInvalidToken
 		}
-		return []byte(JWTSalt), nil
+		return signingKey(), nil
 	})
 	if err != nil || !t.Valid {
-		return "", models.ErrInvalidToken
+		return "", "", models.ErrInvalidToken
 	}
 
 	if cl, ok := t.Claims.(*myCl); ok {
 		if cl.ExpiresAt <= time.Now().Unix() {
-			return "", models.ErrExpiredToken
+			return "", "", models.ErrExpiredToken
 		}
 	} else {
-		return "", models.ErrInvalidToken
+		return "", "", models.ErrInvalidToken
+	}
+
+	session, err := s.GetSession(cl.Sid)
+	if err != nil {
+		return "", "", err
+	}
+	if session.ID == "" || session.Revoked || session.User != cl.Id {
+		return "", "", models.ErrRevokedSession
 	}
 
-	return cl.Id, nil
+	return cl.Id, cl.Sid, nil
 }
 
 // CheckUser проверяет соответствие пароля и логина. Возвращает id пользователя
@@ -1,19 +1,33 @@
 package server_logic
 
 import (
+	"errors"
 	"log"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
+	"time"
 
+	otp "github.com/azazel3ooo/keeper/internal/logic/otp"
 	"github.com/azazel3ooo/keeper/internal/models"
 	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
 	"github.com/stretchr/testify/assert"
 )
 
 func TestCheckToken(t *testing.T) {
+	var storage testing_repos_server.TestingServerStorage
+	storage.Init()
+
 	expiration := 1.0
 	realID := "1"
-	testToken, _ := GenerateToken(realID, expiration)
-	expiredToken, _ := GenerateToken(realID, 0.0)
+	storage.CreateSession(models.Session{ID: "session", User: realID})
+	storage.CreateSession(models.Session{ID: "revoked", User: realID, Revoked: true})
+	testToken, _ := GenerateToken(realID, "session", expiration)
+	expiredToken, _ := GenerateToken(realID, "session", 0.0)
+	revokedToken, _ := GenerateToken(realID, "revoked", expiration)
+	unknownSessionToken, _ := GenerateToken(realID, "unknown", expiration)
+	foreignSessionToken, _ := GenerateToken("2", "session", expiration)
 
 	tests := []struct {
 		description string
@@ -39,10 +53,28 @@ func TestCheckToken(t *testing.T) {
 			want:        "",
 			wantErr:     models.ErrExpiredToken,
 		},
+		{
+			description: "revoked session",
+			token:       revokedToken,
+			want:        "",
+			wantErr:     models.ErrRevokedSession,
+		},
+		{
+			description: "unknown session",
+			token:       unknownSessionToken,
+			want:        "",
+			wantErr:     models.ErrRevokedSession,
+		},
+		{
+			description: "session of another user",
+			token:       foreignSessionToken,
+			want:        "",
+			wantErr:     models.ErrRevokedSession,
+		},
 	}
 
 	for _, tt := range tests {
-		id, err := CheckToken(tt.token)
+		id, _, err := CheckToken(tt.token, storage)
 		assert.Equalf(t, tt.want, id, tt.description)
 		assert.Equalf(t, tt.wantErr, err, tt.description)
 	}
@@ -202,3 +234,491 @@ func TestUpdate(t *testing.T) {
 		assert.Equalf(t, true, prevRes[0].Data != res[0].Data, tt.description)
 	}
 }
+
+func TestConfirmTOTP(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	user, _ := s.CreateUser("totp_user", "pas")
+	enroll, err := EnrollTOTP(user, s)
+	assert.Nil(t, err)
+	code, _ := otp.Key{Secret: enroll.Secret}.Code(time.Now())
+
+	tests := []struct {
+		description string
+		code        string
+		wantCodes   int
+		wantErr     error
+	}{
+		{
+			description: "invalid code",
+			code:        "000000x",
+			wantErr:     models.ErrInvalidCode,
+		},
+		{
+			description: "success confirm",
+			code:        code,
+			wantCodes:   RecoveryCodesCount,
+		},
+		{
+			description: "already enabled",
+			code:        code,
+			wantErr:     models.ErrTOTPEnabled,
+		},
+	}
+	for _, tt := range tests {
+		codes, err := ConfirmTOTP(user, tt.code, s)
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+		assert.Equalf(t, tt.wantCodes, len(codes), tt.description)
+	}
+
+	_, err = EnrollTOTP(user, s)
+	assert.Equalf(t, models.ErrTOTPEnabled, err, "enroll after confirm")
+}
+
+func TestCheckSecondFactor(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	plain, _ := s.CreateUser("plain", "pas")
+	user, _ := s.CreateUser("totp", "pas")
+	enroll, _ := EnrollTOTP(user, s)
+	key := otp.Key{Secret: enroll.Secret}
+	confirmCode, _ := key.Code(time.Now())
+	recovery, _ := ConfirmTOTP(user, confirmCode, s)
+	// код следующего периода принимается благодаря допуску рассинхронизации часов
+	code, _ := key.Code(time.Now().Add(30 * time.Second))
+
+	tests := []struct {
+		description string
+		user        string
+		code        string
+		wantErr     error
+	}{
+		{
+			description: "without second factor",
+			user:        plain,
+			wantErr:     nil,
+		},
+		{
+			description: "code required",
+			user:        user,
+			wantErr:     models.ErrSecondFactorRequired,
+		},
+		{
+			description: "valid code",
+			user:        user,
+			code:        code,
+			wantErr:     nil,
+		},
+		{
+			description: "replayed code",
+			user:        user,
+			code:        code,
+			wantErr:     models.ErrInvalidCode,
+		},
+		{
+			description: "code of earlier period than used one",
+			user:        user,
+			code:        confirmCode,
+			wantErr:     models.ErrInvalidCode,
+		},
+		{
+			description: "invalid code",
+			user:        user,
+			code:        "abcdef",
+			wantErr:     models.ErrInvalidCode,
+		},
+		{
+			description: "recovery code",
+			user:        user,
+			code:        strings.ToUpper(recovery[0]),
+			wantErr:     nil,
+		},
+		{
+			description: "used recovery code",
+			user:        user,
+			code:        recovery[0],
+			wantErr:     models.ErrInvalidCode,
+		},
+	}
+	for _, tt := range tests {
+		err := CheckSecondFactor(tt.user, tt.code, s)
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+	}
+}
+
+func TestDisableTOTP(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	user, _ := s.CreateUser("totp", "pas")
+	assert.Equal(t, models.ErrTOTPNotEnrolled, DisableTOTP(user, "123456", s))
+
+	enroll, _ := EnrollTOTP(user, s)
+	key := otp.Key{Secret: enroll.Secret}
+	code, _ := key.Code(time.Now())
+	_, err := ConfirmTOTP(user, code, s)
+	assert.Nil(t, err)
+
+	assert.Equalf(t, models.ErrInvalidCode, DisableTOTP(user, code, s), "code used for confirm")
+	_, confirmed, _ := s.GetTOTP(user)
+	assert.True(t, confirmed)
+
+	next, _ := key.Code(time.Now().Add(30 * time.Second))
+	assert.Nil(t, DisableTOTP(user, next, s))
+	assert.Nilf(t, CheckSecondFactor(user, "", s), "second factor is not required after disable")
+}
+
+func TestRegisterFailure(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	limit := Limit{Key: "login:test", Threshold: 3}
+	now := time.Now()
+
+	tests := []struct {
+		description    string
+		at             time.Time
+		wantRetryAfter time.Duration
+	}{
+		{description: "first failure", at: now, wantRetryAfter: 0},
+		{description: "second failure", at: now, wantRetryAfter: 0},
+		{description: "threshold reached", at: now, wantRetryAfter: LockoutBase},
+		{description: "next failure doubles lockout", at: now, wantRetryAfter: 2 * LockoutBase},
+		{description: "failure after window resets counter", at: now.Add(FailuresWindow + time.Hour), wantRetryAfter: 0},
+	}
+	for _, tt := range tests {
+		err := RegisterFailure(s, tt.at, limit)
+		assert.Nilf(t, err, tt.description)
+
+		err = CheckLimits(s, tt.at, limit)
+		if tt.wantRetryAfter == 0 {
+			assert.Nilf(t, err, tt.description)
+			continue
+		}
+
+		var limitErr *models.RateLimitError
+		assert.Truef(t, errors.As(err, &limitErr), tt.description)
+		assert.Equalf(t, tt.wantRetryAfter, limitErr.RetryAfter, tt.description)
+		assert.Truef(t, errors.Is(err, models.ErrTooManyRequests), tt.description)
+	}
+
+	err := ResetLimits(s, limit)
+	assert.Nil(t, err)
+	assert.Nil(t, CheckLimits(s, now, limit))
+}
+
+func TestTakeAttempt(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	limit := Limit{Key: "reg:test", Threshold: 10}
+	now := time.Now()
+
+	// параллельные попытки не проходят проверку лимита одновременно: принимается ровно Threshold попыток
+	var wg sync.WaitGroup
+	var accepted atomic.Int32
+	for i := 0; i < 50; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if TakeAttempt(s, now, limit) == nil {
+				accepted.Add(1)
+			}
+		}()
+	}
+	wg.Wait()
+
+	assert.Equal(t, int32(limit.Threshold), accepted.Load())
+	assert.ErrorIs(t, CheckLimits(s, now, limit), models.ErrTooManyRequests)
+	assert.Nilf(t, TakeAttempt(s, now.Add(LockoutMax+FailuresWindow+time.Minute), limit), "after lockout and window")
+}
+
+func TestGetSessions(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	now := time.Now()
+	s.CreateSession(models.Session{ID: "current", User: "user", Expires: now.Add(time.Minute)})
+	s.CreateSession(models.Session{ID: "other", User: "user", Expires: now.Add(time.Minute)})
+	s.CreateSession(models.Session{ID: "expired", User: "user", Expires: now.Add(-time.Minute)})
+	s.CreateSession(models.Session{ID: "revoked", User: "user", Expires: now.Add(time.Minute), Revoked: true})
+	s.CreateSession(models.Session{ID: "foreign", User: "other", Expires: now.Add(time.Minute)})
+
+	sessions, err := GetSessions("user", "current", s)
+	assert.Nil(t, err)
+
+	current := make(map[string]bool)
+	for _, el := range sessions {
+		current[el.ID] = el.Current
+	}
+	assert.Equal(t, map[string]bool{"current": true, "other": false}, current)
+
+	session, err := CreateSession("user", models.UserRequest{Device: "laptop"}, "127.0.0.1", s)
+	assert.Nil(t, err)
+	assert.WithinDuration(t, time.Now().Add(TokenTTL()), session.Expires, time.Second)
+}
+
+func TestRefreshToken(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	now := time.Now()
+	s.CreateSession(models.Session{ID: "soon", User: "user", Expires: now.Add(30 * time.Second)})
+	s.CreateSession(models.Session{ID: "later", User: "user", Expires: now.Add(time.Hour)})
+	s.CreateSession(models.Session{ID: "expired", User: "user", Expires: now.Add(-time.Second)})
+	s.CreateSession(models.Session{ID: "revoked", User: "user", Expires: now.Add(time.Hour), Revoked: true})
+
+	tests := []struct {
+		description string
+		session     string
+		user        string
+		wantToken   bool
+		wantErr     error
+	}{
+		{description: "expires soon", session: "soon", user: "user", wantToken: true},
+		{description: "refresh is not needed", session: "later", user: "user"},
+		{description: "expired session", session: "expired", user: "user", wantErr: models.ErrExpiredToken},
+		{description: "revoked session", session: "revoked", user: "user", wantErr: models.ErrRevokedSession},
+		{description: "foreign session", session: "soon", user: "other", wantErr: models.ErrRevokedSession},
+		{description: "unknown session", session: "unknown", user: "user", wantErr: models.ErrRevokedSession},
+	}
+	for _, tt := range tests {
+		token, err := RefreshToken(tt.user, tt.session, time.Minute, s)
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+		assert.Equalf(t, tt.wantToken, token != "", tt.description)
+		if !tt.wantToken {
+			continue
+		}
+
+		id, session, err := CheckToken(token, s)
+		assert.Nilf(t, err, tt.description)
+		assert.Equalf(t, []string{tt.user, tt.session}, []string{id, session}, tt.description)
+		extended, _ := s.GetSession(tt.session)
+		assert.WithinDurationf(t, time.Now().Add(TokenTTL()), extended.Expires, time.Second, tt.description)
+	}
+}
+
+func TestChangePassword(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	user, _ := s.CreateUser("user", "old")
+	s.CreateSession(models.Session{ID: "current", User: user})
+	s.CreateSession(models.Session{ID: "other", User: user})
+
+	tests := []struct {
+		description string
+		req         models.ChangePasswordRequest
+		wantErr     error
+	}{
+		{
+			description: "wrong password",
+			req:         models.ChangePasswordRequest{Password: "wrong", NewPassword: "new"},
+			wantErr:     models.ErrUserDataConflict,
+		},
+		{
+			description: "success change",
+			req:         models.ChangePasswordRequest{Password: "old", NewPassword: "new"},
+			wantErr:     nil,
+		},
+	}
+	for _, tt := range tests {
+		err := ChangePassword(tt.req, user, "current", s)
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+	}
+
+	_, err := CheckUser(models.UserRequest{Login: "user", Password: "new"}, s)
+	assert.Nil(t, err)
+
+	current, _ := s.GetSession("current")
+	other, _ := s.GetSession("other")
+	assert.Equalf(t, false, current.Revoked, "current session")
+	assert.Equalf(t, true, other.Revoked, "other session")
+}
+
+func TestChangeLogin(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	user, _ := s.CreateUser("user", "pas")
+	s.CreateUser("taken", "pas")
+
+	tests := []struct {
+		description string
+		req         models.ChangeLoginRequest
+		wantErr     error
+	}{
+		{
+			description: "wrong password",
+			req:         models.ChangeLoginRequest{Password: "wrong", NewLogin: "new"},
+			wantErr:     models.ErrUserDataConflict,
+		},
+		{
+			description: "login conflict",
+			req:         models.ChangeLoginRequest{Password: "pas", NewLogin: "taken"},
+			wantErr:     models.ErrUserConflict,
+		},
+		{
+			description: "success change",
+			req:         models.ChangeLoginRequest{Password: "pas", NewLogin: "new"},
+			wantErr:     nil,
+		},
+	}
+	for _, tt := range tests {
+		err := ChangeLogin(tt.req, user, s)
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+	}
+
+	login, _ := s.GetLogin(user)
+	assert.Equal(t, "new", login)
+}
+
+func TestDeleteAccount(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	user, _ := s.CreateUser("user", "pas")
+	other, _ := s.CreateUser("other", "pas")
+	s.SetData(models.UserData{ID: "1", Data: "data"}, user)
+	s.SetData(models.UserData{ID: "2", Data: "data"}, other)
+	s.CreateSession(models.Session{ID: "session", User: user})
+
+	err := DeleteAccount(user, s)
+	assert.Nil(t, err)
+
+	data, _ := s.GetData(user)
+	assert.Equalf(t, 0, len(data), "user data")
+	data, _ = s.GetData(other)
+	assert.Equalf(t, 1, len(data), "other user data")
+	session, _ := s.GetSession("session")
+	assert.Equalf(t, "", session.ID, "user session")
+	id, _ := CheckUser(models.UserRequest{Login: "user", Password: "pas"}, s)
+	assert.Equalf(t, "", id, "user")
+}
+
+func TestRecoverAccount(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	user, _ := s.CreateUser("user", "forgotten")
+	s.CreateSession(models.Session{ID: "session", User: user})
+	key, err := IssueRecoveryKey(user, s)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		description string
+		req         models.RecoveryRequest
+		wantErr     error
+	}{
+		{
+			description: "unknown login",
+			req:         models.RecoveryRequest{Login: "unknown", RecoveryKey: key, NewPassword: "new"},
+			wantErr:     models.ErrUserDataConflict,
+		},
+		{
+			description: "wrong key",
+			req:         models.RecoveryRequest{Login: "user", RecoveryKey: "AAAA-BBBB", NewPassword: "new"},
+			wantErr:     models.ErrUserDataConflict,
+		},
+		{
+			description: "success recovery, key without separators",
+			req:         models.RecoveryRequest{Login: "user", RecoveryKey: strings.ReplaceAll(strings.ToLower(key), "-", ""), NewPassword: "new"},
+			wantErr:     nil,
+		},
+		{
+			description: "used key",
+			req:         models.RecoveryRequest{Login: "user", RecoveryKey: key, NewPassword: "other"},
+			wantErr:     models.ErrUserDataConflict,
+		},
+	}
+	for _, tt := range tests {
+		_, _, err := RecoverAccount(tt.req, s)
+		assert.Equalf(t, tt.wantErr, err, tt.description)
+	}
+
+	_, err = CheckUser(models.UserRequest{Login: "user", Password: "new"}, s)
+	assert.Nil(t, err)
+
+	session, _ := s.GetSession("session")
+	assert.Equalf(t, true, session.Revoked, "sessions revoked")
+
+	err = RevokeRecoveryKey(user, "new", s)
+	assert.Nil(t, err)
+	hash, _ := s.GetRecoveryKey(user)
+	assert.Equalf(t, "", hash, "revoked key")
+}
+
+func TestFailedLoginEvent(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	user, _ := s.CreateUser("user", "pas")
+
+	tests := []struct {
+		description string
+		login       string
+		want        models.AuditEvent
+	}{
+		{
+			description: "existing login",
+			login:       "user",
+			want:        models.AuditEvent{User: user, Event: models.AuditLoginFailed, Details: "invalid password"},
+		},
+		{
+			description: "unknown login",
+			login:       "nobody",
+			want:        models.AuditEvent{Event: models.AuditLoginFailed, Details: "unknown login nobody"},
+		},
+	}
+	for _, tt := range tests {
+		e, err := FailedLoginEvent(tt.login, "invalid password", s)
+		assert.Equalf(t, nil, err, tt.description)
+		assert.Equalf(t, tt.want, e, tt.description)
+	}
+}
+
+func TestMove(t *testing.T) {
+	var s testing_repos_server.TestingServerStorage
+	s.Init()
+
+	u := "tmp_u"
+	s.SetData(models.UserData{ID: "work", Data: "folder"}, u)
+	s.SetData(models.UserData{ID: "dev", Data: "folder", Folder: "work"}, u)
+	s.SetData(models.UserData{ID: "record", Data: "data"}, u)
+	s.SetData(models.UserData{ID: "foreign", Data: "folder"}, "other")
+
+	tests := []struct {
+		description string
+		req         models.MoveRequest
+		wantErr     error
+	}{
+		{
+			description: "into own subfolder",
+			req:         models.MoveRequest{IDs: []string{"work"}, Folder: "dev"},
+			wantErr:     models.ErrFolderCycle,
+		},
+		{
+			description: "into other user folder",
+			req:         models.MoveRequest{IDs: []string{"record"}, Folder: "foreign"},
+			wantErr:     models.ErrNotFound,
+		},
+		{
+			description: "into folder",
+			req:         models.MoveRequest{IDs: []string{"record"}, Folder: "dev"},
+		},
+	}
+	for _, tt := range tests {
+		err := Move(tt.req, s, u)
+		assert.ErrorIsf(t, err, tt.wantErr, tt.description)
+	}
+
+	res, err := GetFolder(u, "dev", s)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UserData{{ID: "record", Data: "data", Folder: "dev"}}, res)
+
+	res, err = GetFolder(u, models.RootFolder, s)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UserData{{ID: "work", Data: "folder"}}, res)
+}
@@ -0,0 +1,103 @@
+package server_logic
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base32"
+	"strings"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+const recoveryKeySize = 20 // байт случайных данных в ключе восстановления
+
+// GenerateRecoveryKey создает ключ восстановления вида XXXX-XXXX-... и его хэш для хранения
+func GenerateRecoveryKey() (key string, hash string, err error) {
+	b := make([]byte, recoveryKeySize)
+	_, err = rand.Read(b)
+	if err != nil {
+		return "", "", err
+	}
+
+	raw := base32.StdEncoding.WithPadding(base32.NoPadding).EncodeToString(b)
+	groups := make([]string, 0, len(raw)/4)
+	for i := 0; i < len(raw); i += 4 {
+		groups = append(groups, raw[i:i+4])
+	}
+
+	key = strings.Join(groups, "-")
+	return key, HashCode(key), nil
+}
+
+// IssueRecoveryKey выпускает новый ключ восстановления пользователя, заменяя предыдущий.
+// Ключ возвращается один раз, в хранилище сохраняется только его хэш
+func IssueRecoveryKey(user string, s models.Storable4Server) (string, error) {
+	key, hash, err := GenerateRecoveryKey()
+	if err != nil {
+		return "", err
+	}
+
+	err = s.SetRecoveryKey(user, hash)
+	if err != nil {
+		return "", err
+	}
+
+	return key, nil
+}
+
+// RecoverAccount сбрасывает пароль по ключу восстановления и отзывает все сессии пользователя.
+// Использованный ключ становится недействительным, взамен возвращается новый. Возвращает id пользователя и новый ключ
+func RecoverAccount(req models.RecoveryRequest, s models.Storable4Server) (string, string, error) {
+	id, _, err := s.CheckUser(req.Login)
+	if err != nil {
+		return "", "", err
+	}
+	if id == "" {
+		return "", "", models.ErrUserDataConflict
+	}
+
+	hash, err := s.GetRecoveryKey(id)
+	if err != nil {
+		return "", "", err
+	}
+	if hash == "" || subtle.ConstantTimeCompare([]byte(hash), []byte(HashCode(req.RecoveryKey))) != 1 {
+		return "", "", models.ErrUserDataConflict
+	}
+
+	err = s.UpdatePassword(id, req.NewPassword)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = s.RevokeSessions(id, "")
+	if err != nil {
+		return "", "", err
+	}
+
+	key, err := IssueRecoveryKey(id, s)
+	if err != nil {
+		return "", "", err
+	}
+
+	return id, key, nil
+}
+
+// RegenerateRecoveryKey перевыпускает ключ восстановления после проверки пароля
+func RegenerateRecoveryKey(user, password string, s models.Storable4Server) (string, error) {
+	err := CheckPassword(user, password, s)
+	if err != nil {
+		return "", err
+	}
+
+	return IssueRecoveryKey(user, s)
+}
+
+// RevokeRecoveryKey отзывает ключ восстановления после проверки пароля
+func RevokeRecoveryKey(user, password string, s models.Storable4Server) error {
+	err := CheckPassword(user, password, s)
+	if err != nil {
+		return err
+	}
+
+	return s.DeleteRecoveryKey(user)
+}
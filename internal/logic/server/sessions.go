@@ -0,0 +1,115 @@
+package server_logic
+
+import (
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// CreateSession создает сессию для входа пользователя с устройства, указанного в запросе
+func CreateSession(user string, req models.UserRequest, ip string, s models.Storable4Server) (models.Session, error) {
+	now := time.Now()
+	session := models.Session{
+		ID:       models.GenerateUserID(),
+		User:     user,
+		Device:   req.Device,
+		OS:       req.OS,
+		IP:       ip,
+		Created:  now,
+		LastSeen: now,
+		// сессия действует, пока действует выданный при входе токен. Продлевается только RefreshToken
+		Expires: now.Add(TokenTTL()),
+	}
+
+	err := s.CreateSession(session)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	return session, nil
+}
+
+// Login создает сессию и токен для пользователя после успешной регистрации или авторизации
+func Login(user string, req models.UserRequest, ip string, s models.Storable4Server) (string, error) {
+	session, err := CreateSession(user, req, ip, s)
+	if err != nil {
+		return "", err
+	}
+
+	return GenerateToken(user, session.ID)
+}
+
+// TouchSession обновляет время последнего обращения и ip сессии
+func TouchSession(session, ip string, s models.Storable4Server) error {
+	return s.TouchSession(session, ip, time.Now())
+}
+
+// RefreshToken выдает новый токен доступа для сессии, если ее срок истекает раньше чем через before, и продлевает
+// сессию до срока нового токена. Пустой токен - продление не нужно. Используется потоком Watch gRPC API, который
+// живет дольше токена, с которым был открыт, но завершается при отзыве или истечении сессии
+func RefreshToken(user, session string, before time.Duration, s models.Storable4Server) (string, error) {
+	current, err := s.GetSession(session)
+	if err != nil {
+		return "", err
+	}
+	if current.ID == "" || current.Revoked || current.User != user {
+		return "", models.ErrRevokedSession
+	}
+
+	now := time.Now()
+	if !current.Expires.After(now) {
+		return "", models.ErrExpiredToken
+	}
+	if current.Expires.Sub(now) > before {
+		return "", nil
+	}
+
+	token, err := GenerateToken(user, session)
+	if err != nil {
+		return "", err
+	}
+
+	err = s.ExtendSession(session, now.Add(TokenTTL()))
+	if err != nil {
+		return "", err
+	}
+
+	return token, nil
+}
+
+// GetSessions возвращает активные сессии пользователя, отмечая текущую. Отозванные и истекшие сессии не выводятся
+func GetSessions(user, current string, s models.Storable4Server) ([]models.Session, error) {
+	sessions, err := s.GetSessions(user)
+	if err != nil {
+		return nil, err
+	}
+
+	now := time.Now()
+	res := make([]models.Session, 0, len(sessions))
+	for _, el := range sessions {
+		if el.Revoked || !el.Expires.After(now) {
+			continue
+		}
+		el.Current = el.ID == current
+		res = append(res, el)
+	}
+
+	return res, nil
+}
+
+// RevokeSession отзывает сессию пользователя по id, либо все сессии, кроме текущей, при req.All
+func RevokeSession(req models.RevokeSessionRequest, user, current string, s models.Storable4Server) error {
+	if req.All {
+		return s.RevokeSessions(user, current)
+	}
+
+	session, err := s.GetSession(req.ID)
+	if err != nil {
+		return err
+	}
+	if session.ID == "" || session.User != user {
+		return models.ErrNotFound
+	}
+
+	return s.RevokeSession(req.ID, user)
+}
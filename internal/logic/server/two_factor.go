@@ -0,0 +1,171 @@
+package server_logic
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base32"
+	"encoding/hex"
+	"strings"
+	"time"
+
+	otp "github.com/azazel3ooo/keeper/internal/logic/otp"
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+const (
+	TOTPIssuer         = "Keeper"
+	RecoveryCodesCount = 10
+)
+
+// EnrollTOTP создает новый TOTP секрет для пользователя. Секрет начинает действовать только после ConfirmTOTP
+func EnrollTOTP(user string, s models.Storable4Server) (models.TOTPEnrollResponse, error) {
+	_, confirmed, err := s.GetTOTP(user)
+	if err != nil {
+		return models.TOTPEnrollResponse{}, err
+	}
+	if confirmed {
+		return models.TOTPEnrollResponse{}, models.ErrTOTPEnabled
+	}
+
+	login, err := s.GetLogin(user)
+	if err != nil {
+		return models.TOTPEnrollResponse{}, err
+	}
+
+	key, err := otp.NewKey(TOTPIssuer, login)
+	if err != nil {
+		return models.TOTPEnrollResponse{}, err
+	}
+
+	err = s.SetTOTP(user, key.Secret)
+	if err != nil {
+		return models.TOTPEnrollResponse{}, err
+	}
+
+	return models.TOTPEnrollResponse{Secret: key.Secret, URI: key.URI()}, nil
+}
+
+// ConfirmTOTP включает второй фактор после проверки кода из приложения-аутентификатора.
+// Возвращает коды восстановления, которые показываются пользователю один раз
+func ConfirmTOTP(user, code string, s models.Storable4Server) ([]string, error) {
+	secret, confirmed, err := s.GetTOTP(user)
+	if err != nil {
+		return nil, err
+	}
+	if secret == "" {
+		return nil, models.ErrTOTPNotEnrolled
+	}
+	if confirmed {
+		return nil, models.ErrTOTPEnabled
+	}
+
+	ok, err := useTOTPCode(user, secret, code, s)
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, models.ErrInvalidCode
+	}
+
+	codes, hashes, err := GenerateRecoveryCodes(RecoveryCodesCount)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.SetRecoveryCodes(user, hashes)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.ConfirmTOTP(user)
+	if err != nil {
+		return nil, err
+	}
+
+	return codes, nil
+}
+
+// CheckSecondFactor проверяет второй фактор пользователя. Для пользователей без подключенного TOTP всегда успешна.
+// В качестве code принимается одноразовый код или неиспользованный код восстановления
+func CheckSecondFactor(user, code string, s models.Storable4Server) error {
+	secret, confirmed, err := s.GetTOTP(user)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return nil
+	}
+	if code == "" {
+		return models.ErrSecondFactorRequired
+	}
+
+	ok, err := useTOTPCode(user, secret, code, s)
+	if err != nil || ok {
+		return err
+	}
+
+	ok, err = s.UseRecoveryCode(user, HashCode(code))
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return models.ErrInvalidCode
+	}
+
+	return nil
+}
+
+// DisableTOTP отключает второй фактор после проверки одноразового кода или кода восстановления
+func DisableTOTP(user, code string, s models.Storable4Server) error {
+	_, confirmed, err := s.GetTOTP(user)
+	if err != nil {
+		return err
+	}
+	if !confirmed {
+		return models.ErrTOTPNotEnrolled
+	}
+
+	err = CheckSecondFactor(user, code, s)
+	if err != nil {
+		return err
+	}
+
+	return s.DeleteTOTP(user)
+}
+
+// useTOTPCode проверяет одноразовый код и отмечает его период использованным, поэтому перехваченный код
+// нельзя принять повторно, пока он остается действительным
+func useTOTPCode(user, secret, code string, s models.Storable4Server) (bool, error) {
+	step, ok := otp.Key{Secret: secret}.Match(code, time.Now())
+	if !ok {
+		return false, nil
+	}
+
+	return s.UseTOTPStep(user, step)
+}
+
+// GenerateRecoveryCodes создает n кодов восстановления вида xxxxx-xxxxx и их хэши для хранения
+func GenerateRecoveryCodes(n int) (codes []string, hashes []string, err error) {
+	enc := base32.StdEncoding.WithPadding(base32.NoPadding)
+	for i := 0; i < n; i++ {
+		b := make([]byte, 7)
+		_, err = rand.Read(b)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(enc.EncodeToString(b))[:10]
+		code := raw[:5] + "-" + raw[5:]
+		codes = append(codes, code)
+		hashes = append(hashes, HashCode(code))
+	}
+
+	return codes, hashes, nil
+}
+
+// HashCode возвращает хэш кода восстановления. Регистр и разделители не учитываются
+func HashCode(code string) string {
+	normalized := strings.ToLower(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
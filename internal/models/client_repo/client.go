@@ -2,12 +2,17 @@ package client_repo
 
 import (
 	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/pb"
 )
 
 // Client структура клиента для отправки запросов к серверу и работы приложения клиента
 type Client struct {
 	cl    models.ClientHttpInterface // for testing
+	rpc   pb.KeeperClient            // задан при transport: grpc, тогда вход и операции с записями идут через gRPC API
 	store models.ClientStorable
-	cfg   models.Config
+	cfg   models.ClientConfig
 	token string
+
+	recoveryKey string // ключ восстановления, выданный при регистрации
+	sessionFile string // файл сохраненной сессии профиля, пустой - сессия не сохраняется
 }
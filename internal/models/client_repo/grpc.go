@@ -0,0 +1,268 @@
+package client_repo
+
+import (
+	"context"
+	"errors"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/pb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// rpcTimeout ограничение времени одного вызова gRPC API (кроме потока Watch)
+const rpcTimeout = 30 * time.Second
+
+// Пауза перед повторной подпиской WatchChanges растет вдвое от watchRetryMin до watchRetryMax и сбрасывается,
+// если подписка проработала дольше watchRetryMax
+const (
+	watchRetryMin = 500 * time.Millisecond
+	watchRetryMax = 30 * time.Second
+)
+
+// NewGRPCConn возвращает соединение с gRPC API сервера по адресу grpc_host. Сервер использует для gRPC те же
+// настройки TLS, что и для REST API, поэтому TLS включается, когда host задан со схемой https
+func NewGRPCConn(cfg models.ClientConfig) (*grpc.ClientConn, error) {
+	creds := insecure.NewCredentials()
+	if strings.HasPrefix(cfg.HostAddr, "https://") {
+		tlsCfg, err := cfg.TLS.ClientConfig()
+		if err != nil {
+			return nil, err
+		}
+		creds = credentials.NewTLS(tlsCfg)
+	}
+
+	return grpc.NewClient(cfg.GRPCAddr, grpc.WithTransportCredentials(creds))
+}
+
+// WithRPC переводит вход и операции с записями клиента на gRPC API через переданное соединение
+func WithRPC(conn grpc.ClientConnInterface) func(*Client) {
+	return func(c *Client) {
+		c.rpc = pb.NewKeeperClient(conn)
+	}
+}
+
+// getTokenRPC аналог GetToken для gRPC API
+func (c *Client) getTokenRPC(r models.UserRequest, addr string) error {
+	ctx, cancel := context.WithTimeout(context.Background(), rpcTimeout)
+	defer cancel()
+
+	call := c.rpc.Auth
+	if addr == c.cfg.RegAddr() {
+		call = c.rpc.Register
+	}
+
+	var trailer metadata.MD
+	res, err := call(ctx, pb.NewUserRequest(r), grpc.Trailer(&trailer))
+	if err != nil {
+		return rpcError(err, trailer)
+	}
+
+	c.UpdateToken(res.GetToken())
+	c.recoveryKey = res.GetRecoveryKey()
+
+	err = c.saveSession()
+	if err != nil {
+		log.Println("can't save session: " + err.Error())
+	}
+	return nil
+}
+
+// actionRPC аналог ActionToServer для gRPC API. Вызов выбирается по типу запроса и http методу
+func (c Client) actionRPC(r models.Validatable, method string) error {
+	ctx, cancel := c.rpcContext(context.Background())
+	defer cancel()
+
+	var err error
+	switch req := r.(type) {
+	case models.UserData:
+		if method == http.MethodPatch {
+			_, err = c.rpc.Update(ctx, pb.NewItem(req))
+		} else {
+			_, err = c.rpc.Set(ctx, pb.NewItem(req))
+		}
+	case models.DeleteRequest:
+		_, err = c.rpc.Delete(ctx, &pb.DeleteRequest{Id: req.ID})
+	case models.MoveRequest:
+		_, err = c.rpc.Move(ctx, &pb.MoveRequest{Ids: req.IDs, Folder: req.Folder})
+	default:
+		return models.ErrUncastable
+	}
+
+	return rpcError(err, nil)
+}
+
+// listRPC аналог GetActualData для gRPC API
+func (c Client) listRPC() ([]models.UserData, error) {
+	ctx, cancel := c.rpcContext(context.Background())
+	defer cancel()
+
+	res, err := c.rpc.List(ctx, &pb.ListRequest{})
+	if err != nil {
+		return nil, rpcError(err, nil)
+	}
+
+	data := make([]models.UserData, 0, len(res.GetItems()))
+	for _, el := range res.GetItems() {
+		data = append(data, el.UserData())
+	}
+	return data, nil
+}
+
+// WatchChanges подписывается на изменения записей, обновляет локальное хранилище с сервера и затем применяет к нему
+// каждое полученное изменение, передавая его в notify. Новые токены сессии из потока заменяют текущий и сохраняются
+// в файл сессии профиля. Если клиент не успевает за изменениями, подписка и обновление хранилища повторяются
+// после паузы. Работает до отмены ctx или ошибки потока (например, отзыва сессии)
+func (c *Client) WatchChanges(ctx context.Context, notify func(*pb.Change)) error {
+	if c.rpc == nil {
+		return models.ErrGRPCOnly
+	}
+
+	delay := watchRetryMin
+	for {
+		start := time.Now()
+		err := c.watch(ctx, notify)
+		if status.Code(err) != codes.Aborted {
+			if ctx.Err() != nil {
+				return nil
+			}
+			return rpcError(err, nil)
+		}
+
+		if time.Since(start) > watchRetryMax {
+			delay = watchRetryMin
+		}
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(delay):
+		}
+		delay = min(2*delay, watchRetryMax)
+	}
+}
+
+// watch выполняет одну подписку WatchChanges
+func (c *Client) watch(ctx context.Context, notify func(*pb.Change)) error {
+	ctx = metadata.AppendToOutgoingContext(ctx, pb.MetadataAuthorization, c.token)
+	stream, err := c.rpc.Watch(ctx, &pb.WatchRequest{})
+	if err != nil {
+		return err
+	}
+
+	// изменения, выполненные между подпиской и получением списка, применяются повторно, что безопасно
+	err = c.ActualizeStorage()
+	if err != nil {
+		return err
+	}
+
+	for {
+		change, err := stream.Recv()
+		if err != nil {
+			return err
+		}
+
+		if change.GetKind() == pb.Change_TOKEN {
+			c.UpdateToken(change.GetToken())
+			err = c.saveSession()
+			if err != nil {
+				log.Println("can't save session: " + err.Error())
+			}
+			continue
+		}
+
+		err = c.applyChange(change)
+		if err != nil {
+			return err
+		}
+		notify(change)
+	}
+}
+
+// applyChange применяет изменение из потока Watch к локальному хранилищу
+func (c Client) applyChange(change *pb.Change) error {
+	switch change.GetKind() {
+	case pb.Change_SET:
+		return c.store.Set(change.GetItem().UserData())
+
+	case pb.Change_UPDATE:
+		r := change.GetItem().UserData()
+		// изменение записи на сервере не переносит ее в другую папку, поэтому сохраняется локальная папка
+		data, err := c.store.GetAll()
+		if err != nil {
+			return err
+		}
+		for _, el := range data {
+			if el.ID == r.ID {
+				r.Folder = el.Folder
+				break
+			}
+		}
+		return c.store.Update(r)
+
+	case pb.Change_DELETE:
+		for _, id := range change.GetIds() {
+			err := c.store.Delete(models.DeleteRequest{ID: id})
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case pb.Change_MOVE:
+		return c.store.Move(models.MoveRequest{IDs: change.GetIds(), Folder: change.GetFolder()})
+	}
+
+	return nil
+}
+
+// rpcContext добавляет к контексту токен клиента и ограничение времени вызова
+func (c Client) rpcContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	ctx = metadata.AppendToOutgoingContext(ctx, pb.MetadataAuthorization, c.token)
+	return context.WithTimeout(ctx, rpcTimeout)
+}
+
+// rpcError возвращает ошибку, соответствующую статусу gRPC, так же, как statusError для http статусов.
+// trailer нужен для времени ожидания при превышении лимита попыток
+func rpcError(err error, trailer metadata.MD) error {
+	if err == nil {
+		return nil
+	}
+
+	switch status.Code(err) {
+	case codes.InvalidArgument:
+		return models.ErrBadRequest
+
+	case codes.PermissionDenied:
+		return models.ErrForbidden
+
+	case codes.Unauthenticated:
+		return models.ErrExpiredToken
+
+	case codes.AlreadyExists:
+		return models.ErrUserRegistrationConflict
+
+	case codes.FailedPrecondition:
+		return models.ErrSecondFactorRequired
+
+	case codes.ResourceExhausted:
+		var seconds int
+		if values := trailer.Get(pb.MetadataRetryAfter); len(values) > 0 {
+			seconds, _ = strconv.Atoi(values[0])
+		}
+		return &models.RateLimitError{RetryAfter: time.Duration(seconds) * time.Second}
+
+	case codes.Internal:
+		return models.ErrInternalServerError
+	}
+
+	return errors.New("grpc: " + status.Convert(err).Message())
+}
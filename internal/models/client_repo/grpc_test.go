@@ -0,0 +1,211 @@
+package client_repo
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	server_logic "github.com/azazel3ooo/keeper/internal/logic/server"
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/pb"
+	"github.com/azazel3ooo/keeper/internal/models/server_repo"
+	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestRPCServer запускает gRPC API сервера s в памяти. Клиенты подключаются к нему через newTestRPCClient
+func newTestRPCServer(t *testing.T, s *server_repo.Server) *bufconn.Listener {
+	err := s.SetupGRPC()
+	assert.Nil(t, err)
+
+	ln := bufconn.Listen(1 << 20)
+	go s.ServeGRPC(ln)
+	return ln
+}
+
+// newTestRPCClient возвращает клиент с локальной базой во временном каталоге, подключенный к серверу из newTestRPCServer
+func newTestRPCClient(t *testing.T, ln *bufconn.Listener) *Client {
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+	t.Cleanup(func() { conn.Close() })
+
+	var store ClientStorage
+	err = store.Init(filepath.Join(t.TempDir(), "client.db"))
+	assert.Nil(t, err)
+
+	return NewClient(WithStorage(&store), WithConfig(models.ClientConfig{HostAddr: "http://localhost"}), WithRPC(conn))
+}
+
+// setTokenTTL задает время жизни токенов сервера на время теста. Вызывается до запуска сервера, чтобы прежнее
+// значение восстанавливалось после его остановки
+func setTokenTTL(t *testing.T, ttl time.Duration) {
+	prev := server_logic.TokenTTL()
+	server_logic.SetTokenSettings("", ttl)
+	t.Cleanup(func() { server_logic.SetTokenSettings("", prev) })
+}
+
+func TestClient_grpc(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	procChan := make(server_repo.ProcessingChan, 10)
+	s := server_repo.NewServer(server_repo.WithStorage(store), server_repo.WithProcessingChan(procChan))
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.ProcessingWatcher(&wg)
+	defer func() {
+		s.Shutdown()
+		close(procChan)
+		wg.Wait()
+	}()
+
+	ln := newTestRPCServer(t, s)
+	c := newTestRPCClient(t, ln)
+	other := newTestRPCClient(t, ln)
+
+	err := c.GetToken(models.UserRequest{Login: "q", Password: "q"}, c.cfg.RegAddr())
+	assert.Nil(t, err)
+	assert.NotEmpty(t, c.recoveryKey)
+	assert.ErrorIs(t, other.GetToken(models.UserRequest{Login: "q", Password: "w"}, other.cfg.AuthAddr()),
+		models.ErrForbidden)
+	assert.Nil(t, other.GetToken(models.UserRequest{Login: "q", Password: "q"}, other.cfg.AuthAddr()))
+
+	err = c.ActionToServer(models.UserData{ID: "1", Data: "data"}, c.ActionAddr(), http.MethodPost)
+	assert.Nil(t, err)
+	assert.ErrorIs(t, c.ActionToServer(models.UserData{}, c.ActionAddr(), http.MethodPost), models.ErrBadRequest)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	changes := make(chan *pb.Change, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- c.WatchChanges(ctx, func(change *pb.Change) { changes <- change })
+	}()
+
+	// запись, сохраненная до подписки, попадает в локальную базу при обновлении хранилища
+	assert.Eventually(t, func() bool {
+		data, _ := c.GetAll()
+		return len(data) == 1
+	}, time.Second, 10*time.Millisecond)
+
+	err = other.ActionToServer(models.UserData{ID: "2", Data: "data"}, other.ActionAddr(), http.MethodPost)
+	assert.Nil(t, err)
+	err = other.ActionToServer(models.DeleteRequest{ID: "1"}, other.ActionAddr(), http.MethodDelete)
+	assert.Nil(t, err)
+
+	for _, kind := range []pb.Change_Kind{pb.Change_SET, pb.Change_DELETE} {
+		select {
+		case change := <-changes:
+			assert.Equal(t, kind, change.GetKind())
+		case <-ctx.Done():
+			t.Fatal("change not received")
+		}
+	}
+
+	data, err := c.GetAll()
+	assert.Nil(t, err)
+	assert.Len(t, data, 1)
+	assert.Equal(t, "2", data[0].ID)
+
+	cancel()
+	assert.Nil(t, <-done)
+}
+
+func TestClient_WatchChangesTokenRefresh(t *testing.T) {
+	setTokenTTL(t, 2*time.Second)
+
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	procChan := make(server_repo.ProcessingChan, 10)
+	defer close(procChan)
+	s := server_repo.NewServer(server_repo.WithStorage(store), server_repo.WithProcessingChan(procChan))
+	defer s.Shutdown()
+
+	c := newTestRPCClient(t, newTestRPCServer(t, s))
+	sessionFile := filepath.Join(t.TempDir(), "session")
+	WithSessionFile(sessionFile)(c)
+
+	err := c.GetToken(models.UserRequest{Login: "q", Password: "q"}, c.cfg.RegAddr())
+	assert.Nil(t, err)
+	initial, _ := os.ReadFile(sessionFile)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 3*time.Second)
+	defer cancel()
+	// поток не завершается с истечением токена, с которым был открыт, а работает до отмены ctx
+	err = c.WatchChanges(ctx, func(*pb.Change) {})
+	assert.Nil(t, err)
+
+	refreshed, _ := os.ReadFile(sessionFile)
+	assert.NotEqual(t, string(initial), string(refreshed))
+	_, _, err = server_logic.CheckToken(string(refreshed), store)
+	assert.Nilf(t, err, "saved token is valid after the initial one expired")
+}
+
+// abortingWatch сервер, сразу обрывающий каждую подписку как отстающую
+type abortingWatch struct {
+	pb.KeeperClient
+	calls int
+}
+
+func (a *abortingWatch) Watch(context.Context, *pb.WatchRequest, ...grpc.CallOption) (pb.Keeper_WatchClient, error) {
+	a.calls++
+	return nil, status.Error(codes.Aborted, "too slow")
+}
+
+func TestClient_WatchChangesBackoff(t *testing.T) {
+	rpc := &abortingWatch{}
+	c := NewClient()
+	c.rpc = rpc
+
+	ctx, cancel := context.WithTimeout(context.Background(), 1200*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := c.WatchChanges(ctx, func(*pb.Change) {})
+	assert.Nil(t, err)
+
+	// подписки в 0, 0.5 и 1.5 секунды: последняя уже после отмены ctx
+	assert.Equal(t, 2, rpc.calls)
+	assert.Less(t, time.Since(start), 1500*time.Millisecond)
+}
+
+func TestClient_WatchChangesREST(t *testing.T) {
+	c := NewClient()
+	assert.ErrorIs(t, c.WatchChanges(context.Background(), func(*pb.Change) {}), models.ErrGRPCOnly)
+}
+
+func Test_rpcError(t *testing.T) {
+	var limitErr *models.RateLimitError
+	err := rpcError(status.Error(codes.ResourceExhausted, "limit"), metadata.Pairs(pb.MetadataRetryAfter, "30"))
+	assert.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, 30*time.Second, limitErr.RetryAfter)
+
+	tests := []struct {
+		code     codes.Code
+		expected error
+	}{
+		{codes.InvalidArgument, models.ErrBadRequest},
+		{codes.PermissionDenied, models.ErrForbidden},
+		{codes.Unauthenticated, models.ErrExpiredToken},
+		{codes.AlreadyExists, models.ErrUserRegistrationConflict},
+		{codes.FailedPrecondition, models.ErrSecondFactorRequired},
+		{codes.Internal, models.ErrInternalServerError},
+	}
+	for _, tt := range tests {
+		assert.ErrorIsf(t, rpcError(status.Error(tt.code, ""), nil), tt.expected, tt.code.String())
+	}
+	assert.Nil(t, rpcError(nil, nil))
+}
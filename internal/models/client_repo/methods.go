@@ -1,6 +1,9 @@
 package client_repo
 
 import (
+	"net/http"
+	"os"
+
 	"github.com/azazel3ooo/keeper/internal/models"
 )
 
@@ -14,6 +17,19 @@ func NewClient(opts ...func(client *Client)) *Client {
 	return c
 }
 
+// NewHTTPClient возвращает http.Client, выполняющий запросы с переданными настройками TLS
+func NewHTTPClient(cfg models.TLSConfig) (*http.Client, error) {
+	tlsCfg, err := cfg.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = tlsCfg
+
+	return &http.Client{Transport: transport}, nil
+}
+
 // WithStorage добавляет переданный models.ClientStorable для клиента
 func WithStorage(store models.ClientStorable) func(*Client) {
 	return func(c *Client) {
@@ -21,8 +37,8 @@ func WithStorage(store models.ClientStorable) func(*Client) {
 	}
 }
 
-// WithConfig добавляет переданный models.Config для клиента
-func WithConfig(cfg models.Config) func(*Client) {
+// WithConfig добавляет переданный models.ClientConfig для клиента
+func WithConfig(cfg models.ClientConfig) func(*Client) {
 	return func(c *Client) {
 		c.cfg = cfg
 	}
@@ -35,11 +51,43 @@ func WithClient(cl models.ClientHttpInterface) func(*Client) {
 	}
 }
 
+// WithSessionFile включает сохранение токена в файл, чтобы следующий запуск клиента не требовал входа
+func WithSessionFile(path string) func(*Client) {
+	return func(c *Client) {
+		c.sessionFile = path
+	}
+}
+
 // UpdateToken обновляет токен клиента
 func (c *Client) UpdateToken(newToken string) {
 	c.token = newToken
 }
 
+// RecoveryKey возвращает ключ восстановления, полученный при регистрации. Ключ отдается один раз
+func (c *Client) RecoveryKey() string {
+	key := c.recoveryKey
+	c.recoveryKey = ""
+	return key
+}
+
+// DeviceName возвращает имя устройства, под которым клиент регистрирует сессию на сервере
+func (c Client) DeviceName() string {
+	if c.cfg.Device != "" {
+		return c.cfg.Device
+	}
+
+	host, err := os.Hostname()
+	if err != nil {
+		return "unknown"
+	}
+	return host
+}
+
+// BreachListPath возвращает путь к локальному файлу утекших паролей, пустой, если он не задан
+func (c Client) BreachListPath() string {
+	return c.cfg.BreachList
+}
+
 // ReadyForActions проверяет, что клиент готов к работе (токен не пустой)
 func (c Client) ReadyForActions() bool {
 	if c.token == "" {
@@ -64,6 +112,11 @@ func (c Client) ActionAddr() string {
 	return c.cfg.ActionAddr()
 }
 
+// MoveAddr возвращает адрес для перемещения записей между папками
+func (c Client) MoveAddr() string {
+	return c.cfg.MoveAddr()
+}
+
 // SetLocal производит cast интерфейса в необходимый тип и выполняет метод хранилища с преобразованной структурой
 func (c Client) SetLocal(request models.Validatable) error {
 	r, ok := request.(models.UserData)
@@ -94,6 +147,21 @@ func (c Client) DeleteLocal(request models.Validatable) error {
 	return c.store.Delete(r)
 }
 
+// MoveLocal производит cast интерфейса в необходимый тип и выполняет метод хранилища с преобразованной структурой
+func (c Client) MoveLocal(request models.Validatable) error {
+	r, ok := request.(models.MoveRequest)
+	if !ok {
+		return models.ErrUncastable
+	}
+
+	return c.store.Move(r)
+}
+
+// ClearLocal удаляет все записи из локального хранилища
+func (c Client) ClearLocal() error {
+	return c.store.Clear()
+}
+
 // GetAll обертка над методом хранилища для получения полного списка данных
 func (c Client) GetAll() ([]models.UserData, error) {
 	return c.store.GetAll()
@@ -0,0 +1,152 @@
+package client_repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// ProfileConfigFile файл настроек в каталоге профиля
+	ProfileConfigFile = "client_settings.yml"
+
+	profileSessionFile = "session"
+	profileKeyFile     = "key"
+)
+
+var profileName = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// Profile каталог профиля клиента с собственными настройками, зашифрованной локальной базой и сохраненной сессией
+type Profile struct {
+	Name string
+	Dir  string
+}
+
+// ProfilesDir возвращает каталог профилей в каталоге настроек пользователя (например, ~/.config/keeper/profiles)
+func ProfilesDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+
+	return filepath.Join(dir, "keeper", "profiles"), nil
+}
+
+// OpenProfile возвращает профиль с переданным именем, создавая его каталог с доступом только для владельца
+func OpenProfile(name string) (Profile, error) {
+	if !profileName.MatchString(name) {
+		return Profile{}, models.ErrInvalidProfile
+	}
+
+	dir, err := ProfilesDir()
+	if err != nil {
+		return Profile{}, err
+	}
+
+	p := Profile{Name: name, Dir: filepath.Join(dir, name)}
+	err = os.MkdirAll(p.Dir, 0700)
+	if err != nil {
+		return Profile{}, err
+	}
+
+	return p, nil
+}
+
+// ListProfiles возвращает имена созданных профилей
+func ListProfiles() ([]string, error) {
+	dir, err := ProfilesDir()
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	for _, el := range entries {
+		if el.IsDir() && profileName.MatchString(el.Name()) {
+			names = append(names, el.Name())
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// ConfigFile возвращает путь к файлу настроек профиля
+func (p Profile) ConfigFile() string {
+	return filepath.Join(p.Dir, ProfileConfigFile)
+}
+
+// SessionFile возвращает путь к файлу сохраненной сессии профиля
+func (p Profile) SessionFile() string {
+	return filepath.Join(p.Dir, profileSessionFile)
+}
+
+// KeyFile возвращает путь к файлу ключа локальной базы профиля, зашифрованного паролем профиля
+func (p Profile) KeyFile() string {
+	return filepath.Join(p.Dir, profileKeyFile)
+}
+
+// Save записывает настройки в файл профиля. Путь к базе сохраняется как есть, относительный путь считается от каталога профиля
+func (p Profile) Save(cfg models.ClientConfig) error {
+	cfg.Profile = ""
+	b, err := yaml.Marshal(cfg)
+	if err != nil {
+		return err
+	}
+
+	return os.WriteFile(p.ConfigFile(), b, 0600)
+}
+
+// Resolve размещает локальную базу с относительным путем (в том числе по умолчанию) в каталоге профиля
+func (p Profile) Resolve(cfg *models.ClientConfig) {
+	if !filepath.IsAbs(cfg.DbLocation) {
+		cfg.DbLocation = filepath.Join(p.Dir, cfg.DbLocation)
+	}
+}
+
+// RestoreSession загружает сохраненный токен и проверяет его на сервере. Возвращает false, если нужно войти заново.
+// Отклоненный сервером токен удаляется
+func (c *Client) RestoreSession() bool {
+	if c.sessionFile == "" {
+		return false
+	}
+
+	b, err := os.ReadFile(c.sessionFile)
+	if err != nil {
+		return false
+	}
+
+	c.UpdateToken(strings.TrimSpace(string(b)))
+	_, err = c.GetSessions()
+	if err != nil {
+		c.UpdateToken("")
+		if errors.Is(err, models.ErrExpiredToken) || errors.Is(err, models.ErrForbidden) {
+			os.Remove(c.sessionFile)
+		}
+		return false
+	}
+
+	return true
+}
+
+// saveSession сохраняет токен, если для клиента задан файл сессии
+func (c Client) saveSession() error {
+	if c.sessionFile == "" || c.token == "" {
+		return nil
+	}
+
+	return os.WriteFile(c.sessionFile, []byte(c.token), 0600)
+}
@@ -0,0 +1,92 @@
+package client_repo
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	server_logic "github.com/azazel3ooo/keeper/internal/logic/server"
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/server_repo"
+	"github.com/azazel3ooo/keeper/internal/models/testing_repos_client"
+	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenProfile(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+
+	for _, name := range []string{"work", "home"} {
+		p, err := OpenProfile(name)
+		assert.Nil(t, err)
+
+		info, err := os.Stat(p.Dir)
+		assert.Nil(t, err)
+		assert.Equal(t, os.FileMode(0700), info.Mode().Perm())
+	}
+
+	_, err := OpenProfile("../work")
+	assert.ErrorIs(t, err, models.ErrInvalidProfile)
+
+	names, err := ListProfiles()
+	assert.Nil(t, err)
+	assert.Equal(t, []string{"home", "work"}, names)
+}
+
+func TestProfile_SaveResolve(t *testing.T) {
+	t.Setenv("XDG_CONFIG_HOME", t.TempDir())
+	p, err := OpenProfile("work")
+	assert.Nil(t, err)
+
+	err = p.Save(models.ClientConfig{Profile: "work", HostAddr: "https://keeper.example.com", DbLocation: "client.db"})
+	assert.Nil(t, err)
+
+	var cfg models.ClientConfig
+	_, err = models.LoadConfig(&cfg, "client", p.ConfigFile(), nil)
+	assert.Nil(t, err)
+	p.Resolve(&cfg)
+	assert.Equal(t, "https://keeper.example.com", cfg.HostAddr)
+	assert.Equal(t, filepath.Join(p.Dir, "client.db"), cfg.DbLocation)
+
+	// абсолютный путь к базе не меняется
+	cfg.DbLocation = "/var/lib/keeper/client.db"
+	p.Resolve(&cfg)
+	assert.Equal(t, "/var/lib/keeper/client.db", cfg.DbLocation)
+}
+
+func TestClient_RestoreSession(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := server_repo.NewServer(server_repo.WithStorage(store))
+	s.SetupApp()
+
+	store.CreateSession(models.Session{ID: "session", User: "tmp"})
+	testToken, _ := server_logic.GenerateToken("tmp", "session", 5.0)
+	expiredToken, _ := server_logic.GenerateToken("tmp", "session", 0.0)
+
+	tests := []struct {
+		description string
+		cached      string
+		restored    bool
+		kept        bool
+	}{
+		{description: "valid session", cached: testToken, restored: true, kept: true},
+		{description: "expired session is removed", cached: expiredToken},
+		{description: "no session"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.description, func(t *testing.T) {
+			file := filepath.Join(t.TempDir(), "session")
+			if tt.cached != "" {
+				assert.Nil(t, os.WriteFile(file, []byte(tt.cached), 0600))
+			}
+
+			c := NewClient(WithClient(testing_repos_client.TestingClient{S: *s}), WithSessionFile(file))
+			assert.Equal(t, tt.restored, c.RestoreSession())
+			assert.Equal(t, tt.restored, c.ReadyForActions())
+
+			_, err := os.Stat(file)
+			assert.Equal(t, tt.kept, err == nil)
+		})
+	}
+}
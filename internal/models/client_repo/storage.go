@@ -1,17 +1,27 @@
 package client_repo
 
 import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
 	"database/sql"
+	"encoding/base64"
+	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"strings"
 
 	"github.com/azazel3ooo/keeper/internal/models"
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// encryptedPrefix отмечает зашифрованные значения в локальной базе
+const encryptedPrefix = "enc1:"
+
 type ClientStorage struct {
-	d *sql.DB
+	d    *sql.DB
+	aead cipher.AEAD
 }
 
 func (c *ClientStorage) Init(path string) error {
@@ -45,11 +55,32 @@ func (c *ClientStorage) Init(path string) error {
 	return nil
 }
 
+// InitEncrypted открывает локальную базу, в которой содержимое и описание записей шифруются ключом key (AES-256-GCM).
+// Записи, сохраненные до включения шифрования, шифруются при открытии
+func (c *ClientStorage) InitEncrypted(path string, key []byte) error {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return err
+	}
+	c.aead, err = cipher.NewGCM(block)
+	if err != nil {
+		return err
+	}
+
+	err = c.Init(path)
+	if err != nil {
+		return err
+	}
+
+	return c.encryptPlain()
+}
+
 func (c *ClientStorage) CreateTables() error {
 	stmt := `CREATE TABLE if not exists storage (
     	"id" TEXT PRIMARY key,
     	"data" TEXT,
-    	"comment" TEXT
+    	"comment" TEXT,
+    	"folder" TEXT
 	);`
 
 	_, err := c.d.Exec(stmt)
@@ -57,18 +88,30 @@ func (c *ClientStorage) CreateTables() error {
 		return err
 	}
 
-	return nil
+	// базы, созданные до появления папок
+	var n int
+	err = c.d.QueryRow(`select COUNT(*) from pragma_table_info('storage') where name='folder'`).Scan(&n)
+	if err != nil || n > 0 {
+		return err
+	}
+	_, err = c.d.Exec(`alter table storage add column "folder" TEXT`)
+	return err
 }
 
 func (c *ClientStorage) Set(r models.UserData) error {
-	stmt := `insert or replace into storage (id,"data",comment) values($1,$2,$3);`
+	stmt := `insert or replace into storage (id,"data",comment,folder) values($1,$2,$3,$4);`
 
-	_, err := c.d.Exec(stmt, r.ID, r.Data, r.Comment)
+	data, comment, err := c.seal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.d.Exec(stmt, r.ID, data, comment, r.Folder)
 	return err
 }
 
 func (c *ClientStorage) GetAll() ([]models.UserData, error) {
-	stmt := `select id, "data", comment from storage`
+	stmt := `select id, "data", comment, IFNULL(folder,'') from storage`
 
 	rows, err := c.d.Query(stmt)
 	if err != nil {
@@ -84,7 +127,10 @@ func (c *ClientStorage) GetAll() ([]models.UserData, error) {
 		res []models.UserData
 	)
 	for rows.Next() {
-		err = rows.Scan(&tmp.ID, &tmp.Data, &tmp.Comment)
+		err = rows.Scan(&tmp.ID, &tmp.Data, &tmp.Comment, &tmp.Folder)
+		if err == nil {
+			err = c.open(&tmp)
+		}
 		if err != nil {
 			log.Println(err)
 			continue
@@ -97,15 +143,151 @@ func (c *ClientStorage) GetAll() ([]models.UserData, error) {
 }
 
 func (c *ClientStorage) Update(r models.UserData) error {
-	stmt := `insert or replace into storage (id,"data",comment) values($1,$2,$3);`
+	stmt := `insert or replace into storage (id,"data",comment,folder) values($1,$2,$3,$4);`
 
-	_, err := c.d.Exec(stmt, r.ID, r.Data, r.Comment)
+	data, comment, err := c.seal(r)
+	if err != nil {
+		return err
+	}
+
+	_, err = c.d.Exec(stmt, r.ID, data, comment, r.Folder)
+	return err
+}
+
+func (c *ClientStorage) Clear() error {
+	stmt := `delete from storage`
+
+	_, err := c.d.Exec(stmt)
 	return err
 }
 
+// Delete удаляет запись. Как и на сервере, содержимое удаленной папки переносится в родительскую папку
 func (c *ClientStorage) Delete(r models.DeleteRequest) error {
-	stmt := `delete from storage where id=$1`
+	stmt := `update storage set folder=(select folder from storage where id=$1) where folder=$1`
 
 	_, err := c.d.Exec(stmt, r.ID)
+	if err != nil {
+		return err
+	}
+
+	stmt = `delete from storage where id=$1`
+
+	_, err = c.d.Exec(stmt, r.ID)
 	return err
 }
+
+func (c *ClientStorage) Move(r models.MoveRequest) error {
+	stmt := `update storage set folder=$1 where id=$2`
+
+	for _, id := range r.IDs {
+		_, err := c.d.Exec(stmt, r.Folder, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// encryptPlain шифрует записи, сохраненные в базе без шифрования
+func (c *ClientStorage) encryptPlain() error {
+	rows, err := c.d.Query(`select id, IFNULL("data",''), IFNULL(comment,'') from storage where IFNULL("data",'') not like $1`,
+		encryptedPrefix+"%")
+	if err != nil {
+		return err
+	}
+
+	var plain []models.UserData
+	for rows.Next() {
+		var tmp models.UserData
+		err = rows.Scan(&tmp.ID, &tmp.Data, &tmp.Comment)
+		if err != nil {
+			rows.Close()
+			return err
+		}
+		plain = append(plain, tmp)
+	}
+	rows.Close()
+	if rows.Err() != nil {
+		return rows.Err()
+	}
+
+	for _, r := range plain {
+		data, comment, err := c.seal(r)
+		if err != nil {
+			return err
+		}
+		_, err = c.d.Exec(`update storage set "data"=$1, comment=$2 where id=$3`, data, comment, r.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	if len(plain) == 0 {
+		return nil
+	}
+
+	// освобожденные страницы базы еще содержат открытые значения
+	_, err = c.d.Exec(`VACUUM`)
+	return err
+}
+
+// seal возвращает содержимое и описание записи для сохранения в базе. Шифротекст привязан к id записи и столбцу,
+// поэтому значения нельзя переставить между записями
+func (c *ClientStorage) seal(r models.UserData) (string, string, error) {
+	if c.aead == nil {
+		return r.Data, r.Comment, nil
+	}
+
+	data, err := c.encrypt(r.ID, "data", r.Data)
+	if err != nil {
+		return "", "", err
+	}
+	comment, err := c.encrypt(r.ID, "comment", r.Comment)
+	return data, comment, err
+}
+
+// open расшифровывает содержимое и описание прочитанной из базы записи
+func (c *ClientStorage) open(r *models.UserData) error {
+	if c.aead == nil {
+		return nil
+	}
+
+	var err error
+	r.Data, err = c.decrypt(r.ID, "data", r.Data)
+	if err != nil {
+		return err
+	}
+	r.Comment, err = c.decrypt(r.ID, "comment", r.Comment)
+	return err
+}
+
+func (c *ClientStorage) encrypt(id, column, value string) (string, error) {
+	nonce := make([]byte, c.aead.NonceSize())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return "", err
+	}
+
+	sealed := c.aead.Seal(nonce, nonce, []byte(value), []byte(id+"/"+column))
+	return encryptedPrefix + base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+func (c *ClientStorage) decrypt(id, column, value string) (string, error) {
+	if !strings.HasPrefix(value, encryptedPrefix) {
+		return "", fmt.Errorf("%w: %s", models.ErrCorruptedRecord, id)
+	}
+
+	sealed, err := base64.StdEncoding.DecodeString(strings.TrimPrefix(value, encryptedPrefix))
+	if err != nil || len(sealed) < c.aead.NonceSize() {
+		return "", fmt.Errorf("%w: %s", models.ErrCorruptedRecord, id)
+	}
+
+	n := c.aead.NonceSize()
+	plain, err := c.aead.Open(nil, sealed[:n], sealed[n:], []byte(id+"/"+column))
+	if err != nil {
+		return "", fmt.Errorf("%w: %s", models.ErrCorruptedRecord, id)
+	}
+
+	return string(plain), nil
+}
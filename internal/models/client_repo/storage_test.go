@@ -0,0 +1,60 @@
+package client_repo
+
+import (
+	"bytes"
+	"database/sql"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClientStorage_InitEncrypted(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "client.db")
+	key := bytes.Repeat([]byte{1}, 32)
+
+	// запись, сохраненная до включения шифрования
+	var plain ClientStorage
+	err := plain.Init(path)
+	assert.Nil(t, err)
+	err = plain.Set(models.UserData{ID: "1", Data: "legacy secret", Comment: "old note"})
+	assert.Nil(t, err)
+
+	var s ClientStorage
+	err = s.InitEncrypted(path, key)
+	assert.Nil(t, err)
+	err = s.Set(models.UserData{ID: "2", Data: "octocat secret", Comment: "GitHub", Folder: "1"})
+	assert.Nil(t, err)
+
+	records, err := s.GetAll()
+	assert.Nil(t, err)
+	assert.ElementsMatch(t, []models.UserData{
+		{ID: "1", Data: "legacy secret", Comment: "old note"},
+		{ID: "2", Data: "octocat secret", Comment: "GitHub", Folder: "1"},
+	}, records)
+
+	b, err := os.ReadFile(path)
+	assert.Nil(t, err)
+	for _, secret := range []string{"legacy secret", "octocat secret", "GitHub"} {
+		assert.Equalf(t, false, bytes.Contains(b, []byte(secret)), "%s is encrypted", secret)
+	}
+
+	// значения, переставленные между записями или записанные без ключа, не читаются
+	db, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	defer db.Close()
+	_, err = db.Exec(`update storage set "data"=(select "data" from storage where id='2') where id='1'`)
+	assert.Nil(t, err)
+	_, err = db.Exec(`insert into storage (id,"data",comment) values('3','injected','')`)
+	assert.Nil(t, err)
+
+	records, err = s.GetAll()
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UserData{{ID: "2", Data: "octocat secret", Comment: "GitHub", Folder: "1"}}, records)
+
+	var wrong ClientStorage
+	err = wrong.InitEncrypted(filepath.Join(t.TempDir(), "client.db"), []byte("short"))
+	assert.NotNil(t, err)
+}
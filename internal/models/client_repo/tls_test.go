@@ -0,0 +1,153 @@
+package client_repo
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/server_repo"
+	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
+	"github.com/stretchr/testify/assert"
+)
+
+// testCert самоподписанный CA или выпущенный им сертификат, сохраненный в PEM файлы
+type testCert struct {
+	cert     *x509.Certificate
+	key      *ecdsa.PrivateKey
+	certFile string
+	keyFile  string
+}
+
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage) testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.Nil(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+
+	signer, signerKey := tmpl, key
+	if parent == nil {
+		tmpl.IsCA = true
+		tmpl.BasicConstraintsValid = true
+		tmpl.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, signer, &key.PublicKey, signerKey)
+	assert.Nil(t, err)
+	cert, err := x509.ParseCertificate(der)
+	assert.Nil(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.Nil(t, err)
+
+	dir := t.TempDir()
+	res := testCert{
+		cert:     cert,
+		key:      key,
+		certFile: filepath.Join(dir, name+".crt"),
+		keyFile:  filepath.Join(dir, name+".key"),
+	}
+	err = os.WriteFile(res.certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600)
+	assert.Nil(t, err)
+	err = os.WriteFile(res.keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600)
+	assert.Nil(t, err)
+
+	return res
+}
+
+func TestNewHTTPClient_MutualTLS(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	otherCA := newTestCert(t, "other_ca", nil, x509.ExtKeyUsageAny)
+	serverCert := newTestCert(t, "server", &ca, x509.ExtKeyUsageServerAuth)
+	clientCert := newTestCert(t, "client", &ca, x509.ExtKeyUsageClientAuth)
+	foreignClient := newTestCert(t, "foreign", &otherCA, x509.ExtKeyUsageClientAuth)
+
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := server_repo.NewServer(
+		server_repo.WithStorage(store),
+		server_repo.WithConfig(models.ServerConfig{TLS: models.TLSConfig{
+			CertFile:     serverCert.certFile,
+			KeyFile:      serverCert.keyFile,
+			ClientCAFile: ca.certFile,
+		}}),
+	)
+	s.SetupApp()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go s.Serve(ln)
+	defer s.Shutdown()
+
+	tests := []struct {
+		description string
+		cfg         models.TLSConfig
+		wantErr     bool
+	}{
+		{
+			description: "pinned ca and client certificate",
+			cfg:         models.TLSConfig{CAFile: ca.certFile, CertFile: clientCert.certFile, KeyFile: clientCert.keyFile},
+			wantErr:     false,
+		},
+		{
+			description: "without client certificate",
+			cfg:         models.TLSConfig{CAFile: ca.certFile},
+			wantErr:     true,
+		},
+		{
+			description: "client certificate from unknown ca",
+			cfg:         models.TLSConfig{CAFile: ca.certFile, CertFile: foreignClient.certFile, KeyFile: foreignClient.keyFile},
+			wantErr:     true,
+		},
+		{
+			description: "server is not signed by pinned ca",
+			cfg:         models.TLSConfig{CAFile: otherCA.certFile, CertFile: clientCert.certFile, KeyFile: clientCert.keyFile},
+			wantErr:     true,
+		},
+	}
+	for _, tt := range tests {
+		cl, err := NewHTTPClient(tt.cfg)
+		assert.Nilf(t, err, tt.description)
+
+		resp, err := cl.Get("https://" + ln.Addr().String() + "/api/v1/items")
+		assert.Equalf(t, tt.wantErr, err != nil, tt.description)
+		if err == nil {
+			// запрос без токена, важно только то, что TLS соединение установлено
+			assert.Equalf(t, http.StatusForbidden, resp.StatusCode, tt.description)
+			resp.Body.Close()
+		}
+	}
+}
+
+func TestTLSConfig_MinVersion(t *testing.T) {
+	ca := newTestCert(t, "ca", nil, x509.ExtKeyUsageAny)
+	serverCert := newTestCert(t, "server", &ca, x509.ExtKeyUsageServerAuth)
+
+	_, err := models.TLSConfig{CertFile: serverCert.certFile, KeyFile: serverCert.keyFile, MinVersion: "1.1"}.ServerConfig()
+	assert.Equal(t, models.ErrInvalidTLSVersion, err)
+
+	_, err = models.TLSConfig{CertFile: serverCert.certFile}.ServerConfig()
+	assert.Equal(t, models.ErrIncompleteKeyPair, err)
+
+	_, err = models.TLSConfig{CAFile: serverCert.keyFile}.ClientConfig()
+	assert.Equal(t, models.ErrNoCertificates, err)
+}
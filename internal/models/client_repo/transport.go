@@ -7,12 +7,25 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"runtime"
+	"strconv"
+	"time"
 
 	"github.com/azazel3ooo/keeper/internal/models"
 )
 
 // GetToken производит авторизацию\регистрацию пользователя и обновляет токен клиента
 func (c *Client) GetToken(r models.UserRequest, addr string) error {
+	if r.Device == "" {
+		r.Device = c.DeviceName()
+	}
+	if r.OS == "" {
+		r.OS = runtime.GOOS
+	}
+	if c.rpc != nil {
+		return c.getTokenRPC(r, addr)
+	}
+
 	s, err := json.Marshal(r)
 	if err != nil {
 		return err
@@ -46,6 +59,15 @@ func (c *Client) GetToken(r models.UserRequest, addr string) error {
 			return err
 		}
 		c.UpdateToken(res.Token)
+		c.recoveryKey = res.RecoveryKey
+
+		err = c.saveSession()
+		if err != nil {
+			log.Println("can't save session: " + err.Error())
+		}
+
+	case http.StatusUnauthorized:
+		return models.ErrSecondFactorRequired
 
 	case http.StatusForbidden:
 		log.Println(string(b))
@@ -59,6 +81,9 @@ func (c *Client) GetToken(r models.UserRequest, addr string) error {
 		log.Println(string(b))
 		return models.ErrUserRegistrationConflict
 
+	case http.StatusTooManyRequests:
+		return rateLimitError(resp)
+
 	case http.StatusInternalServerError:
 		log.Println(string(b))
 		return models.ErrInternalServerError
@@ -72,6 +97,10 @@ func (c *Client) GetToken(r models.UserRequest, addr string) error {
 
 // ActionToServer отправляет запрос с необходимым действием на сервер
 func (c Client) ActionToServer(r models.Validatable, addr, method string) error {
+	if c.rpc != nil {
+		return c.actionRPC(r, method)
+	}
+
 	s, err := json.Marshal(r)
 	if err != nil {
 		return err
@@ -111,6 +140,10 @@ func (c Client) ActionToServer(r models.Validatable, addr, method string) error
 
 // GetActualData получает все записи клиента с сервера
 func (c Client) GetActualData() ([]models.UserData, error) {
+	if c.rpc != nil {
+		return c.listRPC()
+	}
+
 	req, err := http.NewRequest(http.MethodGet, c.ActionAddr(), nil)
 	if err != nil {
 		return nil, err
@@ -149,3 +182,317 @@ func (c Client) GetActualData() ([]models.UserData, error) {
 		return nil, errors.New("unknown status " + resp.Status)
 	}
 }
+
+// EnrollTOTP запрашивает у сервера новый TOTP секрет для подключения второго фактора
+func (c Client) EnrollTOTP() (models.TOTPEnrollResponse, error) {
+	var res models.TOTPEnrollResponse
+
+	resp, err := c.authorizedRequest(http.MethodPost, c.cfg.TOTPEnrollAddr(), nil)
+	if err != nil {
+		return res, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		return res, err
+
+	case http.StatusConflict:
+		return res, models.ErrTOTPEnabled
+	}
+
+	return res, statusError(resp)
+}
+
+// ConfirmTOTP подтверждает подключение второго фактора одноразовым кодом и возвращает коды восстановления
+func (c Client) ConfirmTOTP(code string) ([]string, error) {
+	resp, err := c.authorizedRequest(http.MethodPost, c.cfg.TOTPConfirmAddr(), models.TOTPConfirmRequest{Code: code})
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var res models.TOTPConfirmResponse
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		if err != nil {
+			return nil, err
+		}
+		return res.RecoveryCodes, nil
+
+	case http.StatusForbidden:
+		return nil, models.ErrInvalidCode
+
+	case http.StatusConflict:
+		return nil, models.ErrTOTPEnabled
+	}
+
+	return nil, statusError(resp)
+}
+
+// DisableTOTP отключает второй фактор. code - текущий одноразовый код или код восстановления
+func (c Client) DisableTOTP(code string) error {
+	resp, err := c.authorizedRequest(http.MethodPost, c.cfg.TOTPDisableAddr(), models.TOTPConfirmRequest{Code: code})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+
+	case http.StatusForbidden:
+		return models.ErrInvalidCode
+
+	case http.StatusConflict:
+		return models.ErrTOTPNotEnrolled
+	}
+
+	return statusError(resp)
+}
+
+// GetSessions получает список активных сессий пользователя
+func (c Client) GetSessions() ([]models.Session, error) {
+	resp, err := c.authorizedRequest(http.MethodGet, c.cfg.SessionsAddr(), nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var res models.SessionsResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Sessions, nil
+}
+
+// GetDue получает с сервера записи, пароли которых нужно сменить в ближайшие days дней.
+// При days <= 0 сервер использует значение по умолчанию
+func (c Client) GetDue(days int) ([]models.UserData, error) {
+	addr := c.cfg.DueAddr()
+	if days > 0 {
+		addr += "?days=" + strconv.Itoa(days)
+	}
+
+	resp, err := c.authorizedRequest(http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var res models.UserDataResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Data, nil
+}
+
+// GetAudit получает последние события журнала аудита пользователя. При limit <= 0 сервер возвращает значение по умолчанию
+func (c Client) GetAudit(limit int) ([]models.AuditEvent, error) {
+	addr := c.cfg.AuditAddr()
+	if limit > 0 {
+		addr += "?limit=" + strconv.Itoa(limit)
+	}
+
+	resp, err := c.authorizedRequest(http.MethodGet, addr, nil)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, statusError(resp)
+	}
+
+	var res models.AuditResponse
+	err = json.NewDecoder(resp.Body).Decode(&res)
+	if err != nil {
+		return nil, err
+	}
+
+	return res.Events, nil
+}
+
+// RevokeSession отзывает сессию пользователя или все сессии, кроме текущей
+func (c Client) RevokeSession(r models.RevokeSessionRequest) error {
+	resp, err := c.authorizedRequest(http.MethodDelete, c.cfg.SessionsAddr(), r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+
+	case http.StatusNotFound:
+		return models.ErrNotFound
+	}
+
+	return statusError(resp)
+}
+
+// ChangePassword меняет пароль пользователя. Остальные сессии пользователя после этого отзываются сервером
+func (c Client) ChangePassword(r models.ChangePasswordRequest) error {
+	return c.accountAction(http.MethodPatch, c.cfg.PasswordAddr(), r)
+}
+
+// ChangeLogin меняет логин пользователя
+func (c Client) ChangeLogin(r models.ChangeLoginRequest) error {
+	return c.accountAction(http.MethodPatch, c.cfg.LoginAddr(), r)
+}
+
+// DeleteAccount удаляет аккаунт пользователя со всеми данными на сервере
+func (c Client) DeleteAccount(r models.DeleteAccountRequest) error {
+	return c.accountAction(http.MethodDelete, c.cfg.AccountAddr(), r)
+}
+
+// RecoverAccount сбрасывает пароль по ключу восстановления и возвращает новый ключ. Токен для запроса не нужен
+func (c Client) RecoverAccount(r models.RecoveryRequest) (string, error) {
+	s, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest(http.MethodPost, c.cfg.RecoveryAddr(), bytes.NewBuffer(s))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := c.cl.Do(req)
+	if err != nil {
+		log.Println(err)
+		return "", models.ErrInternalServerError
+	}
+	defer resp.Body.Close()
+
+	return recoveryKeyResponse(resp)
+}
+
+// RegenerateRecoveryKey выпускает новый ключ восстановления, предыдущий перестает действовать
+func (c Client) RegenerateRecoveryKey(r models.RecoveryKeyRequest) (string, error) {
+	resp, err := c.authorizedRequest(http.MethodPost, c.cfg.RecoveryKeyAddr(), r)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	return recoveryKeyResponse(resp)
+}
+
+// RevokeRecoveryKey отзывает ключ восстановления
+func (c Client) RevokeRecoveryKey(r models.RecoveryKeyRequest) error {
+	return c.accountAction(http.MethodDelete, c.cfg.RecoveryKeyAddr(), r)
+}
+
+// accountAction отправляет запрос изменения аккаунта и преобразует статус ответа в ошибку
+func (c Client) accountAction(method, addr string, r models.Validatable) error {
+	resp, err := c.authorizedRequest(method, addr, r)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+		return nil
+
+	case http.StatusForbidden:
+		return models.ErrUserDataConflict
+
+	case http.StatusConflict:
+		return models.ErrUserConflict
+	}
+
+	return statusError(resp)
+}
+
+// authorizedRequest отправляет запрос с токеном клиента. При непустом body он передается в формате json
+func (c Client) authorizedRequest(method, addr string, body any) (*http.Response, error) {
+	var reader io.Reader
+	if body != nil {
+		s, err := json.Marshal(body)
+		if err != nil {
+			return nil, err
+		}
+		reader = bytes.NewBuffer(s)
+	}
+
+	req, err := http.NewRequest(method, addr, reader)
+	if err != nil {
+		return nil, err
+	}
+
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	req.Header.Set("Authorization", c.token)
+
+	return c.cl.Do(req)
+}
+
+// recoveryKeyResponse разбирает ответ с новым ключом восстановления. Статус 403 означает неверные данные пользователя
+func recoveryKeyResponse(resp *http.Response) (string, error) {
+	switch resp.StatusCode {
+	case http.StatusOK:
+		var res models.RecoveryKeyResponse
+		err := json.NewDecoder(resp.Body).Decode(&res)
+		if err != nil {
+			return "", err
+		}
+		return res.RecoveryKey, nil
+
+	case http.StatusForbidden:
+		return "", models.ErrUserDataConflict
+	}
+
+	return "", statusError(resp)
+}
+
+// rateLimitError возвращает *models.RateLimitError с временем ожидания из заголовка Retry-After
+func rateLimitError(resp *http.Response) error {
+	seconds, err := strconv.Atoi(resp.Header.Get("Retry-After"))
+	if err != nil || seconds < 0 {
+		seconds = 0
+	}
+
+	return &models.RateLimitError{RetryAfter: time.Duration(seconds) * time.Second}
+}
+
+// statusError возвращает ошибку, соответствующую общим статусам ответа сервера
+func statusError(resp *http.Response) error {
+	switch resp.StatusCode {
+	case http.StatusBadRequest:
+		return models.ErrBadRequest
+
+	case http.StatusForbidden:
+		return models.ErrForbidden
+
+	case http.StatusUnauthorized:
+		return models.ErrExpiredToken
+
+	case http.StatusTooManyRequests:
+		return rateLimitError(resp)
+
+	case http.StatusInternalServerError:
+		return models.ErrInternalServerError
+	}
+
+	return errors.New("unexpected status code " + resp.Status)
+}
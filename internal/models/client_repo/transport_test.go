@@ -3,6 +3,7 @@ package client_repo
 import (
 	"net/http"
 	"testing"
+	"time"
 
 	server_logic "github.com/azazel3ooo/keeper/internal/logic/server"
 	"github.com/azazel3ooo/keeper/internal/models"
@@ -22,8 +23,9 @@ func TestClient_ActionToServer(t *testing.T) {
 
 	c := NewClient(WithClient(testing_repos_client.TestingClient{S: *s}))
 
-	testToken, _ := server_logic.GenerateToken("tmp", 5.0)
-	expiredToken, _ := server_logic.GenerateToken("tmp", 0.0)
+	store.CreateSession(models.Session{ID: "session", User: "tmp"})
+	testToken, _ := server_logic.GenerateToken("tmp", "session", 5.0)
+	expiredToken, _ := server_logic.GenerateToken("tmp", "", 0.0)
 	tests := []struct {
 		description string
 		req         models.Validatable
@@ -82,8 +84,9 @@ func TestClient_GetActualData(t *testing.T) {
 
 	route := "/api/v1/items"
 	uid := "tmp"
-	testToken, _ := server_logic.GenerateToken(uid, 5.0)
-	expiredToken, _ := server_logic.GenerateToken(uid, 0.0)
+	store.CreateSession(models.Session{ID: "session", User: uid})
+	testToken, _ := server_logic.GenerateToken(uid, "session", 5.0)
+	expiredToken, _ := server_logic.GenerateToken(uid, "", 0.0)
 	store.SetData(models.UserData{
 		Data:    "tmp_data",
 		Comment: "tmp_metadata",
@@ -133,6 +136,15 @@ func TestClient_GetToken(t *testing.T) {
 	authRoute := "/api/v1/auth"
 	regRoute := "/api/v1/registration"
 
+	id, _ := store.CreateUser("totp", "totp")
+	store.SetTOTP(id, "JBSWY3DPEHPK3PXP")
+	store.ConfirmTOTP(id)
+	store.SetAttempts(server_logic.LoginLimit("locked").Key, models.AuthAttempts{
+		Failures:    server_logic.LoginFailuresThreshold,
+		LastFailure: time.Now(),
+		LockedUntil: time.Now().Add(time.Minute),
+	})
+
 	tests := []struct {
 		description string
 		req         models.UserRequest
@@ -163,12 +175,44 @@ func TestClient_GetToken(t *testing.T) {
 			route:       regRoute,
 			expectedErr: models.ErrBadRequest,
 		},
+		{
+			description: "second factor required",
+			req:         models.UserRequest{Login: "totp", Password: "totp"},
+			route:       authRoute,
+			expectedErr: models.ErrSecondFactorRequired,
+		},
+		{
+			description: "too many requests",
+			req:         models.UserRequest{Login: "locked", Password: "locked"},
+			route:       authRoute,
+			expectedErr: &models.RateLimitError{RetryAfter: time.Minute},
+		},
 	}
 	for _, tt := range tests {
 		err := c.GetToken(tt.req, tt.route)
 		assert.Equalf(t, tt.expectedErr, err, tt.description)
 		if tt.expectedErr == nil {
 			assert.Equalf(t, true, c.token != "", tt.description)
+			assert.Equalf(t, true, c.RecoveryKey() != "", tt.description)
 		}
 	}
 }
+
+func TestClient_RecoverAccount(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := server_repo.NewServer(server_repo.WithStorage(store))
+	s.SetupApp()
+
+	c := NewClient(WithClient(testing_repos_client.TestingClient{S: *s}))
+
+	id, _ := store.CreateUser("q", "q")
+	key, _ := server_logic.IssueRecoveryKey(id, store)
+
+	_, err := c.RecoverAccount(models.RecoveryRequest{Login: "q", RecoveryKey: "AAAA", NewPassword: "w"})
+	assert.Equalf(t, models.ErrUserDataConflict, err, "wrong key")
+
+	newKey, err := c.RecoverAccount(models.RecoveryRequest{Login: "q", RecoveryKey: key, NewPassword: "w"})
+	assert.Nilf(t, err, "success")
+	assert.NotEqualf(t, key, newKey, "key rotated")
+}
@@ -0,0 +1,299 @@
+package models
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// EnvPrefix префикс переменных окружения, переопределяющих настройки
+	EnvPrefix = "KEEPER_"
+
+	// ConfigFlag флаг с путем к файлу настроек
+	ConfigFlag = "config"
+
+	// MinJWTKeyLength минимальная длина ключа подписи токенов
+	MinJWTKeyLength = 32
+)
+
+// Транспорты клиента
+const (
+	TransportHTTP = "http"
+	TransportGRPC = "grpc"
+)
+
+// Configurable настройки, которые можно загрузить через LoadConfig
+type Configurable interface {
+	SetDefaults()
+	Validate() error
+}
+
+// LoadConfig заполняет cfg по слоям, каждый следующий из которых переопределяет предыдущий: значения по умолчанию,
+// файл настроек (путь из флага --config, без него defaultFile, если он существует), переменные окружения KEEPER_*
+// и флаги запуска. Имена переменных и флагов строятся из yaml тегов: tls.cert_file задается через KEEPER_TLS_CERT_FILE
+// и --tls-cert-file. Возвращает аргументы, оставшиеся после флагов (команду и ее аргументы)
+func LoadConfig(cfg Configurable, name, defaultFile string, args []string) ([]string, error) {
+	cfg.SetDefaults()
+	fields := configFields(reflect.ValueOf(cfg).Elem(), nil)
+
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	path := fs.String(ConfigFlag, "", "path to config file (default "+defaultFile+")")
+
+	// флаги применяются последними, поэтому до чтения файла и окружения значения только запоминаются
+	flags := make(map[string]string)
+	for _, f := range fields {
+		f := f
+		fs.Func(f.flag(), fmt.Sprintf("overrides %s, env %s", f.name(), f.env()), func(v string) error {
+			flags[f.flag()] = v
+			return nil
+		})
+	}
+
+	err := fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	filename, required := *path, true
+	if filename == "" {
+		filename, required = defaultFile, false
+	}
+	err = readConfigFile(cfg, filename, required)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, f := range fields {
+		v, ok := os.LookupEnv(f.env())
+		if !ok {
+			continue
+		}
+		err = f.set(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %s", ErrInvalidConfig, f.env(), err)
+		}
+	}
+
+	for _, f := range fields {
+		v, ok := flags[f.flag()]
+		if !ok {
+			continue
+		}
+		err = f.set(v)
+		if err != nil {
+			return nil, fmt.Errorf("%w: --%s: %s", ErrInvalidConfig, f.flag(), err)
+		}
+	}
+
+	return fs.Args(), cfg.Validate()
+}
+
+// readConfigFile читает yaml файл настроек. Неизвестные поля считаются ошибкой, чтобы опечатки не терялись молча
+func readConfigFile(cfg Configurable, filename string, required bool) error {
+	b, err := os.ReadFile(filename)
+	if errors.Is(err, os.ErrNotExist) && !required {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = yaml.UnmarshalStrict(b, cfg)
+	if err != nil {
+		return fmt.Errorf("%w: %s: %s", ErrInvalidConfig, filename, err)
+	}
+
+	return nil
+}
+
+// configField поле настроек и путь к нему из yaml тегов
+type configField struct {
+	path  []string
+	value reflect.Value
+}
+
+func (f configField) name() string {
+	return strings.Join(f.path, ".")
+}
+
+func (f configField) env() string {
+	return EnvPrefix + strings.ToUpper(strings.Join(f.path, "_"))
+}
+
+func (f configField) flag() string {
+	return strings.ReplaceAll(strings.Join(f.path, "-"), "_", "-")
+}
+
+// set разбирает строковое значение из окружения или флага в соответствии с типом поля
+func (f configField) set(v string) error {
+	if f.value.Type() == reflect.TypeOf(time.Duration(0)) {
+		d, err := time.ParseDuration(v)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(d))
+		return nil
+	}
+
+	switch f.value.Kind() {
+	case reflect.String:
+		f.value.SetString(v)
+	case reflect.Int:
+		n, err := strconv.Atoi(v)
+		if err != nil {
+			return err
+		}
+		f.value.SetInt(int64(n))
+	case reflect.Bool:
+		b, err := strconv.ParseBool(v)
+		if err != nil {
+			return err
+		}
+		f.value.SetBool(b)
+	default:
+		return fmt.Errorf("unsupported type %s", f.value.Type())
+	}
+
+	return nil
+}
+
+// configFields возвращает поля структуры настроек, включая поля вложенных структур
+func configFields(v reflect.Value, prefix []string) []configField {
+	var fields []configField
+	for i := 0; i < v.NumField(); i++ {
+		tag, _, _ := strings.Cut(v.Type().Field(i).Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+
+		path := append(append([]string{}, prefix...), tag)
+		if v.Field(i).Kind() == reflect.Struct {
+			fields = append(fields, configFields(v.Field(i), path)...)
+			continue
+		}
+		fields = append(fields, configField{path: path, value: v.Field(i)})
+	}
+
+	return fields
+}
+
+// SetDefaults задает значения по умолчанию для запуска без файла настроек
+func (c *ServerConfig) SetDefaults() {
+	*c = ServerConfig{
+		HostAddr:        "localhost:8888",
+		DbLocation:      "server.db",
+		JWT:             JWTConfig{TokenTTL: 5 * time.Minute},
+		Log:             LogConfig{Level: "info", Format: "text"},
+		ShutdownTimeout: 30 * time.Second,
+	}
+}
+
+// Validate проверяет настройки сервера. Ошибка содержит имя неверного поля
+func (c ServerConfig) Validate() error {
+	switch {
+	case c.HostAddr == "":
+		return invalidField("host", "must not be empty")
+	case c.DbLocation == "":
+		return invalidField("db_location", "must not be empty")
+	case c.ShutdownTimeout < 0:
+		return invalidField("shutdown_timeout", "must not be negative")
+	case c.JWT.TokenTTL <= 0:
+		return invalidField("jwt.token_ttl", "must be positive")
+	case c.JWT.Key != "" && len(c.JWT.Key) < MinJWTKeyLength:
+		return invalidField("jwt.key", fmt.Sprintf("must be at least %d bytes", MinJWTKeyLength))
+	case c.Backup.Interval < 0:
+		return invalidField("backup.interval", "must not be negative")
+	case c.Backup.Keep < 0:
+		return invalidField("backup.keep", "must not be negative")
+	case c.Backup.Interval > 0 && c.Backup.Dir == "":
+		return invalidField("backup.dir", "is required for scheduled backups")
+	}
+
+	err := c.Log.validate()
+	if err != nil {
+		return err
+	}
+
+	err = c.TLS.validate()
+	if err != nil {
+		return err
+	}
+	// без сертификата сервер работает без TLS и проверять сертификаты клиентов было бы нечем
+	if c.TLS.ClientCAFile != "" && !c.TLS.Enabled() {
+		return invalidField("tls.client_ca_file", "requires cert_file and key_file")
+	}
+
+	return nil
+}
+
+// SetDefaults задает значения по умолчанию для запуска без файла настроек
+func (c *ClientConfig) SetDefaults() {
+	*c = ClientConfig{
+		HostAddr:   "http://127.0.0.1:8888",
+		DbLocation: "client.db",
+	}
+}
+
+// Validate проверяет настройки клиента. Ошибка содержит имя неверного поля
+func (c ClientConfig) Validate() error {
+	u, err := url.Parse(c.HostAddr)
+	if c.HostAddr == "" || err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return invalidField("host", fmt.Sprintf("%q is not a server address like https://host:port", c.HostAddr))
+	}
+	if c.DbLocation == "" {
+		return invalidField("db_location", "must not be empty")
+	}
+
+	switch c.Transport {
+	case "", TransportHTTP:
+	case TransportGRPC:
+		if c.GRPCAddr == "" {
+			return invalidField("grpc_host", "is required for transport grpc")
+		}
+	default:
+		return invalidField("transport", fmt.Sprintf("%q, expected http or grpc", c.Transport))
+	}
+
+	return c.TLS.validate()
+}
+
+func (c LogConfig) validate() error {
+	switch strings.ToLower(c.Level) {
+	case "", "debug", "info", "warn", "error":
+	default:
+		return invalidField("log.level", fmt.Sprintf("%q, expected debug, info, warn or error", c.Level))
+	}
+
+	switch strings.ToLower(c.Format) {
+	case "", "text", "json":
+	default:
+		return invalidField("log.format", fmt.Sprintf("%q, expected text or json", c.Format))
+	}
+
+	return nil
+}
+
+func (c TLSConfig) validate() error {
+	_, err := c.minVersion()
+	if err != nil {
+		return invalidField("tls.min_version", fmt.Sprintf("%q, expected 1.2 or 1.3", c.MinVersion))
+	}
+	if (c.CertFile == "") != (c.KeyFile == "") {
+		return invalidField("tls", "cert_file and key_file must be set together")
+	}
+
+	return nil
+}
+
+func invalidField(name, reason string) error {
+	return fmt.Errorf("%w: %s %s", ErrInvalidConfig, name, reason)
+}
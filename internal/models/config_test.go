@@ -0,0 +1,146 @@
+package models
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadConfig(t *testing.T) {
+	dir := t.TempDir()
+	file := filepath.Join(dir, "server.yml")
+	err := os.WriteFile(file, []byte("host: \"file:1\"\ndb_location: \"file.db\"\njwt:\n  token_ttl: \"10m\"\n"), 0600)
+	assert.Nil(t, err)
+
+	tests := []struct {
+		name string
+		env  map[string]string
+		args []string
+		want ServerConfig
+		rest []string
+		err  error
+	}{
+		{
+			name: "defaults without file",
+			args: []string{"backup", "snapshot.db"},
+			want: ServerConfig{HostAddr: "localhost:8888", DbLocation: "server.db", JWT: JWTConfig{TokenTTL: 5 * time.Minute}},
+			rest: []string{"backup", "snapshot.db"},
+		},
+		{
+			name: "file overrides defaults",
+			args: []string{"--config", file},
+			want: ServerConfig{HostAddr: "file:1", DbLocation: "file.db", JWT: JWTConfig{TokenTTL: 10 * time.Minute}},
+		},
+		{
+			name: "env overrides file",
+			env:  map[string]string{"KEEPER_HOST": "env:1", "KEEPER_JWT_TOKEN_TTL": "1h", "KEEPER_BACKUP_KEEP": "3"},
+			args: []string{"--config", file},
+			want: ServerConfig{HostAddr: "env:1", DbLocation: "file.db", JWT: JWTConfig{TokenTTL: time.Hour}, Backup: BackupConfig{Keep: 3}},
+		},
+		{
+			name: "flags override env",
+			env:  map[string]string{"KEEPER_HOST": "env:1"},
+			args: []string{"--config", file, "--host", "flag:1", "--jwt-token-ttl=2m", "restore", "--force"},
+			want: ServerConfig{HostAddr: "flag:1", DbLocation: "file.db", JWT: JWTConfig{TokenTTL: 2 * time.Minute}},
+			rest: []string{"restore", "--force"},
+		},
+		{
+			name: "invalid env value",
+			env:  map[string]string{"KEEPER_SHUTDOWN_TIMEOUT": "soon"},
+			err:  ErrInvalidConfig,
+		},
+		{
+			name: "short jwt key",
+			args: []string{"--jwt-key", "secret"},
+			err:  ErrInvalidConfig,
+		},
+		{
+			name: "scheduled backup without dir",
+			args: []string{"--backup-interval", "1h"},
+			err:  ErrInvalidConfig,
+		},
+		{
+			name: "missing config file",
+			args: []string{"--config", filepath.Join(dir, "missing.yml")},
+			err:  os.ErrNotExist,
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for k, v := range tt.env {
+				t.Setenv(k, v)
+			}
+
+			var cfg ServerConfig
+			rest, err := LoadConfig(&cfg, "server", filepath.Join(dir, "default.yml"), tt.args)
+			if tt.err != nil {
+				assert.ErrorIs(t, err, tt.err)
+				return
+			}
+			assert.Nil(t, err)
+			assert.ElementsMatch(t, tt.rest, rest)
+
+			// поля, не участвующие в проверке, берутся из значений по умолчанию
+			tt.want.Log = LogConfig{Level: "info", Format: "text"}
+			tt.want.ShutdownTimeout = 30 * time.Second
+			assert.Equal(t, tt.want, cfg)
+		})
+	}
+}
+
+func TestLoadConfig_unknownField(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "client.yml")
+	err := os.WriteFile(file, []byte("hots: \"http://127.0.0.1:8888\"\n"), 0600)
+	assert.Nil(t, err)
+
+	var cfg ClientConfig
+	_, err = LoadConfig(&cfg, "client", "", []string{"--config", file})
+	assert.ErrorIs(t, err, ErrInvalidConfig)
+}
+
+func TestClientConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name      string
+		host      string
+		transport string
+		grpcHost  string
+		valid     bool
+	}{
+		{name: "http", host: "http://127.0.0.1:8888", valid: true},
+		{name: "https", host: "https://keeper.example.com", valid: true},
+		{name: "no scheme", host: "127.0.0.1:8888"},
+		{name: "empty", host: ""},
+		{name: "grpc", host: "http://127.0.0.1:8888", transport: TransportGRPC, grpcHost: "127.0.0.1:9090", valid: true},
+		{name: "grpc without address", host: "http://127.0.0.1:8888", transport: TransportGRPC},
+		{name: "unknown transport", host: "http://127.0.0.1:8888", transport: "websocket"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ClientConfig{HostAddr: tt.host, DbLocation: "client.db", Transport: tt.transport, GRPCAddr: tt.grpcHost}
+			assert.Equal(t, tt.valid, cfg.Validate() == nil)
+		})
+	}
+}
+
+func TestServerConfig_Validate(t *testing.T) {
+	tests := []struct {
+		name  string
+		tls   TLSConfig
+		valid bool
+	}{
+		{name: "no tls", valid: true},
+		{name: "tls", tls: TLSConfig{CertFile: "server.crt", KeyFile: "server.key"}, valid: true},
+		{name: "mtls", tls: TLSConfig{CertFile: "server.crt", KeyFile: "server.key", ClientCAFile: "ca.crt"}, valid: true},
+		{name: "cert without key", tls: TLSConfig{CertFile: "server.crt"}},
+		{name: "client ca without cert", tls: TLSConfig{ClientCAFile: "ca.crt"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := ServerConfig{HostAddr: "localhost:8888", DbLocation: "server.db", JWT: JWTConfig{TokenTTL: time.Minute}, TLS: tt.tls}
+			assert.Equal(t, tt.valid, cfg.Validate() == nil)
+		})
+	}
+}
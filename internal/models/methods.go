@@ -1,37 +1,78 @@
 package models
 
-import (
-	"bytes"
-	"os"
-
-	"gopkg.in/yaml.v2"
-)
-
 // RegAddr возвращает адрес для хендлера регистрации
-func (c Config) RegAddr() string {
+func (c ClientConfig) RegAddr() string {
 	return c.HostAddr + "/api/v1/registration"
 }
 
 // AuthAddr возвращает адрес для хендлера авторизации
-func (c Config) AuthAddr() string {
+func (c ClientConfig) AuthAddr() string {
 	return c.HostAddr + "/api/v1/auth"
 }
 
-// ActionAddr возвращает адрес для хендлера выполнения действий(обновление, добавление...)
-func (c Config) ActionAddr() string {
-	return c.HostAddr + "/api/v1/items"
+// TOTPEnrollAddr возвращает адрес для хендлера подключения двухфакторной аутентификации
+func (c ClientConfig) TOTPEnrollAddr() string {
+	return c.HostAddr + "/api/v1/2fa/enroll"
 }
 
-func (c *Config) Init(filename string) error {
-	f, err := os.ReadFile(filename)
-	if err != nil {
-		return err
-	}
+// TOTPConfirmAddr возвращает адрес для хендлера подтверждения двухфакторной аутентификации
+func (c ClientConfig) TOTPConfirmAddr() string {
+	return c.HostAddr + "/api/v1/2fa/confirm"
+}
+
+// TOTPDisableAddr возвращает адрес для хендлера отключения двухфакторной аутентификации
+func (c ClientConfig) TOTPDisableAddr() string {
+	return c.HostAddr + "/api/v1/2fa/disable"
+}
+
+// AuditAddr возвращает адрес для хендлера получения журнала аудита
+func (c ClientConfig) AuditAddr() string {
+	return c.HostAddr + "/api/v1/audit"
+}
 
-	reader := bytes.NewReader(f)
-	decoder := yaml.NewDecoder(reader)
+// SessionsAddr возвращает адрес для хендлеров работы с сессиями
+func (c ClientConfig) SessionsAddr() string {
+	return c.HostAddr + "/api/v1/sessions"
+}
 
-	return decoder.Decode(c)
+// AccountAddr возвращает адрес для хендлера удаления аккаунта
+func (c ClientConfig) AccountAddr() string {
+	return c.HostAddr + "/api/v1/account"
+}
+
+// PasswordAddr возвращает адрес для хендлера смены пароля
+func (c ClientConfig) PasswordAddr() string {
+	return c.HostAddr + "/api/v1/account/password"
+}
+
+// LoginAddr возвращает адрес для хендлера смены логина
+func (c ClientConfig) LoginAddr() string {
+	return c.HostAddr + "/api/v1/account/login"
+}
+
+// RecoveryAddr возвращает адрес для хендлера восстановления доступа по ключу восстановления
+func (c ClientConfig) RecoveryAddr() string {
+	return c.HostAddr + "/api/v1/recovery"
+}
+
+// RecoveryKeyAddr возвращает адрес для хендлеров перевыпуска и отзыва ключа восстановления
+func (c ClientConfig) RecoveryKeyAddr() string {
+	return c.HostAddr + "/api/v1/account/recovery"
+}
+
+// DueAddr возвращает адрес для хендлера списка записей, пароли которых пора сменить
+func (c ClientConfig) DueAddr() string {
+	return c.HostAddr + "/api/v1/items/due"
+}
+
+// MoveAddr возвращает адрес для хендлера перемещения записей между папками
+func (c ClientConfig) MoveAddr() string {
+	return c.HostAddr + "/api/v1/items/move"
+}
+
+// ActionAddr возвращает адрес для хендлера выполнения действий(обновление, добавление...)
+func (c ClientConfig) ActionAddr() string {
+	return c.HostAddr + "/api/v1/items"
 }
 
 // Valid проверяет заполнение полей и валидность структуры для обработки
@@ -39,6 +80,41 @@ func (r UserRequest) Valid() bool {
 	return r.Login != "" && r.Password != ""
 }
 
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r TOTPConfirmRequest) Valid() bool {
+	return r.Code != ""
+}
+
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r RevokeSessionRequest) Valid() bool {
+	return r.ID != "" || r.All
+}
+
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r ChangePasswordRequest) Valid() bool {
+	return r.Password != "" && r.NewPassword != ""
+}
+
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r ChangeLoginRequest) Valid() bool {
+	return r.Password != "" && r.NewLogin != ""
+}
+
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r DeleteAccountRequest) Valid() bool {
+	return r.Password != ""
+}
+
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r RecoveryRequest) Valid() bool {
+	return r.Login != "" && r.RecoveryKey != "" && r.NewPassword != ""
+}
+
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r RecoveryKeyRequest) Valid() bool {
+	return r.Password != ""
+}
+
 // Valid проверяет заполнение полей и валидность структуры для обработки
 func (r UserData) Valid() bool {
 	return r.Data != ""
@@ -48,3 +124,16 @@ func (r UserData) Valid() bool {
 func (r DeleteRequest) Valid() bool {
 	return r.ID != ""
 }
+
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r MoveRequest) Valid() bool {
+	if len(r.IDs) == 0 {
+		return false
+	}
+	for _, id := range r.IDs {
+		if id == "" || id == r.Folder {
+			return false
+		}
+	}
+	return true
+}
@@ -2,7 +2,9 @@ package models
 
 import (
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 )
 
 var (
@@ -11,6 +13,41 @@ var (
 	ErrUserRegistrationConflict = errors.New("user already exist")
 	ErrInternalServerError      = errors.New("internal server error")
 	ErrUncastable               = errors.New("can't cast")
+	ErrTooManyRequests          = errors.New("too many requests")
+	ErrSecondFactorRequired     = errors.New("second factor required")
+	ErrNotFound                 = errors.New("record not found")
+	ErrNoOTP                    = errors.New("record has no one-time password secret")
+	ErrNotLoginRecord           = errors.New("record is not a login")
+	ErrInvalidExpiry            = errors.New("invalid expiry, expected number of days or date YYYY-MM-DD")
+	ErrUnknownRecordType        = errors.New("unknown record type")
+	ErrUnknownImportFormat      = errors.New("unknown import format")
+	ErrEncryptedExport          = errors.New("encrypted exports are not supported, export data without encryption")
+	ErrInvalidColumnMapping     = errors.New("invalid column mapping")
+	ErrUnsupportedArchive       = errors.New("not a keeper backup or unsupported backup version")
+	ErrWrongArchivePassword     = errors.New("wrong backup password or corrupted backup")
+	ErrKDFParamsTooHigh         = errors.New("key derivation parameters are too high")
+	ErrBackupExists             = errors.New("backup file already exists")
+	ErrInvalidBackup            = errors.New("invalid backup")
+	ErrDatabaseLocked           = errors.New("database is used by a running server")
+	ErrInvalidLogConfig         = errors.New("invalid log config")
+	ErrShutdownTimeout          = errors.New("shutdown deadline exceeded")
+	ErrInvalidConfig            = errors.New("invalid config")
+	ErrInvalidProfile           = errors.New("invalid profile name, use letters, digits, - and _")
+	ErrWrongProfilePassword     = errors.New("wrong profile password or corrupted profile key")
+	ErrCorruptedRecord          = errors.New("local record can not be decrypted")
+	ErrInvalidGeneratorOptions  = errors.New("invalid generator options")
+	ErrInvalidBreachList        = errors.New("invalid breach list, expected sorted lines of SHA1:COUNT")
+	ErrInvalidCardNumber        = errors.New("invalid card number")
+	ErrInvalidCardExpiry        = errors.New("invalid card expiry, expected MM/YY")
+	ErrInvalidCVV               = errors.New("invalid card security code")
+	ErrInvalidPIN               = errors.New("invalid card PIN")
+	ErrInvalidField             = errors.New("invalid custom field")
+	ErrInvalidAttachment        = errors.New("invalid attachment")
+	ErrInvalidName              = errors.New("invalid name, it must not be empty or contain /")
+	ErrNameExists               = errors.New("name is already used in this folder")
+	ErrNotFolder                = errors.New("record is not a folder")
+	ErrFolderCycle              = errors.New("folder can't be moved into itself or its subfolder")
+	ErrGRPCOnly                 = errors.New("available only over gRPC, set transport: grpc")
 )
 
 var (
@@ -18,8 +55,26 @@ var (
 	ErrUserDataConflict = errors.New("invalid login or password")
 	ErrInvalidToken     = errors.New("invalid token")
 	ErrExpiredToken     = errors.New("expired token")
+	ErrRevokedSession   = errors.New("session revoked")
+	ErrInvalidCode      = errors.New("invalid one-time code")
+	ErrTOTPNotEnrolled  = errors.New("two-factor authentication is not enrolled")
+	ErrTOTPEnabled      = errors.New("two-factor authentication already enabled")
 )
 
+// RateLimitError возвращается при превышении лимита попыток и содержит время до снятия блокировки
+type RateLimitError struct {
+	RetryAfter time.Duration
+}
+
+func (e *RateLimitError) Error() string {
+	return fmt.Sprintf("%s, retry after %s", ErrTooManyRequests, e.RetryAfter)
+}
+
+// Is позволяет проверять ошибку через errors.Is(err, ErrTooManyRequests)
+func (e *RateLimitError) Is(target error) bool {
+	return target == ErrTooManyRequests
+}
+
 // ClientHttpInterface для возможности подмены на тестовый клиент
 type ClientHttpInterface interface {
 	Do(req *http.Request) (*http.Response, error)
@@ -33,44 +88,287 @@ type Validatable interface {
 type Storable4Server interface {
 	Storable4Users
 	Storable4Data
+	Storable4TwoFactor
+	Storable4Limits
+	Storable4Sessions
+	Storable4Recovery
+	Storable4Audit
+
+	Ping() error // проверка доступности хранилища для readiness
 }
 
 type Storable4Users interface {
 	CreateUser(login, pass string) (string, error)
 	CheckUser(login string) (string, string, error)
+	GetLogin(id string) (string, error)
+	UpdatePassword(id, pass string) error
+	UpdateLogin(id, login string) error
+	DeleteUser(id string) error
+}
+
+// Storable4TwoFactor хранение TOTP секретов и кодов восстановления (коды хранятся в виде хэшей)
+type Storable4TwoFactor interface {
+	SetTOTP(user, secret string) error
+	ConfirmTOTP(user string) error
+	GetTOTP(user string) (secret string, confirmed bool, err error)
+	UseTOTPStep(user string, step int64) (bool, error) // false, если этот или более поздний период уже использован
+	DeleteTOTP(user string) error                      // вместе с кодами восстановления
+	SetRecoveryCodes(user string, codes []string) error
+	UseRecoveryCode(user, code string) (bool, error)
+}
+
+// Storable4Limits хранение неудачных попыток авторизации, чтобы блокировки переживали перезапуск сервера
+type Storable4Limits interface {
+	GetAttempts(key string) (AuthAttempts, error)
+	SetAttempts(key string, a AuthAttempts) error
+	ResetAttempts(key string) error
+}
+
+// Storable4Sessions хранение сессий (устройств), с которых пользователь выполнил вход
+type Storable4Sessions interface {
+	CreateSession(s Session) error
+	GetSession(id string) (Session, error)
+	GetSessions(user string) ([]Session, error)
+	TouchSession(id, ip string, t time.Time) error
+	ExtendSession(id string, expires time.Time) error
+	RevokeSession(id, user string) error
+	RevokeSessions(user, except string) error
+	CountActiveUsers(since, now time.Time) (int, error) // обращавшиеся после since, с действующей в now сессией
+}
+
+// Storable4Recovery хранение хэшей ключей восстановления аккаунта
+type Storable4Recovery interface {
+	SetRecoveryKey(user, hash string) error
+	GetRecoveryKey(user string) (string, error)
+	DeleteRecoveryKey(user string) error
+}
+
+// Storable4Audit журнал событий безопасности. Записи только добавляются
+type Storable4Audit interface {
+	AddAuditEvent(e AuditEvent) error
+	GetAuditEvents(user string, limit int) ([]AuditEvent, error)
 }
 
 type Storable4Data interface {
 	SetData(req UserData, user string) error
 	GetData(user string) ([]UserData, error)
-	Delete(req DeleteRequest, user string) error
-	Update(req UserData, user string) error
+	GetDueData(user string, before time.Time) ([]UserData, error) // записи со сроком смены пароля до before
+	GetFolderData(user, folder string) ([]UserData, error)        // записи, лежащие непосредственно в папке
+	Delete(req DeleteRequest, user string) error                  // содержимое удаленной папки переносится в ее родителя
+	Update(req UserData, user string) error                       // папка записи не изменяется, для этого есть MoveData
+	MoveData(req MoveRequest, user string) error
 }
 
-type Config struct {
-	HostAddr   string `yaml:"host"`
-	DbLocation string `yaml:"db_location"`
+// ServerConfig настройки сервера, загружаются через LoadConfig
+type ServerConfig struct {
+	HostAddr   string       `yaml:"host"`
+	GRPCAddr   string       `yaml:"grpc_host"` // адрес gRPC API, пустой - gRPC API не запускается
+	DbLocation string       `yaml:"db_location"`
+	TLS        TLSConfig    `yaml:"tls"`
+	JWT        JWTConfig    `yaml:"jwt"`
+	Backup     BackupConfig `yaml:"backup"`
+	Log        LogConfig    `yaml:"log"`
+
+	ShutdownTimeout time.Duration `yaml:"shutdown_timeout"` // время на завершение запросов и обработку очереди при остановке
+}
+
+// ClientConfig настройки клиента, загружаются через LoadConfig. HostAddr - адрес сервера со схемой.
+// Profile выбирает профиль со своим сервером, локальной базой и сессией. Transport grpc переводит вход и операции
+// с записями на gRPC API по адресу GRPCAddr (host:port), остальные запросы по-прежнему идут на HostAddr
+type ClientConfig struct {
+	Profile    string    `yaml:"profile,omitempty"`
+	HostAddr   string    `yaml:"host"`
+	Transport  string    `yaml:"transport,omitempty"` // http (по умолчанию) или grpc
+	GRPCAddr   string    `yaml:"grpc_host,omitempty"`
+	DbLocation string    `yaml:"db_location"`
+	Device     string    `yaml:"device,omitempty"` // имя устройства для списка сессий, по умолчанию имя хоста
+	TLS        TLSConfig `yaml:"tls,omitempty"`
+	BreachList string    `yaml:"breach_list,omitempty"` // локальный файл утекших паролей в формате HIBP
+}
+
+// JWTConfig настройки токенов доступа. Без Key токены подписываются случайным ключом и не переживают перезапуск сервера
+type JWTConfig struct {
+	Key      string        `yaml:"key"`
+	TokenTTL time.Duration `yaml:"token_ttl"`
+}
+
+// LogConfig настройки лога сервера. Level: debug, info, warn или error, Format: text или json
+type LogConfig struct {
+	Level  string `yaml:"level"`
+	Format string `yaml:"format"`
+}
+
+// BackupConfig настройки резервного копирования базы сервера по расписанию. Пустой Interval отключает его
+type BackupConfig struct {
+	Dir      string        `yaml:"dir"`
+	Interval time.Duration `yaml:"interval"`
+	Keep     int           `yaml:"keep"` // число хранимых снимков, 0 - хранить все
+}
+
+// TLSConfig настройки TLS. Для сервера CertFile и KeyFile включают TLS, а ClientCAFile - обязательную проверку
+// сертификатов клиентов (mTLS). Для клиента CAFile закрепляет доверенный CA сервера вместо системных,
+// CertFile и KeyFile задают сертификат клиента
+type TLSConfig struct {
+	CertFile     string `yaml:"cert_file"`
+	KeyFile      string `yaml:"key_file"`
+	CAFile       string `yaml:"ca_file"`
+	ClientCAFile string `yaml:"client_ca_file"`
+	MinVersion   string `yaml:"min_version"` // "1.2" (по умолчанию) или "1.3"
+}
+
+// AuthAttempts учет неудачных попыток для ключа ограничения (ip, логин)
+type AuthAttempts struct {
+	Failures    int
+	LastFailure time.Time
+	LockedUntil time.Time
 }
 
 type UserRequest struct {
 	Login    string `json:"login"`
 	Password string `json:"password"`
+	Code     string `json:"code,omitempty"` // одноразовый код или код восстановления для второго фактора
+	Device   string `json:"device,omitempty"`
+	OS       string `json:"os,omitempty"`
 }
 
 type UserResponse struct {
-	Token string `json:"token"`
+	Token       string `json:"token"`
+	RecoveryKey string `json:"recovery_key,omitempty"` // возвращается только при регистрации
+}
+
+// RecoveryRequest сброс пароля с помощью ключа восстановления
+type RecoveryRequest struct {
+	Login       string `json:"login"`
+	RecoveryKey string `json:"recovery_key"`
+	NewPassword string `json:"new_password"`
+}
+
+// RecoveryKeyRequest подтверждение паролем перевыпуска или отзыва ключа восстановления
+type RecoveryKeyRequest struct {
+	Password string `json:"password"`
 }
 
+type RecoveryKeyResponse struct {
+	RecoveryKey string `json:"recovery_key"`
+}
+
+type ChangePasswordRequest struct {
+	Password    string `json:"password"`
+	NewPassword string `json:"new_password"`
+}
+
+type ChangeLoginRequest struct {
+	Password string `json:"password"`
+	NewLogin string `json:"new_login"`
+}
+
+type DeleteAccountRequest struct {
+	Password string `json:"password"`
+}
+
+// Session сессия пользователя, создаваемая при каждом входе. Токены отозванной сессии не принимаются,
+// после Expires токен сессии истек, и она больше не считается активной
+type Session struct {
+	ID       string    `json:"id"`
+	User     string    `json:"-"`
+	Device   string    `json:"device"`
+	OS       string    `json:"os"`
+	IP       string    `json:"ip"`
+	Created  time.Time `json:"created"`
+	LastSeen time.Time `json:"last_seen"`
+	Expires  time.Time `json:"expires"`
+	Revoked  bool      `json:"-"`
+	Current  bool      `json:"current"`
+}
+
+// HealthResponse состояние сервера и результаты отдельных проверок ("ok" или описание ошибки)
+type HealthResponse struct {
+	Status string            `json:"status"`
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+type SessionsResponse struct {
+	Sessions []Session `json:"sessions"`
+}
+
+// Типы событий журнала аудита
+const (
+	AuditRegistration          = "registration"
+	AuditLogin                 = "login"
+	AuditLoginFailed           = "login_failed"
+	AuditRecordCreate          = "record_create"
+	AuditRecordUpdate          = "record_update"
+	AuditRecordDelete          = "record_delete"
+	AuditRecordMove            = "record_move"
+	AuditSessionRevoke         = "session_revoke"
+	AuditPasswordChange        = "password_change"
+	AuditLoginChange           = "login_change"
+	AuditTOTPEnable            = "totp_enable"
+	AuditTOTPDisable           = "totp_disable"
+	AuditRecovery              = "account_recovery"
+	AuditRecoveryKeyRegenerate = "recovery_key_regenerate"
+	AuditRecoveryKeyRevoke     = "recovery_key_revoke"
+)
+
+// AuditEvent событие журнала аудита. Details содержит id записи или сессии, к которой относится событие
+type AuditEvent struct {
+	User    string    `json:"-"`
+	Event   string    `json:"event"`
+	Device  string    `json:"device,omitempty"`
+	IP      string    `json:"ip"`
+	Time    time.Time `json:"time"`
+	Details string    `json:"details,omitempty"`
+}
+
+type AuditResponse struct {
+	Events []AuditEvent `json:"events"`
+}
+
+// RevokeSessionRequest отзыв сессии по ID или всех сессий пользователя, кроме текущей
+type RevokeSessionRequest struct {
+	ID  string `json:"id,omitempty"`
+	All bool   `json:"all,omitempty"`
+}
+
+// SecondFactorResponse возвращается при авторизации, если для пользователя включен второй фактор
+type SecondFactorResponse struct {
+	Method string `json:"method"`
+}
+
+type TOTPEnrollResponse struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// TOTPConfirmRequest одноразовый код для подтверждения подключения или отключения второго фактора
+type TOTPConfirmRequest struct {
+	Code string `json:"code"`
+}
+
+type TOTPConfirmResponse struct {
+	RecoveryCodes []string `json:"recovery_codes"`
+}
+
+// UserData запись пользователя. ExpiresAt - срок смены пароля записи, передается отдельно для напоминаний сервера.
+// Folder - id записи-папки, в которой она находится, пустой для записей в корне. Имя папки хранится в ее Data
 type UserData struct {
-	ID      string `json:"id"`
-	Data    string `json:"data"`
-	Comment string `json:"metadata,omitempty"`
+	ID        string     `json:"id"`
+	Data      string     `json:"data"`
+	Comment   string     `json:"metadata,omitempty"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+	Folder    string     `json:"folder,omitempty"`
 }
 
 type DeleteRequest struct {
 	ID string `json:"id"`
 }
 
+// MoveRequest перемещает записи и папки IDs в папку Folder (пустая строка - в корень)
+type MoveRequest struct {
+	IDs    []string `json:"ids"`
+	Folder string   `json:"folder"`
+}
+
 type UserDataResponse struct {
 	Data []UserData `json:"data"`
 }
@@ -80,4 +378,6 @@ type ClientStorable interface {
 	GetAll() ([]UserData, error)
 	Update(r UserData) error
 	Delete(r DeleteRequest) error
+	Move(r MoveRequest) error
+	Clear() error
 }
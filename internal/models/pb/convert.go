@@ -0,0 +1,45 @@
+package pb
+
+import (
+	"github.com/azazel3ooo/keeper/internal/models"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// Ключи метаданных gRPC API
+const (
+	MetadataAuthorization = "authorization" // токен, как в заголовке Authorization REST API
+	MetadataRetryAfter    = "retry-after"   // секунды до снятия блокировки в trailer ответа ResourceExhausted
+	MetadataRequestID     = "x-request-id"
+)
+
+// NewItem преобразует запись пользователя в сообщение gRPC API
+func NewItem(d models.UserData) *Item {
+	item := &Item{Id: d.ID, Data: d.Data, Metadata: d.Comment, Folder: d.Folder}
+	if d.ExpiresAt != nil {
+		item.ExpiresAt = timestamppb.New(*d.ExpiresAt)
+	}
+
+	return item
+}
+
+// UserData преобразует сообщение в запись пользователя
+func (x *Item) UserData() models.UserData {
+	d := models.UserData{ID: x.GetId(), Data: x.GetData(), Comment: x.GetMetadata(), Folder: x.GetFolder()}
+	if x.GetExpiresAt() != nil {
+		t := x.GetExpiresAt().AsTime()
+		d.ExpiresAt = &t
+	}
+
+	return d
+}
+
+// NewUserRequest преобразует запрос регистрации или авторизации в сообщение gRPC API
+func NewUserRequest(r models.UserRequest) *UserRequest {
+	return &UserRequest{Login: r.Login, Password: r.Password, Code: r.Code, Device: r.Device, Os: r.OS}
+}
+
+// Request преобразует сообщение в запрос регистрации или авторизации
+func (x *UserRequest) Request() models.UserRequest {
+	return models.UserRequest{Login: x.GetLogin(), Password: x.GetPassword(), Code: x.GetCode(), Device: x.GetDevice(),
+		OS: x.GetOs()}
+}
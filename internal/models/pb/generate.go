@@ -0,0 +1,4 @@
+// Package pb содержит код gRPC API, сгенерированный из keeper.proto
+package pb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative --go-grpc_out=. --go-grpc_opt=paths=source_relative keeper.proto
@@ -0,0 +1,156 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+)
+
+const (
+	RecordText   = "text"
+	RecordLogin  = "login"
+	RecordCard   = "card"
+	RecordFolder = "folder" // имя папки хранится в Text
+)
+
+// RootFolder значение параметра folder для списка записей, не лежащих в папках
+const RootFolder = "root"
+
+// Типы дополнительных полей записи
+const (
+	CustomFieldText   = "text"
+	CustomFieldHidden = "hidden" // значение не выводится в списке записей
+	CustomFieldURL    = "url"
+	CustomFieldDate   = "date" // дата в формате YYYY-MM-DD
+)
+
+// Record содержимое записи пользователя. Сериализуется в UserData.Data, которое клиент передает и сервер хранит
+// без шифрования: тип записи, имя, поля и вложения доступны серверу и защищены только TLS и доступом к его базе.
+// Name - необязательное имя записи, уникальное в ее папке
+type Record struct {
+	Type        string        `json:"type"`
+	Name        string        `json:"name,omitempty"`
+	Text        string        `json:"text,omitempty"`
+	Login       *LoginRecord  `json:"login,omitempty"`
+	Card        *CardRecord   `json:"card,omitempty"`
+	Notes       string        `json:"notes,omitempty"`
+	Fields      []CustomField `json:"fields,omitempty"`
+	Attachments []Attachment  `json:"attachments,omitempty"`
+}
+
+// CustomField дополнительное поле записи любого типа: контрольный вопрос, идентификатор ключа API, коды восстановления
+type CustomField struct {
+	Name  string `json:"name"`
+	Type  string `json:"type"`
+	Value string `json:"value"`
+}
+
+// Attachment ссылка на файл, относящийся к записи: путь к локальному файлу или url. Сам файл keeper не хранит,
+// размер и SHA256 позволяют проверить, что по ссылке тот же файл
+type Attachment struct {
+	Name   string `json:"name"`
+	Ref    string `json:"ref"`
+	Size   int64  `json:"size,omitempty"`
+	SHA256 string `json:"sha256,omitempty"`
+}
+
+// LoginRecord пара логин/пароль. OTP опционально содержит otpauth:// адрес (или base32 секрет) для генерации кодов.
+// PasswordChanged - время последней смены пароля, пустое для записей, созданных до его учета, и импортированных.
+// Срок смены пароля задается датой ExpiresAt или интервалом RotationDays от последней смены
+type LoginRecord struct {
+	Login           string            `json:"login"`
+	Password        string            `json:"password"`
+	URL             string            `json:"url,omitempty"`
+	OTP             string            `json:"otp,omitempty"`
+	PasswordChanged *time.Time        `json:"password_changed,omitempty"`
+	ExpiresAt       *time.Time        `json:"expires_at,omitempty"`
+	RotationDays    int               `json:"rotation_days,omitempty"`
+	History         []PasswordHistory `json:"history,omitempty"`
+}
+
+// PasswordHistory прежний пароль записи и время, до которого он использовался
+type PasswordHistory struct {
+	Password string    `json:"password"`
+	Replaced time.Time `json:"replaced"`
+}
+
+// CardRecord данные банковской карты. Number хранится без пробелов, Expiry в формате MM/YY
+type CardRecord struct {
+	Number string `json:"number"`
+	Holder string `json:"holder,omitempty"`
+	Expiry string `json:"expiry"`
+	CVV    string `json:"cvv,omitempty"`
+	PIN    string `json:"pin,omitempty"`
+}
+
+// ExpiryDate возвращает момент окончания срока действия карты: карта действует до конца указанного месяца
+func (c CardRecord) ExpiryDate() (time.Time, error) {
+	var month, year int
+	n, err := fmt.Sscanf(c.Expiry, "%2d/%2d", &month, &year)
+	if err != nil || n != 2 || len(c.Expiry) != len("01/06") || month < 1 || month > 12 {
+		return time.Time{}, ErrInvalidCardExpiry
+	}
+
+	return time.Date(2000+year, time.Month(month)+1, 1, 0, 0, 0, 0, time.UTC), nil
+}
+
+// ParseRecord восстанавливает Record из UserData.Data. Данные, сохраненные до появления типов записей,
+// считаются текстовой записью
+func ParseRecord(data string) Record {
+	var r Record
+	err := json.Unmarshal([]byte(data), &r)
+	if err != nil || !r.Valid() {
+		return Record{Type: RecordText, Text: data}
+	}
+
+	return r
+}
+
+// DueDate возвращает срок смены пароля логина или окончания действия карты, nil, если он не задан
+func (r Record) DueDate() *time.Time {
+	if r.Type == RecordCard && r.Card != nil {
+		expiry, err := r.Card.ExpiryDate()
+		if err != nil {
+			return nil
+		}
+		return &expiry
+	}
+	if r.Type != RecordLogin || r.Login == nil {
+		return nil
+	}
+	if r.Login.ExpiresAt != nil {
+		return r.Login.ExpiresAt
+	}
+	if r.Login.RotationDays > 0 && r.Login.PasswordChanged != nil {
+		due := r.Login.PasswordChanged.AddDate(0, 0, r.Login.RotationDays)
+		return &due
+	}
+
+	return nil
+}
+
+// Encode сериализует запись для передачи в UserData.Data
+func (r Record) Encode() (string, error) {
+	b, err := json.Marshal(r)
+	if err != nil {
+		return "", err
+	}
+
+	return string(b), nil
+}
+
+// Valid проверяет заполнение полей и валидность структуры для обработки
+func (r Record) Valid() bool {
+	switch r.Type {
+	case RecordText:
+		return r.Text != ""
+	case RecordLogin:
+		return r.Login != nil && (r.Login.Login != "" || r.Login.Password != "")
+	case RecordCard:
+		return r.Card != nil && r.Card.Number != ""
+	case RecordFolder:
+		return r.Text != ""
+	}
+
+	return false
+}
@@ -0,0 +1,306 @@
+package server_repo
+
+import (
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+const (
+	// ChecksumExt расширение файла с контрольной суммой снимка (формат sha256sum)
+	ChecksumExt = ".sha256"
+
+	backupPrefix     = "server-"
+	backupExt        = ".db"
+	backupTimeLayout = "20060102-150405"
+)
+
+// requiredTables таблицы, без которых снимок не считается базой сервера
+var requiredTables = []string{"users", "storage", "sessions"}
+
+// Backup создает согласованный снимок базы с помощью VACUUM INTO, не останавливая работу сервера.
+// Рядом со снимком записывается файл контрольной суммы. Возвращает sha256 снимка
+func (s *ServerStorage) Backup(dst string) (string, error) {
+	if _, err := os.Stat(dst); err == nil {
+		return "", fmt.Errorf("%w: %s", models.ErrBackupExists, dst)
+	}
+
+	err := os.MkdirAll(filepath.Dir(dst), 0774)
+	if err != nil {
+		return "", err
+	}
+
+	// снимок пишется во временный файл, чтобы прерванное копирование не оставило файл, похожий на готовый снимок
+	tmp := dst + ".tmp"
+	_ = os.Remove(tmp)
+	s.mu.RLock()
+	_, err = s.db.Exec(`VACUUM INTO $1`, tmp)
+	s.mu.RUnlock()
+	if err != nil {
+		return "", err
+	}
+
+	sum, err := fileChecksum(tmp)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.Rename(tmp, dst)
+	if err != nil {
+		return "", err
+	}
+
+	err = os.WriteFile(dst+ChecksumExt, []byte(fmt.Sprintf("%s  %s\n", sum, filepath.Base(dst))), 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return sum, nil
+}
+
+// Close закрывает соединение с базой
+func (s *ServerStorage) Close() error {
+	return s.db.Close()
+}
+
+// VerifyBackup проверяет контрольную сумму снимка, целостность базы и наличие таблиц сервера
+func VerifyBackup(path string) error {
+	return verifyBackupFile(path, path+ChecksumExt)
+}
+
+// verifyBackupFile проверяет файл базы path по контрольной сумме из sumFile, которая может относиться к другому
+// файлу, например к снимку, копией которого является path
+func verifyBackupFile(path, sumFile string) error {
+	expected, err := os.ReadFile(sumFile)
+	if err != nil {
+		return err
+	}
+	fields := strings.Fields(string(expected))
+	if len(fields) == 0 {
+		return fmt.Errorf("%w: empty checksum file", models.ErrInvalidBackup)
+	}
+
+	sum, err := fileChecksum(path)
+	if err != nil {
+		return err
+	}
+	if sum != fields[0] {
+		return fmt.Errorf("%w: checksum mismatch", models.ErrInvalidBackup)
+	}
+
+	db, err := sql.Open("sqlite3", "file:"+path+"?mode=ro")
+	if err != nil {
+		return err
+	}
+	defer db.Close()
+
+	var result string
+	err = db.QueryRow(`PRAGMA integrity_check`).Scan(&result)
+	if err != nil {
+		return fmt.Errorf("%w: %s", models.ErrInvalidBackup, err)
+	}
+	if result != "ok" {
+		return fmt.Errorf("%w: integrity check: %s", models.ErrInvalidBackup, result)
+	}
+
+	for _, table := range requiredTables {
+		var c int
+		err = db.QueryRow(`select COUNT(*) from sqlite_master where type='table' AND name=$1`, table).Scan(&c)
+		if err != nil {
+			return err
+		}
+		if c == 0 {
+			return fmt.Errorf("%w: no table %s", models.ErrInvalidBackup, table)
+		}
+	}
+
+	return nil
+}
+
+// RestoreBackup проверяет снимок и заменяет им базу dbPath. Текущая база сохраняется рядом с суффиксом
+// .before-restore-<время>, путь к ней возвращается (пустой, если базы не было). Пока база открыта запущенным
+// сервером, восстановление отклоняется с models.ErrDatabaseLocked
+func RestoreBackup(snapshot, dbPath string, now time.Time) (string, error) {
+	err := VerifyBackup(snapshot)
+	if err != nil {
+		return "", err
+	}
+
+	lock, err := LockDatabase(dbPath)
+	if err != nil {
+		return "", err
+	}
+	defer lock.Close()
+
+	// копия проверяется заново: снимок мог измениться после проверки или записаться на диск с ошибкой
+	tmp := dbPath + ".restore"
+	err = copyFile(snapshot, tmp)
+	if err == nil {
+		err = verifyBackupFile(tmp, snapshot+ChecksumExt)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+
+	var saved string
+	if _, err = os.Stat(dbPath); err == nil {
+		saved = dbPath + ".before-restore-" + now.UTC().Format(backupTimeLayout)
+		err = saveDatabase(dbPath, saved)
+		if err != nil {
+			os.Remove(tmp)
+			return "", err
+		}
+	}
+
+	// rename в пределах одного каталога атомарен, поэтому база либо старая, либо целиком восстановленная
+	err = os.Rename(tmp, dbPath)
+	if err != nil {
+		os.Remove(tmp)
+		if saved != "" {
+			os.Rename(saved, dbPath)
+		}
+		return "", err
+	}
+
+	return saved, nil
+}
+
+// saveDatabase переносит базу в dst вместе с журналами SQLite, чтобы незавершенный журнал прежней базы
+// не был применен к восстановленной. Существующий dst не перезаписывается
+func saveDatabase(dbPath, dst string) error {
+	if _, err := os.Stat(dst); err == nil {
+		return fmt.Errorf("%w: %s", models.ErrBackupExists, dst)
+	}
+
+	err := os.Rename(dbPath, dst)
+	if err != nil {
+		return err
+	}
+
+	for _, ext := range []string{"-journal", "-wal", "-shm"} {
+		if _, err = os.Stat(dbPath + ext); err != nil {
+			continue
+		}
+		err = os.Rename(dbPath+ext, dst+ext)
+		if err != nil {
+			os.Rename(dst, dbPath)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// BackupWatcher создает снимки базы с интервалом из конфигурации и удаляет старые, оставляя cfg.Keep последних.
+// Завершается после закрытия stop
+func (s *ServerStorage) BackupWatcher(cfg models.BackupConfig, lg *slog.Logger, stop <-chan struct{}, wg *sync.WaitGroup) {
+	defer wg.Done()
+
+	ticker := time.NewTicker(cfg.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-stop:
+			return
+		case t := <-ticker.C:
+			path := filepath.Join(cfg.Dir, backupPrefix+t.UTC().Format(backupTimeLayout)+backupExt)
+			sum, err := s.Backup(path)
+			if err != nil {
+				lg.Error("scheduled backup failed", "path", path, "err", err)
+				continue
+			}
+			lg.Info("backup created", "path", path, "sha256", sum)
+
+			err = PruneBackups(cfg.Dir, cfg.Keep)
+			if err != nil {
+				lg.Error("can't remove old backups", "dir", cfg.Dir, "err", err)
+			}
+		}
+	}
+}
+
+// PruneBackups удаляет снимки, созданные BackupWatcher, кроме keep последних. При keep <= 0 снимки не удаляются
+func PruneBackups(dir string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+
+	names, err := filepath.Glob(filepath.Join(dir, backupPrefix+"*"+backupExt))
+	if err != nil {
+		return err
+	}
+	if len(names) <= keep {
+		return nil
+	}
+
+	// время в имени файла сортируется лексикографически
+	sort.Strings(names)
+	for _, name := range names[:len(names)-keep] {
+		err = os.Remove(name)
+		if err != nil {
+			return err
+		}
+		err = os.Remove(name + ChecksumExt)
+		if err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func fileChecksum(path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	_, err = io.Copy(h, f)
+	if err != nil {
+		return "", err
+	}
+
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+func copyFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0664)
+	if err != nil {
+		return err
+	}
+
+	_, err = io.Copy(out, in)
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	err = out.Sync()
+	if err != nil {
+		out.Close()
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,118 @@
+package server_repo
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerStorage_Backup(t *testing.T) {
+	dir := t.TempDir()
+
+	var storage ServerStorage
+	err := storage.Init(filepath.Join(dir, "server.db"))
+	assert.Nil(t, err)
+	defer storage.Close()
+
+	id, err := storage.CreateUser("q", "q")
+	assert.Nil(t, err)
+
+	snapshot := filepath.Join(dir, "backups", "snapshot.db")
+	_, err = storage.Backup(snapshot)
+	assert.Nil(t, err)
+
+	_, err = storage.Backup(snapshot)
+	assert.Equalf(t, true, errors.Is(err, models.ErrBackupExists), "existing file is not overwritten")
+
+	corrupted := filepath.Join(dir, "corrupted.db")
+	_ = copyFile(snapshot, corrupted)
+	_ = copyFile(snapshot+ChecksumExt, corrupted+ChecksumExt)
+	f, _ := os.OpenFile(corrupted, os.O_WRONLY|os.O_APPEND, 0)
+	f.Write([]byte("garbage"))
+	f.Close()
+
+	tests := []struct {
+		description string
+		path        string
+		wantErr     error
+	}{
+		{description: "valid snapshot", path: snapshot, wantErr: nil},
+		{description: "checksum mismatch", path: corrupted, wantErr: models.ErrInvalidBackup},
+	}
+	for _, tt := range tests {
+		err = VerifyBackup(tt.path)
+		assert.Equalf(t, true, errors.Is(err, tt.wantErr), tt.description)
+	}
+
+	// копия при восстановлении сверяется с контрольной суммой исходного снимка
+	assert.Nil(t, verifyBackupFile(snapshot, snapshot+ChecksumExt))
+	err = verifyBackupFile(corrupted, snapshot+ChecksumExt)
+	assert.Equalf(t, true, errors.Is(err, models.ErrInvalidBackup), "copy differs from the snapshot")
+
+	restored := filepath.Join(dir, "restored", "server.db")
+	_ = os.MkdirAll(filepath.Dir(restored), 0774)
+	_ = os.WriteFile(restored, []byte("old database"), 0664)
+
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	_, err = RestoreBackup(corrupted, restored, now)
+	assert.Equalf(t, true, errors.Is(err, models.ErrInvalidBackup), "corrupted snapshot is not restored")
+
+	lock, err := LockDatabase(restored)
+	assert.Nil(t, err)
+	_, err = RestoreBackup(snapshot, restored, now)
+	assert.Equalf(t, true, errors.Is(err, models.ErrDatabaseLocked), "database of a running server is not replaced")
+	lock.Close()
+
+	_ = os.WriteFile(restored+"-journal", []byte("old journal"), 0664)
+	saved, err := RestoreBackup(snapshot, restored, now)
+	assert.Nil(t, err)
+	assert.Equal(t, restored+".before-restore-20260102-030405", saved)
+	old, _ := os.ReadFile(saved)
+	assert.Equalf(t, "old database", string(old), "previous database kept")
+	journal, _ := os.ReadFile(saved + "-journal")
+	assert.Equalf(t, "old journal", string(journal), "journal moved with the previous database")
+
+	// прежняя сохраненная база не перезаписывается, а временная копия снимка удаляется
+	_, err = RestoreBackup(snapshot, restored, now)
+	assert.Equalf(t, true, errors.Is(err, models.ErrBackupExists), "saved database is not overwritten")
+	old, _ = os.ReadFile(saved)
+	assert.Equal(t, "old database", string(old))
+	_, err = os.Stat(restored + ".restore")
+	assert.Equalf(t, true, os.IsNotExist(err), "temporary copy removed")
+
+	var restoredStorage ServerStorage
+	err = restoredStorage.Init(restored)
+	assert.Nil(t, err)
+	defer restoredStorage.Close()
+
+	restoredID, _, err := restoredStorage.CheckUser("q")
+	assert.Nil(t, err)
+	assert.Equalf(t, id, restoredID, "data restored")
+}
+
+func TestPruneBackups(t *testing.T) {
+	dir := t.TempDir()
+	names := []string{"server-20260101-000000.db", "server-20260102-000000.db", "server-20260103-000000.db"}
+	for _, name := range names {
+		_ = os.WriteFile(filepath.Join(dir, name), nil, 0664)
+		_ = os.WriteFile(filepath.Join(dir, name+ChecksumExt), nil, 0664)
+	}
+	_ = os.WriteFile(filepath.Join(dir, "manual.db"), nil, 0664)
+
+	err := PruneBackups(dir, 2)
+	assert.Nil(t, err)
+
+	left, _ := filepath.Glob(filepath.Join(dir, "*"))
+	assert.Equal(t, []string{
+		filepath.Join(dir, "manual.db"),
+		filepath.Join(dir, names[1]),
+		filepath.Join(dir, names[1]+ChecksumExt),
+		filepath.Join(dir, names[2]),
+		filepath.Join(dir, names[2]+ChecksumExt),
+	}, left)
+}
@@ -0,0 +1,89 @@
+package server_repo
+
+import "sync"
+
+// feedBuffer число операций, которые подписчик может не забрать, прежде чем его подписка будет закрыта
+const feedBuffer = 100
+
+// changeFeed рассылает операции, выполненные ProcessingWatcher, подписчикам пользователя (поток Watch gRPC API).
+// Рассылка не блокирует очередь обработки: подписка отстающего подписчика закрывается, и он должен заново
+// получить список записей
+type changeFeed struct {
+	mu     sync.Mutex
+	subs   map[string]map[chan ProcessingTuple]struct{}
+	closed bool
+}
+
+func newChangeFeed() *changeFeed {
+	return &changeFeed{subs: make(map[string]map[chan ProcessingTuple]struct{})}
+}
+
+// subscribe подписывает на операции пользователя. Канал закрывается при отставании подписчика и остановке сервера,
+// cancel отменяет подписку
+func (f *changeFeed) subscribe(user string) (<-chan ProcessingTuple, func()) {
+	ch := make(chan ProcessingTuple, feedBuffer)
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.closed {
+		close(ch)
+		return ch, func() {}
+	}
+	if f.subs[user] == nil {
+		f.subs[user] = make(map[chan ProcessingTuple]struct{})
+	}
+	f.subs[user][ch] = struct{}{}
+
+	return ch, func() {
+		f.mu.Lock()
+		defer f.mu.Unlock()
+		f.remove(user, ch)
+	}
+}
+
+// publish передает выполненную операцию подписчикам ее пользователя
+func (f *changeFeed) publish(el ProcessingTuple) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	for ch := range f.subs[el.User] {
+		select {
+		case ch <- el:
+		default:
+			f.remove(el.User, ch)
+		}
+	}
+}
+
+// close закрывает все подписки, новые подписки сразу получают закрытый канал
+func (f *changeFeed) close() {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.closed = true
+	for user, subs := range f.subs {
+		for ch := range subs {
+			f.remove(user, ch)
+		}
+	}
+}
+
+// stopped сообщает, закрыта ли рассылка вызовом close
+func (f *changeFeed) stopped() bool {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	return f.closed
+}
+
+// remove удаляет подписку и закрывает ее канал. Вызывается под f.mu
+func (f *changeFeed) remove(user string, ch chan ProcessingTuple) {
+	if _, ok := f.subs[user][ch]; !ok {
+		return
+	}
+
+	delete(f.subs[user], ch)
+	if len(f.subs[user]) == 0 {
+		delete(f.subs, user)
+	}
+	close(ch)
+}
@@ -0,0 +1,464 @@
+package server_repo
+
+import (
+	"context"
+	"errors"
+	"log/slog"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/server"
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/pb"
+	"github.com/google/uuid"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/emptypb"
+)
+
+// watchTokenCheck наибольший период проверки сессии потока Watch, чтобы отозванная сессия не продолжала получать
+// изменения, и продления ее токена
+const watchTokenCheck = time.Minute
+
+// publicMethods методы gRPC API, которые не требуют токена
+var publicMethods = map[string]bool{
+	pb.Keeper_Register_FullMethodName: true,
+	pb.Keeper_Auth_FullMethodName:     true,
+}
+
+type rpcContextKey int
+
+const (
+	rpcLoggerKey rpcContextKey = iota
+	rpcRequestIDKey
+	rpcUserKey
+)
+
+// rpcUser пользователь, прошедший проверку токена в rpcAuthUnary или rpcAuthStream
+type rpcUser struct {
+	id      string
+	session string
+}
+
+// keeperService реализация gRPC API поверх той же логики, хранилища и очереди обработки, что и REST API
+type keeperService struct {
+	pb.UnimplementedKeeperServer
+	s *Server
+}
+
+// SetupGRPC создает gRPC сервер с проверкой токенов и регистрирует в нем Keeper API.
+// TLS настраивается так же, как для REST API
+func (s *Server) SetupGRPC() error {
+	var opts []grpc.ServerOption
+	if s.cfg.TLS.Enabled() {
+		tlsCfg, err := s.cfg.TLS.ServerConfig()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, grpc.Creds(credentials.NewTLS(tlsCfg)))
+	}
+	opts = append(opts,
+		grpc.ChainUnaryInterceptor(s.rpcLogUnary, s.rpcAuthUnary),
+		grpc.ChainStreamInterceptor(s.rpcLogStream, s.rpcAuthStream),
+	)
+
+	s.rpc = grpc.NewServer(opts...)
+	pb.RegisterKeeperServer(s.rpc, keeperService{s: s})
+	return nil
+}
+
+// ListenGRPC запускает gRPC API на адресе grpc_host из конфигурации
+func (s *Server) ListenGRPC() error {
+	ln, err := net.Listen("tcp", s.cfg.GRPCAddr)
+	if err != nil {
+		return err
+	}
+
+	return s.ServeGRPC(ln)
+}
+
+// ServeGRPC запускает gRPC API на переданном listener
+func (s *Server) ServeGRPC(ln net.Listener) error {
+	return s.rpc.Serve(ln)
+}
+
+// rpcLogUnary сохраняет в контексте логгер с id запроса и пишет строку лога по каждому вызову, как accessLog
+func (s *Server) rpcLogUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+
+	start := time.Now()
+	ctx = s.rpcContext(ctx)
+
+	res, err := handler(ctx, req)
+	s.logRPC(ctx, info.FullMethod, start, err)
+	return res, err
+}
+
+// rpcLogStream аналог rpcLogUnary для потоковых вызовов, строка лога пишется по завершении потока
+func (s *Server) rpcLogStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+
+	start := time.Now()
+	ctx := s.rpcContext(ss.Context())
+
+	err := handler(srv, contextStream{ServerStream: ss, ctx: ctx})
+	s.logRPC(ctx, info.FullMethod, start, err)
+	return err
+}
+
+// rpcAuthUnary проверяет токен из метаданных authorization, как authorize для REST API
+func (s *Server) rpcAuthUnary(ctx context.Context, req any, info *grpc.UnaryServerInfo,
+	handler grpc.UnaryHandler) (any, error) {
+
+	if publicMethods[info.FullMethod] {
+		return handler(ctx, req)
+	}
+
+	ctx, err := s.authorizeRPC(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return handler(ctx, req)
+}
+
+// rpcAuthStream аналог rpcAuthUnary для потоковых вызовов
+func (s *Server) rpcAuthStream(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo,
+	handler grpc.StreamHandler) error {
+
+	ctx, err := s.authorizeRPC(ss.Context())
+	if err != nil {
+		return err
+	}
+	return handler(srv, contextStream{ServerStream: ss, ctx: ctx})
+}
+
+// authorizeRPC проверяет токен вызова и обновляет время последнего обращения его сессии
+func (s *Server) authorizeRPC(ctx context.Context) (context.Context, error) {
+	token := metadataValue(ctx, pb.MetadataAuthorization)
+	id, session, err := logic.CheckToken(token, s.storage)
+	if err != nil {
+		return ctx, tokenError(err)
+	}
+
+	err = logic.TouchSession(session, peerIP(ctx), s.storage)
+	if err != nil {
+		rpcLogger(ctx).Error("can't update session", "err", err)
+	}
+
+	return context.WithValue(ctx, rpcUserKey, rpcUser{id: id, session: session}), nil
+}
+
+// Register регистрирует пользователя, как хендлер /api/v1/registration
+func (k keeperService) Register(ctx context.Context, req *pb.UserRequest) (*pb.UserResponse, error) {
+	r := req.Request()
+	if !r.Valid() {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadRequest.Error())
+	}
+
+	res, err := k.s.signUp(rpcLogger(ctx), peerIP(ctx), r)
+	switch {
+	case errors.Is(err, models.ErrTooManyRequests):
+		return nil, limitError(ctx, err)
+	case errors.Is(err, models.ErrUserConflict):
+		return nil, status.Error(codes.AlreadyExists, err.Error())
+	case err != nil:
+		rpcLogger(ctx).Error("registration failed", "err", err)
+		return nil, status.Error(codes.Internal, models.ErrInternalServerError.Error())
+	}
+
+	return &pb.UserResponse{Token: res.Token, RecoveryKey: res.RecoveryKey}, nil
+}
+
+// Auth авторизует пользователя, как хендлер /api/v1/auth. Если нужен второй фактор, возвращает FailedPrecondition
+func (k keeperService) Auth(ctx context.Context, req *pb.UserRequest) (*pb.UserResponse, error) {
+	r := req.Request()
+	if !r.Valid() {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadRequest.Error())
+	}
+
+	res, err := k.s.signIn(rpcLogger(ctx), peerIP(ctx), pb.Keeper_Auth_FullMethodName, r)
+	switch {
+	case errors.Is(err, models.ErrTooManyRequests):
+		return nil, limitError(ctx, err)
+	case errors.Is(err, models.ErrSecondFactorRequired):
+		return nil, status.Error(codes.FailedPrecondition, err.Error())
+	case errors.Is(err, models.ErrUserDataConflict), errors.Is(err, models.ErrInvalidCode):
+		return nil, status.Error(codes.PermissionDenied, err.Error())
+	case err != nil:
+		rpcLogger(ctx).Error("authorization failed", "err", err)
+		return nil, status.Error(codes.Internal, models.ErrInternalServerError.Error())
+	}
+
+	return &pb.UserResponse{Token: res.Token}, nil
+}
+
+// List возвращает записи пользователя, как хендлер GET /api/v1/items
+func (k keeperService) List(ctx context.Context, req *pb.ListRequest) (*pb.ListResponse, error) {
+	user := rpcUserFrom(ctx)
+
+	var (
+		res []models.UserData
+		err error
+	)
+	if req.GetFolder() != "" {
+		res, err = logic.GetFolder(user.id, req.GetFolder(), k.s.storage)
+	} else {
+		res, err = logic.GetAll(user.id, k.s.storage)
+	}
+	if err != nil {
+		rpcLogger(ctx).Error("list failed", "err", err)
+		return nil, status.Error(codes.Internal, models.ErrInternalServerError.Error())
+	}
+
+	items := make([]*pb.Item, 0, len(res))
+	for _, el := range res {
+		items = append(items, pb.NewItem(el))
+	}
+	return &pb.ListResponse{Items: items}, nil
+}
+
+// Set ставит в очередь добавление записи, как хендлер POST /api/v1/items
+func (k keeperService) Set(ctx context.Context, req *pb.Item) (*emptypb.Empty, error) {
+	r := req.UserData()
+	if !r.Valid() {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadRequest.Error())
+	}
+
+	k.s.enqueueRPC(ctx, SetOperation, r, models.AuditEvent{Event: models.AuditRecordCreate, Details: r.ID})
+	return &emptypb.Empty{}, nil
+}
+
+// Update ставит в очередь изменение записи, как хендлер PATCH /api/v1/items
+func (k keeperService) Update(ctx context.Context, req *pb.Item) (*emptypb.Empty, error) {
+	r := req.UserData()
+	if !r.Valid() {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadRequest.Error())
+	}
+
+	k.s.enqueueRPC(ctx, UpdateOperation, r, models.AuditEvent{Event: models.AuditRecordUpdate, Details: r.ID})
+	return &emptypb.Empty{}, nil
+}
+
+// Delete ставит в очередь удаление записи, как хендлер DELETE /api/v1/items
+func (k keeperService) Delete(ctx context.Context, req *pb.DeleteRequest) (*emptypb.Empty, error) {
+	r := models.DeleteRequest{ID: req.GetId()}
+	if !r.Valid() {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadRequest.Error())
+	}
+
+	k.s.enqueueRPC(ctx, DeleteOperation, r, models.AuditEvent{Event: models.AuditRecordDelete, Details: r.ID})
+	return &emptypb.Empty{}, nil
+}
+
+// Move ставит в очередь перемещение записей, как хендлер PATCH /api/v1/items/move
+func (k keeperService) Move(ctx context.Context, req *pb.MoveRequest) (*emptypb.Empty, error) {
+	r := models.MoveRequest{IDs: req.GetIds(), Folder: req.GetFolder()}
+	if !r.Valid() {
+		return nil, status.Error(codes.InvalidArgument, models.ErrBadRequest.Error())
+	}
+
+	k.s.enqueueRPC(ctx, MoveOperation, r, models.AuditEvent{Event: models.AuditRecordMove,
+		Details: strings.Join(r.IDs, ",") + " -> " + r.Folder})
+	return &emptypb.Empty{}, nil
+}
+
+// Watch передает изменения записей пользователя после их обработки очередью. Поток живет дольше токена, с которым
+// был открыт: до истечения токена клиенту передается новый токен той же сессии (TOKEN), и сессия продлевается.
+// Поток завершается с Aborted, если клиент не успевает принимать изменения, с Unavailable при остановке сервера
+// и с Unauthenticated при отзыве сессии
+func (k keeperService) Watch(_ *pb.WatchRequest, stream pb.Keeper_WatchServer) error {
+	ctx := stream.Context()
+	user := rpcUserFrom(ctx)
+
+	changes, cancel := k.s.feed.subscribe(user.id)
+	defer cancel()
+
+	// при коротком времени жизни токена проверка чаще, чтобы токен успевал продлеваться
+	interval := watchTokenCheck
+	if d := logic.TokenTTL() / 4; d < interval {
+		interval = d
+	}
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return status.FromContextError(ctx.Err()).Err()
+
+		case <-ticker.C:
+			token, err := logic.RefreshToken(user.id, user.session, 2*interval, k.s.storage)
+			if err != nil {
+				return tokenError(err)
+			}
+			if token == "" {
+				continue
+			}
+
+			err = logic.TouchSession(user.session, peerIP(ctx), k.s.storage)
+			if err != nil {
+				rpcLogger(ctx).Error("can't update session", "err", err)
+			}
+			err = stream.Send(&pb.Change{Kind: pb.Change_TOKEN, Token: token})
+			if err != nil {
+				return err
+			}
+
+		case el, ok := <-changes:
+			if !ok {
+				if k.s.feed.stopped() {
+					return status.Error(codes.Unavailable, "server is shutting down")
+				}
+				return status.Error(codes.Aborted, "change feed overflow, list records and watch again")
+			}
+
+			change, ok := newChange(el)
+			if !ok {
+				continue
+			}
+			err := stream.Send(change)
+			if err != nil {
+				return err
+			}
+		}
+	}
+}
+
+// enqueueRPC ставит операцию пользователя вызова в очередь обработки и записывает событие в журнал аудита
+func (s *Server) enqueueRPC(ctx context.Context, operation string, data models.Validatable, e models.AuditEvent) {
+	user := rpcUserFrom(ctx)
+	requestID, _ := ctx.Value(rpcRequestIDKey).(string)
+
+	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[operation], Data: data, User: user.id,
+		RequestID: requestID}
+
+	e.User = user.id
+	s.recordAudit(rpcLogger(ctx), peerIP(ctx), user.session, e)
+}
+
+// rpcContext дополняет контекст вызова id запроса (из метаданных x-request-id или новым) и логгером с ним
+func (s *Server) rpcContext(ctx context.Context) context.Context {
+	id := metadataValue(ctx, pb.MetadataRequestID)
+	if id == "" {
+		id = uuid.NewString()
+	}
+
+	ctx = context.WithValue(ctx, rpcRequestIDKey, id)
+	return context.WithValue(ctx, rpcLoggerKey, s.lg.With("request_id", id))
+}
+
+// logRPC пишет строку лога вызова и учитывает его в метриках. Тела запросов и ответов не логируются
+func (s *Server) logRPC(ctx context.Context, method string, start time.Time, err error) {
+	code := status.Code(err).String()
+	rpcLogger(ctx).Info("rpc",
+		"method", method,
+		"code", code,
+		"latency", time.Since(start),
+		"ip", peerIP(ctx),
+	)
+	s.metrics.observeRPC(method, code, start)
+}
+
+// newChange преобразует выполненную операцию очереди в сообщение потока Watch
+func newChange(el ProcessingTuple) (*pb.Change, bool) {
+	switch el.Operation {
+	case ProcessingOperations[SetOperation], ProcessingOperations[UpdateOperation]:
+		r, ok := el.Data.(models.UserData)
+		if !ok {
+			return nil, false
+		}
+		kind := pb.Change_SET
+		if el.Operation == ProcessingOperations[UpdateOperation] {
+			kind = pb.Change_UPDATE
+		}
+		return &pb.Change{Kind: kind, Item: pb.NewItem(r)}, true
+
+	case ProcessingOperations[DeleteOperation]:
+		r, ok := el.Data.(models.DeleteRequest)
+		return &pb.Change{Kind: pb.Change_DELETE, Ids: []string{r.ID}}, ok
+
+	case ProcessingOperations[MoveOperation]:
+		r, ok := el.Data.(models.MoveRequest)
+		return &pb.Change{Kind: pb.Change_MOVE, Ids: r.IDs, Folder: r.Folder}, ok
+	}
+
+	return nil, false
+}
+
+// tokenError возвращает статус gRPC для ошибки проверки токена, аналог tokenErrorStatus
+func tokenError(err error) error {
+	if errors.Is(err, models.ErrInvalidToken) {
+		return status.Error(codes.PermissionDenied, err.Error())
+	}
+	if errors.Is(err, models.ErrExpiredToken) || errors.Is(err, models.ErrRevokedSession) {
+		return status.Error(codes.Unauthenticated, err.Error())
+	}
+
+	return status.Error(codes.Internal, models.ErrInternalServerError.Error())
+}
+
+// limitError возвращает ResourceExhausted и передает время до снятия блокировки в trailer retry-after,
+// аналог sendLimitError
+func limitError(ctx context.Context, err error) error {
+	var limitErr *models.RateLimitError
+	if errors.As(err, &limitErr) {
+		seconds := strconv.Itoa(retryAfterSeconds(limitErr.RetryAfter))
+		_ = grpc.SetTrailer(ctx, metadata.Pairs(pb.MetadataRetryAfter, seconds))
+	}
+
+	return status.Error(codes.ResourceExhausted, err.Error())
+}
+
+// rpcUserFrom возвращает пользователя, сохраненного в контексте проверкой токена
+func rpcUserFrom(ctx context.Context) rpcUser {
+	user, _ := ctx.Value(rpcUserKey).(rpcUser)
+	return user
+}
+
+// rpcLogger возвращает логгер текущего вызова
+func rpcLogger(ctx context.Context) *slog.Logger {
+	lg, ok := ctx.Value(rpcLoggerKey).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	return lg
+}
+
+// metadataValue возвращает первое значение ключа входящих метаданных
+func metadataValue(ctx context.Context, key string) string {
+	values := metadata.ValueFromIncomingContext(ctx, key)
+	if len(values) == 0 {
+		return ""
+	}
+	return values[0]
+}
+
+// peerIP возвращает ip клиента вызова
+func peerIP(ctx context.Context) string {
+	p, ok := peer.FromContext(ctx)
+	if !ok || p.Addr == nil {
+		return ""
+	}
+
+	host, _, err := net.SplitHostPort(p.Addr.String())
+	if err != nil {
+		return p.Addr.String()
+	}
+	return host
+}
+
+// contextStream grpc.ServerStream с контекстом, дополненным перехватчиками
+type contextStream struct {
+	grpc.ServerStream
+	ctx context.Context
+}
+
+func (s contextStream) Context() context.Context {
+	return s.ctx
+}
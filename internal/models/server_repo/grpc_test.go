@@ -0,0 +1,284 @@
+package server_repo
+
+import (
+	"context"
+	"net"
+	"sync"
+	"testing"
+	"time"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/server"
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/pb"
+	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+// newTestGRPC запускает gRPC API сервера s в памяти и возвращает подключенный к нему клиент
+func newTestGRPC(t *testing.T, s *Server) pb.KeeperClient {
+	err := s.SetupGRPC()
+	assert.Nil(t, err)
+
+	ln := bufconn.Listen(1 << 20)
+	go s.ServeGRPC(ln)
+
+	conn, err := grpc.NewClient("passthrough:///bufconn",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return ln.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.Nil(t, err)
+
+	t.Cleanup(func() {
+		conn.Close()
+		s.rpc.Stop()
+	})
+	return pb.NewKeeperClient(conn)
+}
+
+// setTokenTTL задает время жизни токенов на время теста. Вызывается до запуска сервера, чтобы прежнее
+// значение восстанавливалось после его остановки
+func setTokenTTL(t *testing.T, ttl time.Duration) {
+	prev := logic.TokenTTL()
+	logic.SetTokenSettings("", ttl)
+	t.Cleanup(func() { logic.SetTokenSettings("", prev) })
+}
+
+func withToken(token string) context.Context {
+	return metadata.AppendToOutgoingContext(context.Background(), pb.MetadataAuthorization, token)
+}
+
+func TestServer_grpcAuth(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	cl := newTestGRPC(t, NewServer(WithStorage(store)))
+	ctx := context.Background()
+
+	res, err := cl.Register(ctx, &pb.UserRequest{Login: "q", Password: "q"})
+	assert.Nil(t, err)
+	assert.NotEmpty(t, res.GetToken())
+	assert.NotEmpty(t, res.GetRecoveryKey())
+
+	tests := []struct {
+		description string
+		call        func() error
+		code        codes.Code
+	}{
+		{
+			description: "register conflict",
+			call: func() error {
+				_, err := cl.Register(ctx, &pb.UserRequest{Login: "q", Password: "q"})
+				return err
+			},
+			code: codes.AlreadyExists,
+		},
+		{
+			description: "register bad request",
+			call: func() error {
+				_, err := cl.Register(ctx, &pb.UserRequest{Login: "w"})
+				return err
+			},
+			code: codes.InvalidArgument,
+		},
+		{
+			description: "auth success",
+			call: func() error {
+				_, err := cl.Auth(ctx, &pb.UserRequest{Login: "q", Password: "q"})
+				return err
+			},
+			code: codes.OK,
+		},
+		{
+			description: "auth wrong password",
+			call: func() error {
+				_, err := cl.Auth(ctx, &pb.UserRequest{Login: "q", Password: "w"})
+				return err
+			},
+			code: codes.PermissionDenied,
+		},
+		{
+			description: "list with token",
+			call: func() error {
+				_, err := cl.List(withToken(res.GetToken()), &pb.ListRequest{})
+				return err
+			},
+			code: codes.OK,
+		},
+		{
+			description: "list without token",
+			call: func() error {
+				_, err := cl.List(ctx, &pb.ListRequest{})
+				return err
+			},
+			code: codes.PermissionDenied,
+		},
+	}
+	for _, tt := range tests {
+		assert.Equalf(t, tt.code, status.Code(tt.call()), tt.description)
+	}
+
+	expiredToken, _ := logic.GenerateToken("user", "", 0.0)
+	stream, err := cl.Watch(withToken(expiredToken), &pb.WatchRequest{})
+	assert.Nil(t, err)
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestServer_grpcItems(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	procChan := make(ProcessingChan, 10)
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
+	cl := newTestGRPC(t, s)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.ProcessingWatcher(&wg)
+	defer func() {
+		close(procChan)
+		wg.Wait()
+	}()
+
+	store.CreateSession(models.Session{ID: "session", User: "user"})
+	token, _ := logic.GenerateToken("user", "session", 5.0)
+	ctx, cancel := context.WithTimeout(withToken(token), 5*time.Second)
+	defer cancel()
+
+	stream, err := cl.Watch(ctx, &pb.WatchRequest{})
+	assert.Nil(t, err)
+	// подписка создается обработчиком потока, изменения до нее не передаются
+	assert.Eventually(t, func() bool {
+		s.feed.mu.Lock()
+		defer s.feed.mu.Unlock()
+		return len(s.feed.subs["user"]) > 0
+	}, time.Second, 10*time.Millisecond)
+
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	_, err = cl.Set(ctx, &pb.Item{Id: "1", Data: "data", Metadata: "meta"})
+	assert.Nil(t, err)
+	_, err = cl.Update(ctx, pb.NewItem(models.UserData{ID: "1", Data: "new", ExpiresAt: &expires}))
+	assert.Nil(t, err)
+	_, err = cl.Set(ctx, &pb.Item{})
+	assert.Equal(t, codes.InvalidArgument, status.Code(err))
+
+	change, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, pb.Change_SET, change.GetKind())
+	assert.Equal(t, models.UserData{ID: "1", Data: "data", Comment: "meta"}, change.GetItem().UserData())
+
+	change, err = stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, pb.Change_UPDATE, change.GetKind())
+	assert.Equal(t, expires, change.GetItem().UserData().ExpiresAt.UTC())
+
+	list, err := cl.List(ctx, &pb.ListRequest{})
+	assert.Nil(t, err)
+	assert.Len(t, list.GetItems(), 1)
+	assert.Equal(t, "new", list.GetItems()[0].GetData())
+
+	_, err = cl.Delete(ctx, &pb.DeleteRequest{Id: "1"})
+	assert.Nil(t, err)
+	change, err = stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, pb.Change_DELETE, change.GetKind())
+	assert.Equal(t, []string{"1"}, change.GetIds())
+
+	// изменения пользователя не видны в потоках других пользователей
+	s.feed.publish(ProcessingTuple{Operation: ProcessingOperations[DeleteOperation],
+		Data: models.DeleteRequest{ID: "2"}, User: "other"})
+	s.Shutdown()
+	_, err = stream.Recv()
+	assert.Equal(t, codes.Unavailable, status.Code(err))
+}
+
+func TestServer_grpcWatchTokenRefresh(t *testing.T) {
+	setTokenTTL(t, 2*time.Second)
+
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	procChan := make(ProcessingChan, 10)
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
+	cl := newTestGRPC(t, s)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.ProcessingWatcher(&wg)
+	defer func() {
+		close(procChan)
+		wg.Wait()
+	}()
+
+	res, err := cl.Register(context.Background(), &pb.UserRequest{Login: "q", Password: "q"})
+	assert.Nil(t, err)
+	expires := time.Now().Add(2 * time.Second)
+
+	ctx, cancel := context.WithTimeout(withToken(res.GetToken()), 10*time.Second)
+	defer cancel()
+	stream, err := cl.Watch(ctx, &pb.WatchRequest{})
+	assert.Nil(t, err)
+
+	// новый токен той же сессии приходит до истечения токена, с которым открыт поток
+	change, err := stream.Recv()
+	assert.Nil(t, err)
+	assert.Equal(t, pb.Change_TOKEN, change.GetKind())
+	assert.True(t, time.Now().Before(expires))
+	token := change.GetToken()
+
+	time.Sleep(time.Until(expires) + 100*time.Millisecond)
+	_, err = cl.List(withToken(res.GetToken()), &pb.ListRequest{})
+	assert.Equalf(t, codes.Unauthenticated, status.Code(err), "initial token expired")
+
+	// поток продолжает передавать изменения после истечения исходного токена
+	_, err = cl.Set(withToken(token), &pb.Item{Id: "1", Data: "data"})
+	assert.Nil(t, err)
+	for change.GetKind() != pb.Change_SET {
+		change, err = stream.Recv()
+		assert.Nil(t, err)
+		if err != nil {
+			return
+		}
+	}
+
+	// отзыв сессии завершает поток
+	id, session, err := logic.CheckToken(token, store)
+	assert.Nil(t, err)
+	err = store.RevokeSession(session, id)
+	assert.Nil(t, err)
+	for err == nil {
+		change, err = stream.Recv()
+		assert.NotEqual(t, pb.Change_SET, change.GetKind())
+	}
+	assert.Equal(t, codes.Unauthenticated, status.Code(err))
+}
+
+func TestChangeFeed(t *testing.T) {
+	f := newChangeFeed()
+	slow, _ := f.subscribe("user")
+	fast, cancel := f.subscribe("user")
+
+	el := ProcessingTuple{Operation: ProcessingOperations[DeleteOperation], Data: models.DeleteRequest{ID: "1"},
+		User: "user"}
+	for i := 0; i < feedBuffer; i++ {
+		f.publish(el)
+		<-fast
+	}
+	// подписка, которая не забирает изменения, закрывается, остальные продолжают работать
+	f.publish(el)
+	assert.Len(t, slow, feedBuffer)
+	for range slow {
+	}
+	assert.Equal(t, el, <-fast)
+
+	cancel()
+	_, ok := <-fast
+	assert.False(t, ok)
+
+	f.close()
+	closed, _ := f.subscribe("user")
+	_, ok = <-closed
+	assert.False(t, ok)
+}
@@ -2,8 +2,10 @@ package server_repo
 
 import (
 	"errors"
-	"log"
 	"net/http"
+	"strconv"
+	"strings"
+	"time"
 
 	_ "github.com/azazel3ooo/keeper/docs"
 	logic "github.com/azazel3ooo/keeper/internal/logic/server"
@@ -20,6 +22,7 @@ import (
 // @Success      200	{object} models.UserResponse
 // @Failure      400
 // @Failure      409
+// @Failure      429
 // @Failure      500
 // @Router       /api/v1/registration [post]
 func (s *Server) registration(c *fiber.Ctx) error {
@@ -29,23 +32,18 @@ func (s *Server) registration(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	id, err := logic.Registration(req, s.storage)
-	if errors.Is(err, models.ErrUserConflict) {
+	res, err := s.signUp(requestLogger(c), c.IP(), req)
+	switch {
+	case errors.Is(err, models.ErrTooManyRequests):
+		return sendLimitError(c, err)
+	case errors.Is(err, models.ErrUserConflict):
 		return c.SendStatus(http.StatusConflict)
-	} else if err != nil {
-		log.Println(err)
-		return c.SendStatus(http.StatusInternalServerError)
-	}
-
-	token, err := logic.GenerateToken(id)
-	if err != nil {
-		log.Println(err)
+	case err != nil:
+		requestLogger(c).Error("registration failed", "err", err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
-	return c.Status(http.StatusOK).JSON(models.UserResponse{
-		Token: token,
-	})
+	return c.Status(http.StatusOK).JSON(res)
 }
 
 // authorization godoc
@@ -56,7 +54,9 @@ func (s *Server) registration(c *fiber.Ctx) error {
 // @Param        request body models.UserRequest true "Request structure"
 // @Success      200	{object} models.UserResponse
 // @Failure      400
+// @Failure      401	{object} models.SecondFactorResponse
 // @Failure      403
+// @Failure      429
 // @Failure      500
 // @Router       /api/v1/auth [post]
 func (s *Server) authorization(c *fiber.Ctx) error {
@@ -66,50 +66,85 @@ func (s *Server) authorization(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	id, err := logic.CheckUser(req, s.storage)
-	if errors.Is(err, models.ErrUserDataConflict) {
+	res, err := s.signIn(requestLogger(c), c.IP(), c.Route().Path, req)
+	switch {
+	case errors.Is(err, models.ErrTooManyRequests):
+		return sendLimitError(c, err)
+	case errors.Is(err, models.ErrSecondFactorRequired):
+		return c.Status(http.StatusUnauthorized).JSON(models.SecondFactorResponse{Method: "totp"})
+	case errors.Is(err, models.ErrUserDataConflict), errors.Is(err, models.ErrInvalidCode):
 		return c.SendStatus(http.StatusForbidden)
-	} else if err != nil {
+	case err != nil:
+		requestLogger(c).Error("authorization failed", "err", err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
-	token, err := logic.GenerateToken(id)
+	return c.Status(http.StatusOK).JSON(res)
+}
+
+// getAll godoc
+// @Description  handler for get full list of user data
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        folder query string false "Only records directly in this folder id, root for records outside folders"
+// @Success      200	{object} models.UserDataResponse
+// @Failure      401
+// @Failure      403
+// @Failure      500
+// @Router       /api/v1/items [get]
+func (s *Server) getAll(c *fiber.Ctx) error {
+	id, _, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var res []models.UserData
+	if folder := c.Query("folder"); folder != "" {
+		res, err = logic.GetFolder(id, folder, s.storage)
+	} else {
+		res, err = logic.GetAll(id, s.storage)
+	}
 	if err != nil {
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
-	return c.Status(http.StatusOK).JSON(models.UserResponse{
-		Token: token,
+	return c.Status(http.StatusOK).JSON(models.UserDataResponse{
+		Data: res,
 	})
 }
 
-// getAll godoc
-// @Description  handler for get full list of user data
+// getDue godoc
+// @Description  handler for get records whose password is due for rotation soon (overdue included), nearest first
 // @Tags         Auth
 // @Accept       json
 // @Produce      json
 // @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        days query int false "Days ahead (default 30, max 3650)"
 // @Success      200	{object} models.UserDataResponse
+// @Failure      400
 // @Failure      401
 // @Failure      403
 // @Failure      500
-// @Router       /api/v1/items [get]
-func (s *Server) getAll(c *fiber.Ctx) error {
-	id, err := logic.CheckToken(c.Get("Authorization"))
+// @Router       /api/v1/items/due [get]
+func (s *Server) getDue(c *fiber.Ctx) error {
+	id, _, err := s.authorize(c)
 	if err != nil {
-		status := http.StatusInternalServerError // default
-		if errors.Is(err, models.ErrInvalidToken) {
-			return c.SendStatus(http.StatusForbidden)
-		}
-		if errors.Is(err, models.ErrExpiredToken) {
-			return c.SendStatus(http.StatusUnauthorized)
-		}
+		return c.SendStatus(tokenErrorStatus(err))
+	}
 
-		return c.SendStatus(status)
+	var days int
+	if v := c.Query("days"); v != "" {
+		days, err = strconv.Atoi(v)
+		if err != nil {
+			return c.SendStatus(http.StatusBadRequest)
+		}
 	}
 
-	res, err := logic.GetAll(id, s.storage)
+	res, err := logic.GetDue(id, days, time.Now(), s.storage)
 	if err != nil {
+		requestLogger(c).Error("getDue failed", "err", err)
 		return c.SendStatus(http.StatusInternalServerError)
 	}
 
@@ -132,17 +167,9 @@ func (s *Server) getAll(c *fiber.Ctx) error {
 // @Failure      500
 // @Router       /api/v1/items [post]
 func (s *Server) set(c *fiber.Ctx) error {
-	id, err := logic.CheckToken(c.Get("Authorization"))
+	id, session, err := s.authorize(c)
 	if err != nil {
-		status := http.StatusInternalServerError // default
-		if errors.Is(err, models.ErrInvalidToken) {
-			return c.SendStatus(http.StatusForbidden)
-		}
-		if errors.Is(err, models.ErrExpiredToken) {
-			return c.SendStatus(http.StatusUnauthorized)
-		}
-
-		return c.SendStatus(status)
+		return c.SendStatus(tokenErrorStatus(err))
 	}
 
 	var req models.UserData
@@ -151,7 +178,9 @@ func (s *Server) set(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[SetOperation], Data: req, User: id}
+	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[SetOperation], Data: req, User: id,
+		RequestID: requestID(c)}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditRecordCreate, Details: req.ID})
 
 	return c.SendStatus(http.StatusOK)
 }
@@ -170,17 +199,9 @@ func (s *Server) set(c *fiber.Ctx) error {
 // @Failure      500
 // @Router       /api/v1/items [delete]
 func (s *Server) delete(c *fiber.Ctx) error {
-	id, err := logic.CheckToken(c.Get("Authorization"))
+	id, session, err := s.authorize(c)
 	if err != nil {
-		status := http.StatusInternalServerError // default
-		if errors.Is(err, models.ErrInvalidToken) {
-			return c.SendStatus(http.StatusForbidden)
-		}
-		if errors.Is(err, models.ErrExpiredToken) {
-			return c.SendStatus(http.StatusUnauthorized)
-		}
-
-		return c.SendStatus(status)
+		return c.SendStatus(tokenErrorStatus(err))
 	}
 
 	var req models.DeleteRequest
@@ -189,7 +210,9 @@ func (s *Server) delete(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[DeleteOperation], Data: req, User: id}
+	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[DeleteOperation], Data: req, User: id,
+		RequestID: requestID(c)}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditRecordDelete, Details: req.ID})
 
 	return c.SendStatus(http.StatusOK)
 }
@@ -208,17 +231,9 @@ func (s *Server) delete(c *fiber.Ctx) error {
 // @Failure      500
 // @Router       /api/v1/items [patch]
 func (s *Server) update(c *fiber.Ctx) error {
-	id, err := logic.CheckToken(c.Get("Authorization"))
+	id, session, err := s.authorize(c)
 	if err != nil {
-		status := http.StatusInternalServerError // default
-		if errors.Is(err, models.ErrInvalidToken) {
-			return c.SendStatus(http.StatusForbidden)
-		}
-		if errors.Is(err, models.ErrExpiredToken) {
-			return c.SendStatus(http.StatusUnauthorized)
-		}
-
-		return c.SendStatus(status)
+		return c.SendStatus(tokenErrorStatus(err))
 	}
 
 	var req models.UserData
@@ -227,7 +242,556 @@ func (s *Server) update(c *fiber.Ctx) error {
 		return c.SendStatus(http.StatusBadRequest)
 	}
 
-	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[UpdateOperation], Data: req, User: id}
+	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[UpdateOperation], Data: req, User: id,
+		RequestID: requestID(c)}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditRecordUpdate, Details: req.ID})
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// move godoc
+// @Description  handler for move records and folders into a folder (empty folder for root)
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.MoveRequest true "Request structure"
+// @Success      200
+// @Failure      400
+// @Failure      403
+// @Failure      401
+// @Failure      500
+// @Router       /api/v1/items/move [patch]
+func (s *Server) move(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.MoveRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[MoveOperation], Data: req, User: id,
+		RequestID: requestID(c)}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditRecordMove,
+		Details: strings.Join(req.IDs, ",") + " -> " + req.Folder})
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// enrollTOTP godoc
+// @Description  handler for start of two-factor authentication enrollment
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Success      200	{object} models.TOTPEnrollResponse
+// @Failure      401
+// @Failure      403
+// @Failure      409
+// @Failure      500
+// @Router       /api/v1/2fa/enroll [post]
+func (s *Server) enrollTOTP(c *fiber.Ctx) error {
+	id, _, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	res, err := logic.EnrollTOTP(id, s.storage)
+	if errors.Is(err, models.ErrTOTPEnabled) {
+		return c.SendStatus(http.StatusConflict)
+	} else if err != nil {
+		requestLogger(c).Error("enrollTOTP failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return c.Status(http.StatusOK).JSON(res)
+}
+
+// confirmTOTP godoc
+// @Description  handler for confirmation of two-factor authentication, returns recovery codes
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.TOTPConfirmRequest true "Request structure"
+// @Success      200	{object} models.TOTPConfirmResponse
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      409
+// @Failure      500
+// @Router       /api/v1/2fa/confirm [post]
+func (s *Server) confirmTOTP(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.TOTPConfirmRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	codes, err := logic.ConfirmTOTP(id, req.Code, s.storage)
+	switch {
+	case errors.Is(err, models.ErrTOTPNotEnrolled):
+		return c.SendStatus(http.StatusBadRequest)
+	case errors.Is(err, models.ErrInvalidCode):
+		return c.SendStatus(http.StatusForbidden)
+	case errors.Is(err, models.ErrTOTPEnabled):
+		return c.SendStatus(http.StatusConflict)
+	case err != nil:
+		requestLogger(c).Error("confirmTOTP failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditTOTPEnable})
+
+	return c.Status(http.StatusOK).JSON(models.TOTPConfirmResponse{RecoveryCodes: codes})
+}
+
+// disableTOTP godoc
+// @Description  handler for disable of two-factor authentication, requires current one-time code or recovery code
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.TOTPConfirmRequest true "Request structure"
+// @Success      200
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      409
+// @Failure      429
+// @Failure      500
+// @Router       /api/v1/2fa/disable [post]
+func (s *Server) disableTOTP(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.TOTPConfirmRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	limit := logic.AccountLimit(id)
+	err = logic.CheckLimits(s.storage, time.Now(), limit)
+	if err != nil {
+		return sendLimitError(c, err)
+	}
+
+	err = logic.DisableTOTP(id, req.Code, s.storage)
+	switch {
+	case errors.Is(err, models.ErrTOTPNotEnrolled):
+		return c.SendStatus(http.StatusConflict)
+	case errors.Is(err, models.ErrInvalidCode):
+		s.registerAuthFailure(c, []logic.Limit{limit})
+		return c.SendStatus(http.StatusForbidden)
+	case err != nil:
+		requestLogger(c).Error("disableTOTP failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditTOTPDisable})
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// getSessions godoc
+// @Description  handler for get list of active sessions (devices) of user
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Success      200	{object} models.SessionsResponse
+// @Failure      401
+// @Failure      403
+// @Failure      500
+// @Router       /api/v1/sessions [get]
+func (s *Server) getSessions(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	res, err := logic.GetSessions(id, session, s.storage)
+	if err != nil {
+		requestLogger(c).Error("getSessions failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return c.Status(http.StatusOK).JSON(models.SessionsResponse{
+		Sessions: res,
+	})
+}
+
+// revokeSession godoc
+// @Description  handler for revoke session by id or all sessions except current
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.RevokeSessionRequest true "Request structure"
+// @Success      200
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      404
+// @Failure      500
+// @Router       /api/v1/sessions [delete]
+func (s *Server) revokeSession(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.RevokeSessionRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	err = logic.RevokeSession(req, id, session, s.storage)
+	if errors.Is(err, models.ErrNotFound) {
+		return c.SendStatus(http.StatusNotFound)
+	} else if err != nil {
+		requestLogger(c).Error("revokeSession failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	details := req.ID
+	if req.All {
+		details = "all"
+	}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditSessionRevoke, Details: details})
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// changePassword godoc
+// @Description  handler for change password, revokes all other sessions of user
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.ChangePasswordRequest true "Request structure"
+// @Success      200
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      429
+// @Failure      500
+// @Router       /api/v1/account/password [patch]
+func (s *Server) changePassword(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.ChangePasswordRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	limit := logic.AccountLimit(id)
+	err = logic.CheckLimits(s.storage, time.Now(), limit)
+	if err != nil {
+		return sendLimitError(c, err)
+	}
+
+	err = logic.ChangePassword(req, id, session, s.storage)
+	if errors.Is(err, models.ErrUserDataConflict) {
+		s.registerAuthFailure(c, []logic.Limit{limit})
+		return c.SendStatus(http.StatusForbidden)
+	} else if err != nil {
+		requestLogger(c).Error("changePassword failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditPasswordChange})
 
 	return c.SendStatus(http.StatusOK)
 }
+
+// changeLogin godoc
+// @Description  handler for change login
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.ChangeLoginRequest true "Request structure"
+// @Success      200
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      409
+// @Failure      429
+// @Failure      500
+// @Router       /api/v1/account/login [patch]
+func (s *Server) changeLogin(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.ChangeLoginRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	limit := logic.AccountLimit(id)
+	err = logic.CheckLimits(s.storage, time.Now(), limit)
+	if err != nil {
+		return sendLimitError(c, err)
+	}
+
+	err = logic.ChangeLogin(req, id, s.storage)
+	switch {
+	case errors.Is(err, models.ErrUserDataConflict):
+		s.registerAuthFailure(c, []logic.Limit{limit})
+		return c.SendStatus(http.StatusForbidden)
+	case errors.Is(err, models.ErrUserConflict):
+		return c.SendStatus(http.StatusConflict)
+	case err != nil:
+		requestLogger(c).Error("changeLogin failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditLoginChange})
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// deleteAccount godoc
+// @Description  handler for delete account with all user data
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.DeleteAccountRequest true "Request structure"
+// @Success      200
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      429
+// @Failure      500
+// @Router       /api/v1/account [delete]
+func (s *Server) deleteAccount(c *fiber.Ctx) error {
+	id, _, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.DeleteAccountRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	limit := logic.AccountLimit(id)
+	err = logic.CheckLimits(s.storage, time.Now(), limit)
+	if err != nil {
+		return sendLimitError(c, err)
+	}
+
+	err = logic.CheckPassword(id, req.Password, s.storage)
+	if errors.Is(err, models.ErrUserDataConflict) {
+		s.registerAuthFailure(c, []logic.Limit{limit})
+		return c.SendStatus(http.StatusForbidden)
+	} else if err != nil {
+		requestLogger(c).Error("deleteAccount failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	// сессии отзываются сразу, а сами данные удаляются после уже поставленных в очередь операций
+	err = logic.RevokeSession(models.RevokeSessionRequest{All: true}, id, "", s.storage)
+	if err != nil {
+		requestLogger(c).Error("can't revoke sessions", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	s.processingChan <- ProcessingTuple{Operation: ProcessingOperations[DeleteAccountOperation], Data: req, User: id,
+		RequestID: requestID(c)}
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// recovery godoc
+// @Description  handler for reset password with recovery key, revokes all sessions of user and returns new recovery key
+// @Tags         All
+// @Accept       json
+// @Produce      json
+// @Param        request body models.RecoveryRequest true "Request structure"
+// @Success      200	{object} models.RecoveryKeyResponse
+// @Failure      400
+// @Failure      403
+// @Failure      429
+// @Failure      500
+// @Router       /api/v1/recovery [post]
+func (s *Server) recovery(c *fiber.Ctx) error {
+	var req models.RecoveryRequest
+	err := c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	limits := []logic.Limit{logic.IPLimit(c.IP()), logic.LoginLimit(req.Login)}
+	err = logic.CheckLimits(s.storage, time.Now(), limits...)
+	if err != nil {
+		return sendLimitError(c, err)
+	}
+
+	id, key, err := logic.RecoverAccount(req, s.storage)
+	if errors.Is(err, models.ErrUserDataConflict) {
+		s.registerAuthFailure(c, limits)
+		s.auditFailedLogin(c, models.UserRequest{Login: req.Login}, "invalid recovery key")
+		return c.SendStatus(http.StatusForbidden)
+	} else if err != nil {
+		requestLogger(c).Error("recovery failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	s.audit(c, "", models.AuditEvent{User: id, Event: models.AuditRecovery})
+
+	err = logic.ResetLimits(s.storage, logic.LoginLimit(req.Login))
+	if err != nil {
+		requestLogger(c).Error("can't reset attempts", "err", err)
+	}
+
+	return c.Status(http.StatusOK).JSON(models.RecoveryKeyResponse{RecoveryKey: key})
+}
+
+// regenerateRecoveryKey godoc
+// @Description  handler for issue new recovery key, previous key becomes invalid
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.RecoveryKeyRequest true "Request structure"
+// @Success      200	{object} models.RecoveryKeyResponse
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      429
+// @Failure      500
+// @Router       /api/v1/account/recovery [post]
+func (s *Server) regenerateRecoveryKey(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.RecoveryKeyRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	limit := logic.AccountLimit(id)
+	err = logic.CheckLimits(s.storage, time.Now(), limit)
+	if err != nil {
+		return sendLimitError(c, err)
+	}
+
+	key, err := logic.RegenerateRecoveryKey(id, req.Password, s.storage)
+	if errors.Is(err, models.ErrUserDataConflict) {
+		s.registerAuthFailure(c, []logic.Limit{limit})
+		return c.SendStatus(http.StatusForbidden)
+	} else if err != nil {
+		requestLogger(c).Error("regenerateRecoveryKey failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditRecoveryKeyRegenerate})
+
+	return c.Status(http.StatusOK).JSON(models.RecoveryKeyResponse{RecoveryKey: key})
+}
+
+// revokeRecoveryKey godoc
+// @Description  handler for revoke recovery key
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        request body models.RecoveryKeyRequest true "Request structure"
+// @Success      200
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      429
+// @Failure      500
+// @Router       /api/v1/account/recovery [delete]
+func (s *Server) revokeRecoveryKey(c *fiber.Ctx) error {
+	id, session, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var req models.RecoveryKeyRequest
+	err = c.BodyParser(&req)
+	if err != nil || !req.Valid() {
+		return c.SendStatus(http.StatusBadRequest)
+	}
+
+	limit := logic.AccountLimit(id)
+	err = logic.CheckLimits(s.storage, time.Now(), limit)
+	if err != nil {
+		return sendLimitError(c, err)
+	}
+
+	err = logic.RevokeRecoveryKey(id, req.Password, s.storage)
+	if errors.Is(err, models.ErrUserDataConflict) {
+		s.registerAuthFailure(c, []logic.Limit{limit})
+		return c.SendStatus(http.StatusForbidden)
+	} else if err != nil {
+		requestLogger(c).Error("revokeRecoveryKey failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+	s.audit(c, session, models.AuditEvent{User: id, Event: models.AuditRecoveryKeyRevoke})
+
+	return c.SendStatus(http.StatusOK)
+}
+
+// getAudit godoc
+// @Description  handler for get audit log of user, newest events first
+// @Tags         Auth
+// @Accept       json
+// @Produce      json
+// @Param 		 Authorization header string true "Insert your access token" default(<Add access token here>)
+// @Param        limit query int false "Max number of events (default 100, max 1000)"
+// @Success      200	{object} models.AuditResponse
+// @Failure      400
+// @Failure      401
+// @Failure      403
+// @Failure      500
+// @Router       /api/v1/audit [get]
+func (s *Server) getAudit(c *fiber.Ctx) error {
+	id, _, err := s.authorize(c)
+	if err != nil {
+		return c.SendStatus(tokenErrorStatus(err))
+	}
+
+	var limit int
+	if v := c.Query("limit"); v != "" {
+		limit, err = strconv.Atoi(v)
+		if err != nil {
+			return c.SendStatus(http.StatusBadRequest)
+		}
+	}
+
+	events, err := logic.GetAuditEvents(id, limit, s.storage)
+	if err != nil {
+		requestLogger(c).Error("getAudit failed", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	return c.Status(http.StatusOK).JSON(models.AuditResponse{
+		Events: events,
+	})
+}
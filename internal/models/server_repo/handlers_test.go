@@ -2,12 +2,16 @@ package server_repo
 
 import (
 	"bytes"
+	"encoding/json"
 	"log"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
+	otp "github.com/azazel3ooo/keeper/internal/logic/otp"
 	logic "github.com/azazel3ooo/keeper/internal/logic/server"
+	"github.com/azazel3ooo/keeper/internal/models"
 	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
 	"github.com/stretchr/testify/assert"
 )
@@ -105,14 +109,103 @@ func TestServer_authorization(t *testing.T) {
 	}
 }
 
+func TestServer_authorizationSecondFactor(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := NewServer(WithStorage(store))
+	s.SetupApp()
+
+	id, _ := store.CreateUser("q", "q")
+	enroll, _ := logic.EnrollTOTP(id, store)
+	key := otp.Key{Secret: enroll.Secret}
+	confirmCode, _ := key.Code(time.Now())
+	_, err := logic.ConfirmTOTP(id, confirmCode, store)
+	assert.Nil(t, err)
+	code, _ := key.Code(time.Now().Add(30 * time.Second))
+
+	tests := []struct {
+		description  string
+		req          string
+		expectedCode int
+	}{
+		{
+			description:  "second factor required",
+			expectedCode: http.StatusUnauthorized,
+			req:          "{\"login\":\"q\",\"password\":\"q\"}",
+		},
+		{
+			description:  "invalid code",
+			expectedCode: http.StatusForbidden,
+			req:          "{\"login\":\"q\",\"password\":\"q\",\"code\":\"1\"}",
+		},
+		{
+			description:  "success",
+			expectedCode: http.StatusOK,
+			req:          "{\"login\":\"q\",\"password\":\"q\",\"code\":\"" + code + "\"}",
+		},
+		{
+			description:  "replayed code",
+			expectedCode: http.StatusForbidden,
+			req:          "{\"login\":\"q\",\"password\":\"q\",\"code\":\"" + code + "\"}",
+		},
+	}
+	for _, tt := range tests {
+		b := bytes.NewBuffer([]byte(tt.req))
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth", b)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := s.app.Test(req, -1)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		assert.Equalf(t, tt.expectedCode, resp.StatusCode, tt.description)
+		err = resp.Body.Close()
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+func TestServer_authorizationLimits(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := NewServer(WithStorage(store))
+	s.SetupApp()
+
+	store.CreateUser("q", "q")
+	send := func(body string) (*http.Response, error) {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		return s.app.Test(req, -1)
+	}
+
+	for i := 0; i < logic.LoginFailuresThreshold; i++ {
+		resp, err := send("{\"login\":\"q\",\"password\":\"qqqqq\"}")
+		assert.Nil(t, err)
+		assert.Equalf(t, http.StatusForbidden, resp.StatusCode, "wrong password")
+		resp.Body.Close()
+	}
+
+	// после блокировки не принимается даже верный пароль
+	resp, err := send("{\"login\":\"q\",\"password\":\"q\"}")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusTooManyRequests, resp.StatusCode, "locked login")
+	assert.Equalf(t, "30", resp.Header.Get("Retry-After"), "locked login")
+	resp.Body.Close()
+}
+
 func TestServer_delete(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
 	procChan := make(ProcessingChan)
-	s := NewServer(WithProcessingChan(procChan))
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
 	s.SetupApp()
 
 	userID := "user"
-	testToken, _ := logic.GenerateToken(userID, 5.0)
-	expiredToken, _ := logic.GenerateToken("user_2", 0.0)
+	store.CreateSession(models.Session{ID: "session", User: userID})
+	testToken, _ := logic.GenerateToken(userID, "session", 5.0)
+	expiredToken, _ := logic.GenerateToken("user_2", "", 0.0)
 
 	tests := []struct {
 		description  string
@@ -177,8 +270,9 @@ func TestServer_getAll(t *testing.T) {
 	s.SetupApp()
 
 	userID := "user"
-	testToken, _ := logic.GenerateToken(userID, 5.0)
-	expiredToken, _ := logic.GenerateToken("user_2", 0.0)
+	store.CreateSession(models.Session{ID: "session", User: userID})
+	testToken, _ := logic.GenerateToken(userID, "session", 5.0)
+	expiredToken, _ := logic.GenerateToken("user_2", "", 0.0)
 
 	tests := []struct {
 		description  string
@@ -220,13 +314,16 @@ func TestServer_getAll(t *testing.T) {
 }
 
 func TestServer_set(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
 	procChan := make(ProcessingChan)
-	s := NewServer(WithProcessingChan(procChan))
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
 	s.SetupApp()
 
 	userID := "user"
-	testToken, _ := logic.GenerateToken(userID, 5.0)
-	expiredToken, _ := logic.GenerateToken("user_2", 0.0)
+	store.CreateSession(models.Session{ID: "session", User: userID})
+	testToken, _ := logic.GenerateToken(userID, "session", 5.0)
+	expiredToken, _ := logic.GenerateToken("user_2", "", 0.0)
 
 	tests := []struct {
 		description  string
@@ -285,13 +382,16 @@ func TestServer_set(t *testing.T) {
 }
 
 func TestServer_update(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
 	procChan := make(ProcessingChan)
-	s := NewServer(WithProcessingChan(procChan))
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
 	s.SetupApp()
 
 	userID := "user"
-	testToken, _ := logic.GenerateToken(userID, 5.0)
-	expiredToken, _ := logic.GenerateToken("user_2", 0.0)
+	store.CreateSession(models.Session{ID: "session", User: userID})
+	testToken, _ := logic.GenerateToken(userID, "session", 5.0)
+	expiredToken, _ := logic.GenerateToken("user_2", "", 0.0)
 
 	tests := []struct {
 		description  string
@@ -348,3 +448,453 @@ func TestServer_update(t *testing.T) {
 	}
 	close(procChan)
 }
+
+func TestServer_enrollTOTP(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := NewServer(WithStorage(store))
+	s.SetupApp()
+
+	id, _ := store.CreateUser("q", "q")
+	store.CreateSession(models.Session{ID: "session", User: id})
+	testToken, _ := logic.GenerateToken(id, "session", 5.0)
+
+	send := func(route, body string) (*http.Response, error) {
+		req := httptest.NewRequest(http.MethodPost, route, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", testToken)
+		return s.app.Test(req, -1)
+	}
+
+	resp, err := send("/api/v1/2fa/enroll", "")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, resp.StatusCode, "enroll")
+	var enroll models.TOTPEnrollResponse
+	err = json.NewDecoder(resp.Body).Decode(&enroll)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	resp, err = send("/api/v1/2fa/confirm", "{\"code\":\"1\"}")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusForbidden, resp.StatusCode, "invalid code")
+	resp.Body.Close()
+
+	key := otp.Key{Secret: enroll.Secret}
+	code, _ := key.Code(time.Now())
+	resp, err = send("/api/v1/2fa/confirm", "{\"code\":\""+code+"\"}")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, resp.StatusCode, "confirm")
+	var confirm models.TOTPConfirmResponse
+	err = json.NewDecoder(resp.Body).Decode(&confirm)
+	assert.Nil(t, err)
+	assert.Equalf(t, logic.RecoveryCodesCount, len(confirm.RecoveryCodes), "confirm")
+	resp.Body.Close()
+
+	resp, err = send("/api/v1/2fa/enroll", "")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusConflict, resp.StatusCode, "enroll after confirm")
+	resp.Body.Close()
+
+	resp, err = send("/api/v1/2fa/disable", "{\"code\":\""+code+"\"}")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusForbidden, resp.StatusCode, "disable with used code")
+	resp.Body.Close()
+
+	resp, err = send("/api/v1/2fa/disable", "{\"code\":\""+confirm.RecoveryCodes[0]+"\"}")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, resp.StatusCode, "disable with recovery code")
+	resp.Body.Close()
+
+	resp, err = send("/api/v1/2fa/disable", "{\"code\":\""+code+"\"}")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusConflict, resp.StatusCode, "disable when not enabled")
+	resp.Body.Close()
+}
+
+func TestServer_sessions(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := NewServer(WithStorage(store))
+	s.SetupApp()
+
+	store.CreateUser("q", "q")
+	send := func(method, route, token, body string) (*http.Response, error) {
+		req := httptest.NewRequest(method, route, bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", token)
+		return s.app.Test(req, -1)
+	}
+	login := func(device string) string {
+		resp, err := send(http.MethodPost, "/api/v1/auth", "", "{\"login\":\"q\",\"password\":\"q\",\"device\":\""+device+"\"}")
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+
+		var res models.UserResponse
+		err = json.NewDecoder(resp.Body).Decode(&res)
+		assert.Nil(t, err)
+		return res.Token
+	}
+
+	laptop := login("laptop")
+	phone := login("phone")
+
+	resp, err := send(http.MethodGet, "/api/v1/sessions", laptop, "")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, resp.StatusCode, "list sessions")
+	var list models.SessionsResponse
+	err = json.NewDecoder(resp.Body).Decode(&list)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	assert.Equalf(t, 2, len(list.Sessions), "list sessions")
+	for _, el := range list.Sessions {
+		assert.Equalf(t, el.Device == "laptop", el.Current, "current session")
+	}
+
+	resp, err = send(http.MethodDelete, "/api/v1/sessions", laptop, "{\"all\":true}")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, resp.StatusCode, "revoke all")
+	resp.Body.Close()
+
+	resp, err = send(http.MethodGet, "/api/v1/items", phone, "")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusUnauthorized, resp.StatusCode, "revoked session")
+	resp.Body.Close()
+
+	resp, err = send(http.MethodGet, "/api/v1/items", laptop, "")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusOK, resp.StatusCode, "current session")
+	resp.Body.Close()
+
+	resp, err = send(http.MethodDelete, "/api/v1/sessions", laptop, "{\"id\":\"unknown\"}")
+	assert.Nil(t, err)
+	assert.Equalf(t, http.StatusNotFound, resp.StatusCode, "unknown session")
+	resp.Body.Close()
+}
+
+func TestServer_deleteAccount(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	procChan := make(ProcessingChan, 1)
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
+	s.SetupApp()
+
+	id, _ := store.CreateUser("q", "q")
+	store.CreateSession(models.Session{ID: "session", User: id})
+	testToken, _ := logic.GenerateToken(id, "session", 5.0)
+
+	tests := []struct {
+		description  string
+		req          string
+		expectedCode int
+	}{
+		{
+			description:  "bad request",
+			expectedCode: http.StatusBadRequest,
+			req:          "{}",
+		},
+		{
+			description:  "wrong password",
+			expectedCode: http.StatusForbidden,
+			req:          "{\"password\":\"qqqqq\"}",
+		},
+		{
+			description:  "success",
+			expectedCode: http.StatusOK,
+			req:          "{\"password\":\"q\"}",
+		},
+		{
+			description:  "session revoked",
+			expectedCode: http.StatusUnauthorized,
+			req:          "{\"password\":\"q\"}",
+		},
+	}
+	for _, tt := range tests {
+		b := bytes.NewBuffer([]byte(tt.req))
+		req := httptest.NewRequest(http.MethodDelete, "/api/v1/account", b)
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", testToken)
+
+		resp, err := s.app.Test(req, -1)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		assert.Equalf(t, tt.expectedCode, resp.StatusCode, tt.description)
+		err = resp.Body.Close()
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	el := <-procChan
+	assert.Equalf(t, ProcessingOperations[DeleteAccountOperation], el.Operation, "queued deletion")
+	assert.Equalf(t, id, el.User, "queued deletion")
+}
+
+func TestServer_recovery(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := NewServer(WithStorage(store))
+	s.SetupApp()
+
+	id, _ := store.CreateUser("q", "q")
+	key, _ := logic.IssueRecoveryKey(id, store)
+
+	tests := []struct {
+		description  string
+		req          string
+		expectedCode int
+	}{
+		{
+			description:  "bad request",
+			expectedCode: http.StatusBadRequest,
+			req:          "{\"login\":\"q\"}",
+		},
+		{
+			description:  "wrong key",
+			expectedCode: http.StatusForbidden,
+			req:          "{\"login\":\"q\",\"recovery_key\":\"AAAA\",\"new_password\":\"w\"}",
+		},
+		{
+			description:  "success",
+			expectedCode: http.StatusOK,
+			req:          "{\"login\":\"q\",\"recovery_key\":\"" + key + "\",\"new_password\":\"w\"}",
+		},
+		{
+			description:  "key already used",
+			expectedCode: http.StatusForbidden,
+			req:          "{\"login\":\"q\",\"recovery_key\":\"" + key + "\",\"new_password\":\"e\"}",
+		},
+	}
+	for _, tt := range tests {
+		b := bytes.NewBuffer([]byte(tt.req))
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/recovery", b)
+		req.Header.Set("Content-Type", "application/json")
+
+		resp, err := s.app.Test(req, -1)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		assert.Equalf(t, tt.expectedCode, resp.StatusCode, tt.description)
+		err = resp.Body.Close()
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+
+	_, pass, _ := store.CheckUser("q")
+	assert.Equalf(t, "w", pass, "password reset")
+}
+
+func TestServer_getAudit(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := NewServer(WithStorage(store))
+	s.SetupApp()
+
+	store.CreateUser("q", "q")
+
+	auth := func(body string) *http.Response {
+		req := httptest.NewRequest(http.MethodPost, "/api/v1/auth", bytes.NewBuffer([]byte(body)))
+		req.Header.Set("Content-Type", "application/json")
+		resp, _ := s.app.Test(req, -1)
+		return resp
+	}
+
+	resp := auth("{\"login\":\"q\",\"password\":\"wrong\",\"device\":\"laptop\"}")
+	resp.Body.Close()
+	resp = auth("{\"login\":\"unknown\",\"password\":\"q\"}")
+	resp.Body.Close()
+	resp = auth("{\"login\":\"q\",\"password\":\"q\",\"device\":\"laptop\"}")
+	var token models.UserResponse
+	json.NewDecoder(resp.Body).Decode(&token)
+	resp.Body.Close()
+
+	tests := []struct {
+		description  string
+		query        string
+		expectedCode int
+		wantEvents   []string
+	}{
+		{
+			description:  "newest first",
+			expectedCode: http.StatusOK,
+			wantEvents:   []string{models.AuditLogin, models.AuditLoginFailed},
+		},
+		{
+			description:  "limit",
+			query:        "?limit=1",
+			expectedCode: http.StatusOK,
+			wantEvents:   []string{models.AuditLogin},
+		},
+		{
+			description:  "bad limit",
+			query:        "?limit=many",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/audit"+tt.query, nil)
+		req.Header.Set("Authorization", token.Token)
+
+		resp, err := s.app.Test(req, -1)
+		if err != nil {
+			log.Println(err)
+			continue
+		}
+		assert.Equalf(t, tt.expectedCode, resp.StatusCode, tt.description)
+
+		if tt.expectedCode == http.StatusOK {
+			var res models.AuditResponse
+			json.NewDecoder(resp.Body).Decode(&res)
+
+			var events []string
+			for _, el := range res.Events {
+				events = append(events, el.Event)
+				assert.Equalf(t, "laptop", el.Device, tt.description)
+			}
+			assert.Equalf(t, tt.wantEvents, events, tt.description)
+		}
+		err = resp.Body.Close()
+		if err != nil {
+			log.Println(err.Error())
+		}
+	}
+}
+
+func TestServer_getDue(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	s := NewServer(WithStorage(store))
+	s.SetupApp()
+
+	id, _ := store.CreateUser("q", "q")
+	store.CreateSession(models.Session{ID: "session", User: id})
+	testToken, _ := logic.GenerateToken(id, "session", 5.0)
+
+	overdue, soon, later := time.Now().Add(-24*time.Hour), time.Now().Add(10*24*time.Hour), time.Now().Add(90*24*time.Hour)
+	store.SetData(models.UserData{ID: "overdue", Data: "data", ExpiresAt: &overdue}, id)
+	store.SetData(models.UserData{ID: "soon", Data: "data", ExpiresAt: &soon}, id)
+	store.SetData(models.UserData{ID: "later", Data: "data", ExpiresAt: &later}, id)
+	store.SetData(models.UserData{ID: "no expiry", Data: "data"}, id)
+	store.SetData(models.UserData{ID: "other user", Data: "data", ExpiresAt: &overdue}, "other")
+
+	tests := []struct {
+		description  string
+		query        string
+		expectedCode int
+		wantIDs      []string
+	}{
+		{
+			description:  "default 30 days",
+			expectedCode: http.StatusOK,
+			wantIDs:      []string{"overdue", "soon"},
+		},
+		{
+			description:  "custom days",
+			query:        "?days=100",
+			expectedCode: http.StatusOK,
+			wantIDs:      []string{"overdue", "soon", "later"},
+		},
+		{
+			description:  "bad days",
+			query:        "?days=soon",
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/items/due"+tt.query, nil)
+		req.Header.Set("Authorization", testToken)
+
+		resp, err := s.app.Test(req, -1)
+		assert.Nil(t, err)
+		assert.Equalf(t, tt.expectedCode, resp.StatusCode, tt.description)
+
+		if tt.expectedCode == http.StatusOK {
+			var res models.UserDataResponse
+			json.NewDecoder(resp.Body).Decode(&res)
+
+			var ids []string
+			for _, el := range res.Data {
+				ids = append(ids, el.ID)
+			}
+			assert.ElementsMatchf(t, tt.wantIDs, ids, tt.description)
+		}
+		resp.Body.Close()
+	}
+}
+
+func TestServer_move(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	procChan := make(ProcessingChan, 10)
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
+	s.SetupApp()
+
+	id, _ := store.CreateUser("q", "q")
+	store.CreateSession(models.Session{ID: "session", User: id})
+	testToken, _ := logic.GenerateToken(id, "session", 5.0)
+
+	store.SetData(models.UserData{ID: "work", Data: "folder"}, id)
+	store.SetData(models.UserData{ID: "github", Data: "data", Folder: "work"}, id)
+	store.SetData(models.UserData{ID: "note", Data: "data"}, id)
+
+	tests := []struct {
+		description  string
+		req          string
+		expectedCode int
+	}{
+		{
+			description:  "success",
+			req:          `{"ids":["note"],"folder":"work"}`,
+			expectedCode: http.StatusOK,
+		},
+		{
+			description:  "no ids",
+			req:          `{"ids":[],"folder":"work"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+		{
+			description:  "into itself",
+			req:          `{"ids":["work"],"folder":"work"}`,
+			expectedCode: http.StatusBadRequest,
+		},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPatch, "/api/v1/items/move", bytes.NewBufferString(tt.req))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", testToken)
+
+		resp, err := s.app.Test(req, -1)
+		assert.Nil(t, err)
+		assert.Equalf(t, tt.expectedCode, resp.StatusCode, tt.description)
+		resp.Body.Close()
+	}
+
+	assert.Len(t, procChan, 1)
+	el := <-procChan
+	assert.Equal(t, ProcessingOperations[MoveOperation], el.Operation)
+	assert.Equal(t, models.MoveRequest{IDs: []string{"note"}, Folder: "work"}, el.Data)
+
+	for query, want := range map[string][]string{"": {"work", "github", "note"}, "?folder=work": {"github"},
+		"?folder=root": {"work", "note"}} {
+		req := httptest.NewRequest(http.MethodGet, "/api/v1/items"+query, nil)
+		req.Header.Set("Authorization", testToken)
+
+		resp, err := s.app.Test(req, -1)
+		assert.Nil(t, err)
+		assert.Equalf(t, http.StatusOK, resp.StatusCode, query)
+
+		var res models.UserDataResponse
+		json.NewDecoder(resp.Body).Decode(&res)
+		resp.Body.Close()
+
+		var ids []string
+		for _, el := range res.Data {
+			ids = append(ids, el.ID)
+		}
+		assert.ElementsMatchf(t, want, ids, query)
+	}
+}
@@ -0,0 +1,116 @@
+package server_repo
+
+import (
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/gofiber/fiber/v2"
+)
+
+const (
+	statusOK = "ok"
+
+	// DefaultShutdownTimeout время на остановку, если оно не задано в конфигурации
+	DefaultShutdownTimeout = 30 * time.Second
+
+	// queueFullPercent заполненность очереди обработки, при которой сервер перестает считаться готовым
+	queueFullPercent = 90
+)
+
+// healthz godoc
+// @Description  liveness probe, the process is up and serving requests
+// @Tags         Health
+// @Produce      json
+// @Success      200	{object} models.HealthResponse
+// @Router       /healthz [get]
+func (s *Server) healthz(c *fiber.Ctx) error {
+	return c.Status(http.StatusOK).JSON(models.HealthResponse{Status: statusOK})
+}
+
+// readyz godoc
+// @Description  readiness probe, checks database connection and processing queue
+// @Tags         Health
+// @Produce      json
+// @Success      200	{object} models.HealthResponse
+// @Failure      503	{object} models.HealthResponse
+// @Router       /readyz [get]
+func (s *Server) readyz(c *fiber.Ctx) error {
+	res := models.HealthResponse{Status: statusOK, Checks: s.readinessChecks()}
+	for _, result := range res.Checks {
+		if result != statusOK {
+			res.Status = "unavailable"
+			return c.Status(http.StatusServiceUnavailable).JSON(res)
+		}
+	}
+
+	return c.Status(http.StatusOK).JSON(res)
+}
+
+// readinessChecks проверяет хранилище, очередь обработки и то, что сервер не находится в процессе остановки
+func (s *Server) readinessChecks() map[string]string {
+	checks := map[string]string{
+		"database":  statusOK,
+		"queue":     statusOK,
+		"accepting": statusOK,
+	}
+
+	err := s.storage.Ping()
+	if err != nil {
+		checks["database"] = err.Error()
+	}
+
+	if size := cap(s.processingChan); size > 0 && len(s.processingChan)*100 >= size*queueFullPercent {
+		checks["queue"] = fmt.Sprintf("queue is almost full: %d of %d", len(s.processingChan), size)
+	}
+
+	if s.draining.Load() {
+		checks["accepting"] = "server is shutting down"
+	}
+
+	return checks
+}
+
+// GracefulShutdown останавливает прием запросов, дожидается завершения текущих, после чего закрывает очередь обработки
+// и ждет, пока watchers обработают оставшиеся операции. На все отводится timeout.
+// Если запросы не завершились вовремя, очередь не закрывается, чтобы обработчики не писали в закрытый канал
+func (s *Server) GracefulShutdown(timeout time.Duration, watchers *sync.WaitGroup) error {
+	if timeout <= 0 {
+		timeout = DefaultShutdownTimeout
+	}
+	deadline := time.NewTimer(timeout)
+	defer deadline.Stop()
+
+	s.draining.Store(true)
+
+	stopped := make(chan error, 1)
+	go func() { stopped <- s.Shutdown() }()
+
+	select {
+	case err := <-stopped:
+		if err != nil {
+			return err
+		}
+	case <-deadline.C:
+		return fmt.Errorf("%w: requests still in progress, %d queued operations dropped",
+			models.ErrShutdownTimeout, len(s.processingChan))
+	}
+
+	s.lg.Info("requests finished, draining processing queue", "queued", len(s.processingChan))
+	close(s.processingChan)
+
+	drained := make(chan struct{})
+	go func() {
+		watchers.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-deadline.C:
+		return fmt.Errorf("%w: %d queued operations left", models.ErrShutdownTimeout, len(s.processingChan))
+	}
+}
@@ -0,0 +1,122 @@
+package server_repo
+
+import (
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
+	"github.com/stretchr/testify/assert"
+)
+
+// unavailableStorage хранилище, недоступное для readiness
+type unavailableStorage struct {
+	testing_repos_server.TestingServerStorage
+}
+
+func (unavailableStorage) Ping() error {
+	return errors.New("database is locked")
+}
+
+func TestServer_readyz(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+
+	tests := []struct {
+		name     string
+		storage  models.Storable4Server
+		queued   int
+		draining bool
+		status   int
+		failed   string
+	}{
+		{name: "ready", storage: store, status: http.StatusOK},
+		{name: "database unavailable", storage: unavailableStorage{store}, status: http.StatusServiceUnavailable, failed: "database"},
+		{name: "queue almost full", storage: store, queued: 9, status: http.StatusServiceUnavailable, failed: "queue"},
+		{name: "shutting down", storage: store, draining: true, status: http.StatusServiceUnavailable, failed: "accepting"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			procChan := make(ProcessingChan, 10)
+			for i := 0; i < tt.queued; i++ {
+				procChan <- ProcessingTuple{}
+			}
+			s := NewServer(WithStorage(tt.storage), WithProcessingChan(procChan))
+			s.SetupApp()
+			s.draining.Store(tt.draining)
+
+			resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/readyz", nil), -1)
+			assert.Nil(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tt.status, resp.StatusCode)
+
+			var res models.HealthResponse
+			assert.Nil(t, json.NewDecoder(resp.Body).Decode(&res))
+			for check, result := range res.Checks {
+				assert.Equal(t, check != tt.failed, result == statusOK, check)
+			}
+
+			// liveness не зависит от состояния хранилища и очереди
+			resp, err = s.app.Test(httptest.NewRequest(http.MethodGet, "/healthz", nil), -1)
+			assert.Nil(t, err)
+			resp.Body.Close()
+			assert.Equal(t, http.StatusOK, resp.StatusCode)
+		})
+	}
+}
+
+func TestServer_GracefulShutdown(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	id, _ := store.CreateUser("q", "q")
+
+	procChan := make(ProcessingChan, 10)
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
+	s.SetupApp()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.Nil(t, err)
+	go s.Serve(ln)
+
+	for _, record := range []string{"1", "2", "3"} {
+		procChan <- ProcessingTuple{
+			Operation: ProcessingOperations[SetOperation],
+			Data:      models.UserData{ID: record, Data: "data"},
+			User:      id,
+		}
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go s.ProcessingWatcher(&wg)
+
+	err = s.GracefulShutdown(time.Second, &wg)
+	assert.Nil(t, err)
+
+	// операции, поставленные в очередь до остановки, не теряются
+	data, err := store.GetData(id)
+	assert.Nil(t, err)
+	assert.Len(t, data, 3)
+}
+
+func TestServer_GracefulShutdownTimeout(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+
+	s := NewServer(WithStorage(store), WithProcessingChan(make(ProcessingChan, 1)))
+	s.SetupApp()
+
+	// обработчик, который не успевает завершиться
+	var wg sync.WaitGroup
+	wg.Add(1)
+	defer wg.Done()
+
+	err := s.GracefulShutdown(50*time.Millisecond, &wg)
+	assert.ErrorIs(t, err, models.ErrShutdownTimeout)
+}
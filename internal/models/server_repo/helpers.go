@@ -1,11 +1,18 @@
 package server_repo
 
 import (
-	"log"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math"
+	"net/http"
+	"strconv"
 	"sync"
+	"time"
 
 	logic "github.com/azazel3ooo/keeper/internal/logic/server"
 	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/gofiber/fiber/v2"
 )
 
 // ProcessingWatcher итерируется по каналу сервера. Вызывает необходимые методы хранилища (обновление, удаление...)
@@ -14,45 +21,231 @@ func (s Server) ProcessingWatcher(wt *sync.WaitGroup) {
 	defer wt.Done()
 
 	for el := range s.processingChan {
+		lg := s.lg.With("request_id", el.RequestID, "user", el.User, "operation", el.Operation)
+		start := time.Now()
+
+		var err error
 		switch el.Operation {
 		case ProcessingOperations[SetOperation]:
 			r, ok := el.Data.(models.UserData)
 			if !ok {
-				log.Println("invalid type in ProcessingWatcher with:", el)
+				lg.Error("invalid type in ProcessingWatcher", "type", fmt.Sprintf("%T", el.Data))
 				continue
 			}
-
-			err := logic.Set(r, s.storage, el.User)
-			if err != nil {
-				log.Println(err)
-			}
+			err = logic.Set(r, s.storage, el.User)
 
 		case ProcessingOperations[DeleteOperation]:
 			r, ok := el.Data.(models.DeleteRequest)
 			if !ok {
-				log.Println("invalid type in ProcessingWatcher with:", el)
+				lg.Error("invalid type in ProcessingWatcher", "type", fmt.Sprintf("%T", el.Data))
 				continue
 			}
-
-			err := logic.Delete(r, s.storage, el.User)
-			if err != nil {
-				log.Println(err)
-			}
+			err = logic.Delete(r, s.storage, el.User)
 
 		case ProcessingOperations[UpdateOperation]:
 			r, ok := el.Data.(models.UserData)
 			if !ok {
-				log.Println("invalid type in ProcessingWatcher with:", el)
+				lg.Error("invalid type in ProcessingWatcher", "type", fmt.Sprintf("%T", el.Data))
 				continue
 			}
+			err = logic.Update(r, s.storage, el.User)
 
-			err := logic.Update(r, s.storage, el.User)
-			if err != nil {
-				log.Println(err)
+		case ProcessingOperations[MoveOperation]:
+			r, ok := el.Data.(models.MoveRequest)
+			if !ok {
+				lg.Error("invalid type in ProcessingWatcher", "type", fmt.Sprintf("%T", el.Data))
+				continue
 			}
+			err = logic.Move(r, s.storage, el.User)
+
+		case ProcessingOperations[DeleteAccountOperation]:
+			// удаление выполняется через очередь, чтобы не осталось записей от операций, поставленных в нее ранее
+			err = logic.DeleteAccount(el.User, s.storage)
 
 		default:
-			log.Println("unknown type in ProcessingWatcher with ", el)
+			lg.Error("unknown operation in ProcessingWatcher")
+			continue
+		}
+
+		s.metrics.observeProcessing(el.Operation, start, err)
+		if err != nil {
+			lg.Error("processing failed", "err", err)
+			continue
+		}
+		s.feed.publish(el)
+		lg.Debug("processed")
+	}
+}
+
+// authorize проверяет токен запроса и обновляет время последнего обращения его сессии.
+// Возвращает id пользователя и id сессии
+func (s *Server) authorize(c *fiber.Ctx) (string, string, error) {
+	id, session, err := logic.CheckToken(c.Get("Authorization"), s.storage)
+	if err != nil {
+		return "", "", err
+	}
+
+	err = logic.TouchSession(session, c.IP(), s.storage)
+	if err != nil {
+		requestLogger(c).Error("can't update session", "err", err)
+	}
+
+	return id, session, nil
+}
+
+// tokenErrorStatus возвращает http статус ответа для ошибки проверки токена
+func tokenErrorStatus(err error) int {
+	if errors.Is(err, models.ErrInvalidToken) {
+		return http.StatusForbidden
+	}
+	if errors.Is(err, models.ErrExpiredToken) || errors.Is(err, models.ErrRevokedSession) {
+		return http.StatusUnauthorized
+	}
+
+	return http.StatusInternalServerError
+}
+
+// sendLimitError отвечает статусом 429 с заголовком Retry-After, если err - ошибка превышения лимита попыток
+func sendLimitError(c *fiber.Ctx, err error) error {
+	var limitErr *models.RateLimitError
+	if !errors.As(err, &limitErr) {
+		requestLogger(c).Error("can't check limits", "err", err)
+		return c.SendStatus(http.StatusInternalServerError)
+	}
+
+	c.Set(fiber.HeaderRetryAfter, strconv.Itoa(retryAfterSeconds(limitErr.RetryAfter)))
+	return c.SendStatus(http.StatusTooManyRequests)
+}
+
+// retryAfterSeconds округляет время до снятия блокировки вверх до целых секунд, но не меньше одной
+func retryAfterSeconds(d time.Duration) int {
+	seconds := int(math.Ceil(d.Seconds()))
+	if seconds < 1 {
+		seconds = 1
+	}
+	return seconds
+}
+
+// audit записывает событие в журнал аудита, дополняя его ip запроса и устройством сессии.
+// Ошибка журнала не должна менять ответ клиенту
+func (s *Server) audit(c *fiber.Ctx, session string, e models.AuditEvent) {
+	s.recordAudit(requestLogger(c), c.IP(), session, e)
+}
+
+// auditFailedLogin записывает в журнал аудита неудачную попытку входа
+func (s *Server) auditFailedLogin(c *fiber.Ctx, req models.UserRequest, reason string) {
+	s.recordFailedLogin(requestLogger(c), c.IP(), req, reason)
+}
+
+// registerAuthFailure учитывает неудачную попытку входа. Ошибка хранилища не должна менять ответ клиенту
+func (s *Server) registerAuthFailure(c *fiber.Ctx, limits []logic.Limit) {
+	s.countAuthFailure(requestLogger(c), c.Route().Path, limits)
+}
+
+// recordAudit общая для REST и gRPC API часть audit
+func (s *Server) recordAudit(lg *slog.Logger, ip, session string, e models.AuditEvent) {
+	e.IP = ip
+	if e.Device == "" && session != "" {
+		sess, err := s.storage.GetSession(session)
+		if err == nil {
+			e.Device = sess.Device
 		}
 	}
+
+	err := logic.RecordEvent(e, s.storage)
+	if err != nil {
+		lg.Error("can't write audit event", "event", e.Event, "err", err)
+	}
+}
+
+// recordFailedLogin общая для REST и gRPC API часть auditFailedLogin
+func (s *Server) recordFailedLogin(lg *slog.Logger, ip string, req models.UserRequest, reason string) {
+	e, err := logic.FailedLoginEvent(req.Login, reason, s.storage)
+	if err != nil {
+		lg.Error("can't write audit event", "event", models.AuditLoginFailed, "err", err)
+		return
+	}
+
+	e.Device = req.Device
+	s.recordAudit(lg, ip, "", e)
+}
+
+// countAuthFailure общая для REST и gRPC API часть registerAuthFailure. route - маршрут или метод gRPC для метрик
+func (s *Server) countAuthFailure(lg *slog.Logger, route string, limits []logic.Limit) {
+	s.metrics.authFailures.WithLabelValues(route).Inc()
+
+	err := logic.RegisterFailure(s.storage, time.Now(), limits...)
+	if err != nil {
+		lg.Error("can't register attempt", "err", err)
+	}
+}
+
+// signUp регистрирует пользователя, открывает для него сессию и выдает ключ восстановления.
+// Общая часть регистрации REST и gRPC API, ошибка превышения лимита - *models.RateLimitError
+func (s *Server) signUp(lg *slog.Logger, ip string, req models.UserRequest) (models.UserResponse, error) {
+	err := logic.TakeAttempt(s.storage, time.Now(), logic.RegistrationLimit(ip))
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+
+	id, err := logic.Registration(req, s.storage)
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+
+	token, err := logic.Login(id, req, ip, s.storage)
+	if err != nil {
+		return models.UserResponse{}, fmt.Errorf("can't create session: %w", err)
+	}
+
+	key, err := logic.IssueRecoveryKey(id, s.storage)
+	if err != nil {
+		return models.UserResponse{}, fmt.Errorf("can't issue recovery key: %w", err)
+	}
+	s.recordAudit(lg, ip, "", models.AuditEvent{User: id, Event: models.AuditRegistration, Device: req.Device})
+
+	return models.UserResponse{Token: token, RecoveryKey: key}, nil
+}
+
+// signIn проверяет пароль и второй фактор и открывает сессию. Общая часть авторизации REST и gRPC API:
+// неудачные попытки учитываются в лимитах и журнале аудита, ошибка превышения лимита - *models.RateLimitError
+func (s *Server) signIn(lg *slog.Logger, ip, route string, req models.UserRequest) (models.UserResponse, error) {
+	limits := []logic.Limit{logic.IPLimit(ip), logic.LoginLimit(req.Login)}
+	err := logic.CheckLimits(s.storage, time.Now(), limits...)
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+
+	id, err := logic.CheckUser(req, s.storage)
+	if errors.Is(err, models.ErrUserDataConflict) {
+		s.countAuthFailure(lg, route, limits)
+		s.recordFailedLogin(lg, ip, req, "invalid password")
+		return models.UserResponse{}, err
+	} else if err != nil {
+		return models.UserResponse{}, err
+	}
+
+	err = logic.CheckSecondFactor(id, req.Code, s.storage)
+	if errors.Is(err, models.ErrInvalidCode) {
+		s.countAuthFailure(lg, route, limits)
+		s.recordAudit(lg, ip, "", models.AuditEvent{User: id, Event: models.AuditLoginFailed, Device: req.Device,
+			Details: "invalid one-time code"})
+		return models.UserResponse{}, err
+	} else if err != nil {
+		return models.UserResponse{}, err
+	}
+
+	// счетчик по ip не сбрасывается, иначе вход в свой аккаунт позволял бы продолжать перебор чужих
+	err = logic.ResetLimits(s.storage, logic.LoginLimit(req.Login))
+	if err != nil {
+		lg.Error("can't reset attempts", "err", err)
+	}
+
+	token, err := logic.Login(id, req, ip, s.storage)
+	if err != nil {
+		return models.UserResponse{}, err
+	}
+	s.recordAudit(lg, ip, "", models.AuditEvent{User: id, Event: models.AuditLogin, Device: req.Device})
+
+	return models.UserResponse{Token: token}, nil
 }
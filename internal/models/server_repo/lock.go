@@ -0,0 +1,39 @@
+package server_repo
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+)
+
+// lockExt расширение файла блокировки рядом с базой
+const lockExt = ".lock"
+
+// LockDatabase берет исключительную блокировку базы path на время работы сервера или восстановления снимка.
+// Блокировка снимается закрытием файла или операционной системой при завершении процесса, поэтому
+// после аварийной остановки сервера оставшийся файл не мешает запуску
+func LockDatabase(path string) (*os.File, error) {
+	err := os.MkdirAll(filepath.Dir(path), 0774)
+	if err != nil {
+		return nil, err
+	}
+
+	f, err := os.OpenFile(path+lockExt, os.O_RDWR|os.O_CREATE, 0664)
+	if err != nil {
+		return nil, err
+	}
+
+	err = lockFile(f)
+	if err != nil {
+		f.Close()
+		if errors.Is(err, errLocked) {
+			return nil, fmt.Errorf("%w: %s", models.ErrDatabaseLocked, path)
+		}
+		return nil, err
+	}
+
+	return f, nil
+}
@@ -0,0 +1,96 @@
+package server_repo
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+)
+
+const (
+	// Redacted значение, которым в логах заменяются секретные поля
+	Redacted = "[REDACTED]"
+
+	loggerKey    = "logger"
+	requestIDKey = "requestid" // ключ, под которым middleware requestid сохраняет id запроса
+)
+
+// secretKeys поля, значения которых не попадают в лог, в каком бы месте записи они ни встретились
+var secretKeys = map[string]bool{
+	"password":      true,
+	"new_password":  true,
+	"token":         true,
+	"authorization": true,
+	"recovery_key":  true,
+	"code":          true,
+	"secret":        true,
+	"otp":           true,
+	"data":          true,
+}
+
+// NewLogger создает логгер с уровнем и форматом (json или text) из конфигурации. Секретные поля скрываются
+func NewLogger(cfg models.LogConfig, w io.Writer) (*slog.Logger, error) {
+	var level slog.Level
+	if cfg.Level != "" {
+		err := level.UnmarshalText([]byte(cfg.Level))
+		if err != nil {
+			return nil, fmt.Errorf("%w: level %q", models.ErrInvalidLogConfig, cfg.Level)
+		}
+	}
+
+	opts := &slog.HandlerOptions{Level: level, ReplaceAttr: redactSecrets}
+	switch strings.ToLower(cfg.Format) {
+	case "", "text":
+		return slog.New(slog.NewTextHandler(w, opts)), nil
+	case "json":
+		return slog.New(slog.NewJSONHandler(w, opts)), nil
+	}
+
+	return nil, fmt.Errorf("%w: format %q", models.ErrInvalidLogConfig, cfg.Format)
+}
+
+func redactSecrets(_ []string, a slog.Attr) slog.Attr {
+	if secretKeys[strings.ToLower(a.Key)] {
+		return slog.String(a.Key, Redacted)
+	}
+	return a
+}
+
+// accessLog middleware, который сохраняет в контексте логгер с id запроса и пишет строку лога по каждому запросу.
+// Тела запросов и ответов не логируются
+func (s *Server) accessLog(c *fiber.Ctx) error {
+	start := time.Now()
+	lg := s.lg.With("request_id", requestID(c))
+	c.Locals(loggerKey, lg)
+
+	err := c.Next()
+
+	lg.Info("request",
+		"method", c.Method(),
+		"path", c.Path(),
+		"status", c.Response().StatusCode(),
+		"latency", time.Since(start),
+		"ip", c.IP(),
+	)
+	return err
+}
+
+// requestLogger возвращает логгер текущего запроса
+func requestLogger(c *fiber.Ctx) *slog.Logger {
+	lg, ok := c.Locals(loggerKey).(*slog.Logger)
+	if !ok {
+		return slog.Default()
+	}
+	return lg
+}
+
+// requestID возвращает id текущего запроса. Значение копируется, поскольку используется и после завершения запроса
+func requestID(c *fiber.Ctx) string {
+	id, _ := c.Locals(requestIDKey).(string)
+	return utils.CopyString(id)
+}
@@ -0,0 +1,79 @@
+package server_repo
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/server"
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewLogger(t *testing.T) {
+	tests := []struct {
+		description string
+		cfg         models.LogConfig
+		wantErr     error
+	}{
+		{description: "defaults", cfg: models.LogConfig{}},
+		{description: "json debug", cfg: models.LogConfig{Level: "debug", Format: "json"}},
+		{description: "unknown level", cfg: models.LogConfig{Level: "verbose"}, wantErr: models.ErrInvalidLogConfig},
+		{description: "unknown format", cfg: models.LogConfig{Format: "xml"}, wantErr: models.ErrInvalidLogConfig},
+	}
+	for _, tt := range tests {
+		_, err := NewLogger(tt.cfg, &bytes.Buffer{})
+		assert.Equalf(t, true, errors.Is(err, tt.wantErr), tt.description)
+	}
+
+	var buf bytes.Buffer
+	lg, _ := NewLogger(models.LogConfig{Format: "json"}, &buf)
+	lg.Info("request", "token", "eyJhbGciOi", "user", "id")
+	assert.Equal(t, false, strings.Contains(buf.String(), "eyJhbGciOi"))
+	assert.Equal(t, true, strings.Contains(buf.String(), `"token":"`+Redacted+`"`))
+}
+
+func TestServer_requestID(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	id, _ := store.CreateUser("q", "q")
+	store.CreateSession(models.Session{ID: "session", User: id})
+	testToken, _ := logic.GenerateToken(id, "session", 5.0)
+
+	var buf bytes.Buffer
+	lg, _ := NewLogger(models.LogConfig{Level: "debug", Format: "json"}, &buf)
+	procChan := make(ProcessingChan, 1)
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan), WithLogger(lg))
+	s.SetupApp()
+
+	req := httptest.NewRequest(http.MethodPost, "/api/v1/items", bytes.NewBuffer([]byte(`{"id":"1","data":"secret data"}`)))
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", testToken)
+	req.Header.Set("X-Request-ID", "test-request")
+	resp, err := s.app.Test(req, -1)
+	assert.Nil(t, err)
+	resp.Body.Close()
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	close(procChan)
+	s.ProcessingWatcher(&wg)
+
+	var messages []string
+	scanner := bufio.NewScanner(&buf)
+	for scanner.Scan() {
+		var line map[string]any
+		_ = json.Unmarshal(scanner.Bytes(), &line)
+		assert.Equalf(t, "test-request", line["request_id"], "request id in %v", line)
+		messages = append(messages, line["msg"].(string))
+	}
+	assert.Equal(t, []string{"request", "processed"}, messages)
+	assert.Equal(t, false, strings.Contains(buf.String(), "secret data"))
+}
@@ -1,13 +1,17 @@
 package server_repo
 
 import (
+	"crypto/tls"
+	"log/slog"
+	"net"
 	"net/http"
+	"sync/atomic"
 
 	"github.com/azazel3ooo/keeper/internal/models"
 	"github.com/gofiber/fiber/v2"
 	"github.com/gofiber/fiber/v2/middleware/compress"
-	"github.com/gofiber/fiber/v2/middleware/logger"
 	"github.com/gofiber/fiber/v2/middleware/recover"
+	"github.com/gofiber/fiber/v2/middleware/requestid"
 	fiberSwagger "github.com/swaggo/fiber-swagger"
 )
 
@@ -19,9 +23,13 @@ func (s *Server) SetupApp() {
 		Level: compress.LevelBestSpeed,
 	}))
 	a.Use(recover.New(recover.Config{EnableStackTrace: true}))
-	a.Use(logger.New(logger.Config{
-		Format: "[${time}] ${status} - ${latency} ${method} ${path} ${resBody}\n",
-	}))
+	a.Use(requestid.New())
+	a.Use(s.accessLog)
+	a.Use(s.metrics.collect)
+
+	a.Get("/metrics", s.metrics.handler())
+	a.Get("/healthz", s.healthz)
+	a.Get("/readyz", s.readyz)
 
 	api := a.Group("/api")
 	v1 := api.Group("/v1")
@@ -30,9 +38,27 @@ func (s *Server) SetupApp() {
 	v1.Post("/items", s.set)
 	v1.Delete("/items", s.delete)
 	v1.Patch("/items", s.update)
+	v1.Get("/items/due", s.getDue)
+	v1.Patch("/items/move", s.move)
 
 	v1.Post("/registration", s.registration)
 	v1.Post("/auth", s.authorization)
+	v1.Post("/recovery", s.recovery)
+
+	v1.Post("/2fa/enroll", s.enrollTOTP)
+	v1.Post("/2fa/confirm", s.confirmTOTP)
+	v1.Post("/2fa/disable", s.disableTOTP)
+
+	v1.Patch("/account/password", s.changePassword)
+	v1.Patch("/account/login", s.changeLogin)
+	v1.Delete("/account", s.deleteAccount)
+	v1.Post("/account/recovery", s.regenerateRecoveryKey)
+	v1.Delete("/account/recovery", s.revokeRecoveryKey)
+
+	v1.Get("/sessions", s.getSessions)
+	v1.Delete("/sessions", s.revokeSession)
+
+	v1.Get("/audit", s.getAudit)
 
 	v1.Get("/swagger/*", fiberSwagger.WrapHandler)
 
@@ -41,17 +67,48 @@ func (s *Server) SetupApp() {
 
 // Listen запускает приложение на указанному порту
 func (s Server) Listen() error {
-	return s.app.Listen(s.cfg.HostAddr)
+	ln, err := net.Listen("tcp", s.cfg.HostAddr)
+	if err != nil {
+		return err
+	}
+
+	return s.Serve(ln)
+}
+
+// Serve запускает приложение на переданном listener. Если в конфигурации задан сертификат, соединения принимаются по TLS
+func (s Server) Serve(ln net.Listener) error {
+	if s.cfg.TLS.Enabled() {
+		tlsCfg, err := s.cfg.TLS.ServerConfig()
+		if err != nil {
+			ln.Close()
+			return err
+		}
+		ln = tls.NewListener(ln, tlsCfg)
+	}
+
+	return s.app.Listener(ln)
+}
+
+// Shutdown останавливает прием соединений и дожидается завершения обрабатываемых запросов REST и gRPC API
+func (s Server) Shutdown() error {
+	// потоки Watch сами не завершаются, поэтому подписки закрываются до остановки gRPC сервера
+	s.feed.close()
+	if s.rpc != nil {
+		s.rpc.GracefulStop()
+	}
+
+	return s.app.Shutdown()
 }
 
 // NewServer возвращает сервер, применяя к нему указанные опции
 func NewServer(opts ...func(*Server)) *Server {
-	s := &Server{}
+	s := &Server{lg: slog.Default(), draining: new(atomic.Bool), feed: newChangeFeed()}
 	s.app = fiber.New()
 
 	for _, opt := range opts {
 		opt(s)
 	}
+	s.metrics = newMetrics(s)
 	return s
 }
 
@@ -62,13 +119,8 @@ func WithProcessingChan(c ProcessingChan) func(*Server) {
 	}
 }
 
-func WithLoggingChan(c LogChan) func(*Server) {
-	return func(s *Server) {
-		s.lgChan = c
-	}
-}
-
-func WithLogger(l any) func(*Server) {
+// WithLogger задает логгер сервера. По умолчанию используется slog.Default()
+func WithLogger(l *slog.Logger) func(*Server) {
 	return func(s *Server) {
 		s.lg = l
 	}
@@ -81,8 +133,8 @@ func WithStorage(store models.Storable4Server) func(*Server) {
 	}
 }
 
-// WithConfig добавляет Config серверу
-func WithConfig(c models.Config) func(*Server) {
+// WithConfig добавляет ServerConfig серверу
+func WithConfig(c models.ServerConfig) func(*Server) {
 	return func(s *Server) {
 		s.cfg = c
 	}
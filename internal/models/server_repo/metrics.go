@@ -0,0 +1,159 @@
+package server_repo
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/gofiber/fiber/v2"
+	"github.com/gofiber/fiber/v2/utils"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"github.com/valyala/fasthttp/fasthttpadaptor"
+)
+
+const (
+	metricsNamespace = "keeper"
+
+	// ActiveUsersWindow период, в течение которого пользователь с активной сессией считается активным
+	ActiveUsersWindow = 15 * time.Minute
+)
+
+// operationNames названия операций ProcessingWatcher для меток метрик
+var operationNames = map[int]string{
+	ProcessingOperations[SetOperation]:           SetOperation,
+	ProcessingOperations[UpdateOperation]:        UpdateOperation,
+	ProcessingOperations[DeleteOperation]:        DeleteOperation,
+	ProcessingOperations[DeleteAccountOperation]: DeleteAccountOperation,
+	ProcessingOperations[MoveOperation]:          MoveOperation,
+}
+
+// Metrics метрики сервера. У каждого сервера свой реестр, поэтому несколько серверов в одном процессе не конфликтуют
+type Metrics struct {
+	registry *prometheus.Registry
+
+	requests         *prometheus.CounterVec
+	requestDuration  *prometheus.HistogramVec
+	processing       *prometheus.HistogramVec
+	processingErrors *prometheus.CounterVec
+	authFailures     *prometheus.CounterVec
+	rpcRequests      *prometheus.CounterVec
+	rpcDuration      *prometheus.HistogramVec
+}
+
+// newMetrics создает метрики сервера. Глубина очереди и число активных пользователей вычисляются при сборе метрик
+func newMetrics(s *Server) *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_requests_total",
+			Help:      "Number of HTTP requests by route, method and status.",
+		}, []string{"route", "method", "status"}),
+		requestDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "HTTP request latency by route and method.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"route", "method"}),
+		processing: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "processing_duration_seconds",
+			Help:      "Time spent on queued storage operations.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"operation"}),
+		processingErrors: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "storage_errors_total",
+			Help:      "Number of failed queued storage operations.",
+		}, []string{"operation"}),
+		authFailures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "auth_failures_total",
+			Help:      "Number of failed password, one-time code and recovery key checks by route.",
+		}, []string{"route"}),
+		rpcRequests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: metricsNamespace,
+			Name:      "grpc_requests_total",
+			Help:      "Number of gRPC calls by method and status code.",
+		}, []string{"method", "code"}),
+		rpcDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: metricsNamespace,
+			Name:      "grpc_request_duration_seconds",
+			Help:      "gRPC call latency by method, streams are observed when they end.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests,
+		m.requestDuration,
+		m.processing,
+		m.processingErrors,
+		m.authFailures,
+		m.rpcRequests,
+		m.rpcDuration,
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "processing_queue_depth",
+			Help:      "Number of operations waiting in the processing queue.",
+		}, func() float64 { return float64(len(s.processingChan)) }),
+		prometheus.NewGaugeFunc(prometheus.GaugeOpts{
+			Namespace: metricsNamespace,
+			Name:      "active_users",
+			Help:      "Number of users with a session used during the last 15 minutes.",
+		}, func() float64 {
+			now := time.Now()
+			count, err := s.storage.CountActiveUsers(now.Add(-ActiveUsersWindow), now)
+			if err != nil {
+				s.lg.Error("can't count active users", "err", err)
+				return 0
+			}
+			return float64(count)
+		}),
+	)
+
+	return m
+}
+
+// collect middleware, который учитывает запрос в метриках. Маршрут берется из шаблона, а не из пути запроса
+func (m *Metrics) collect(c *fiber.Ctx) error {
+	start := time.Now()
+	err := c.Next()
+
+	// строки fasthttp ссылаются на переиспользуемые буферы, а метки хранятся в реестре, поэтому метод копируется
+	route, method := c.Route().Path, utils.CopyString(c.Method())
+	m.requests.WithLabelValues(route, method, strconv.Itoa(c.Response().StatusCode())).Inc()
+	m.requestDuration.WithLabelValues(route, method).Observe(time.Since(start).Seconds())
+	return err
+}
+
+// handler отдает метрики в формате Prometheus
+func (m *Metrics) handler() fiber.Handler {
+	h := fasthttpadaptor.NewFastHTTPHandler(promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{}))
+	return func(c *fiber.Ctx) error {
+		h(c.Context())
+		return nil
+	}
+}
+
+// observeRPC учитывает вызов gRPC API
+func (m *Metrics) observeRPC(method, code string, start time.Time) {
+	m.rpcRequests.WithLabelValues(method, code).Inc()
+	m.rpcDuration.WithLabelValues(method).Observe(time.Since(start).Seconds())
+}
+
+// observeProcessing учитывает выполнение операции из очереди
+func (m *Metrics) observeProcessing(operation int, start time.Time, err error) {
+	name, ok := operationNames[operation]
+	if !ok {
+		name = "unknown"
+	}
+
+	m.processing.WithLabelValues(name).Observe(time.Since(start).Seconds())
+	if err != nil {
+		m.processingErrors.WithLabelValues(name).Inc()
+	}
+}
@@ -0,0 +1,80 @@
+package server_repo
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	logic "github.com/azazel3ooo/keeper/internal/logic/server"
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/azazel3ooo/keeper/internal/models/testing_repos_server"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServer_metrics(t *testing.T) {
+	var store testing_repos_server.TestingServerStorage
+	store.Init()
+	id, _ := store.CreateUser("q", "q")
+	store.CreateSession(models.Session{ID: "session", User: id, LastSeen: time.Now(), Expires: time.Now().Add(time.Minute)})
+	testToken, _ := logic.GenerateToken(id, "session", 5.0)
+
+	procChan := make(ProcessingChan, 2)
+	s := NewServer(WithStorage(store), WithProcessingChan(procChan))
+	s.SetupApp()
+
+	requests := []struct {
+		method string
+		path   string
+		body   string
+	}{
+		{method: http.MethodPost, path: "/api/v1/items", body: `{"id":"1","data":"data"}`},
+		{method: http.MethodDelete, path: "/api/v1/items", body: `{"id":"2"}`},
+		{method: http.MethodPost, path: "/api/v1/auth", body: `{"login":"q","password":"wrong"}`},
+	}
+	for _, r := range requests {
+		req := httptest.NewRequest(r.method, r.path, bytes.NewBuffer([]byte(r.body)))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Authorization", testToken)
+		resp, err := s.app.Test(req, -1)
+		assert.Nil(t, err)
+		resp.Body.Close()
+	}
+
+	scrape := func() string {
+		resp, err := s.app.Test(httptest.NewRequest(http.MethodGet, "/metrics", nil), -1)
+		assert.Nil(t, err)
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		return string(b)
+	}
+
+	body := scrape()
+	for _, want := range []string{
+		`keeper_http_requests_total{method="POST",route="/api/v1/items",status="200"} 1`,
+		`keeper_http_requests_total{method="POST",route="/api/v1/auth",status="403"} 1`,
+		`keeper_auth_failures_total{route="/api/v1/auth"} 1`,
+		`keeper_processing_queue_depth 2`,
+		`keeper_active_users 1`,
+	} {
+		assert.Equalf(t, true, strings.Contains(body, want), want)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	close(procChan)
+	s.ProcessingWatcher(&wg)
+
+	body = scrape()
+	for _, want := range []string{
+		`keeper_processing_queue_depth 0`,
+		`keeper_processing_duration_seconds_count{operation="set"} 1`,
+		`keeper_processing_duration_seconds_count{operation="del"} 1`,
+	} {
+		assert.Equalf(t, true, strings.Contains(body, want), want)
+	}
+}
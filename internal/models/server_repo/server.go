@@ -1,28 +1,39 @@
 package server_repo
 
 import (
+	"log/slog"
+	"sync/atomic"
+
 	"github.com/azazel3ooo/keeper/internal/models"
 	"github.com/gofiber/fiber/v2"
+	"google.golang.org/grpc"
 )
 
 const (
-	SetOperation    = "set"
-	UpdateOperation = "upd"
-	DeleteOperation = "del"
+	SetOperation           = "set"
+	UpdateOperation        = "upd"
+	DeleteOperation        = "del"
+	DeleteAccountOperation = "acc"
+	MoveOperation          = "mov"
 )
 
 var ProcessingOperations = map[string]int{
-	SetOperation:    1,
-	UpdateOperation: 2,
-	DeleteOperation: 3,
+	SetOperation:           1,
+	UpdateOperation:        2,
+	DeleteOperation:        3,
+	DeleteAccountOperation: 4,
+	MoveOperation:          5,
 }
 
 type Server struct {
 	storage        models.Storable4Server
-	cfg            models.Config
+	cfg            models.ServerConfig
 	app            *fiber.App
-	lg             any
-	lgChan         LogChan
+	rpc            *grpc.Server // nil, если gRPC API не настроен (см. SetupGRPC)
+	feed           *changeFeed  // изменения записей для потока Watch gRPC API
+	lg             *slog.Logger
+	metrics        *Metrics
+	draining       *atomic.Bool // true после начала остановки, readiness в этом состоянии не проходит
 	processingChan ProcessingChan
 }
 
@@ -31,7 +42,7 @@ type ProcessingTuple struct {
 	Operation int
 	Data      models.Validatable
 	User      string
+	RequestID string // для связи записей лога обработки с запросом
 }
 
-type LogChan chan any // unused
 type ProcessingChan chan ProcessingTuple
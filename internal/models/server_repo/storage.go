@@ -2,9 +2,12 @@ package server_repo
 
 import (
 	"database/sql"
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"sync"
+	"time"
 
 	"github.com/azazel3ooo/keeper/internal/models"
 	_ "github.com/mattn/go-sqlite3"
@@ -47,6 +50,11 @@ func (s *ServerStorage) Init(path string) error {
 	return nil
 }
 
+// Ping проверяет соединение с базой
+func (s *ServerStorage) Ping() error {
+	return s.db.Ping()
+}
+
 func (s *ServerStorage) CreateTables() error {
 	stmt := `CREATE TABLE if not exists users (
 		"id" TEXT primary key,
@@ -63,7 +71,9 @@ func (s *ServerStorage) CreateTables() error {
     	"id" TEXT PRIMARY key,
     	"user" TEXT,
     	"data" TEXT,
-    	"comment" TEXT
+    	"comment" TEXT,
+    	"expires_at" INTEGER,
+    	"folder" TEXT
 	);`
 
 	_, err = s.db.Exec(stmt)
@@ -71,7 +81,119 @@ func (s *ServerStorage) CreateTables() error {
 		return err
 	}
 
-	return nil
+	stmt = `CREATE TABLE if not exists totp (
+		"user" TEXT primary key,
+		"secret" TEXT,
+		"confirmed" INTEGER
+	);`
+
+	_, err = s.db.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	stmt = `CREATE TABLE if not exists recovery_codes (
+		"user" TEXT,
+		"code" TEXT
+	);`
+
+	_, err = s.db.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	stmt = `CREATE TABLE if not exists recovery_keys (
+		"user" TEXT primary key,
+		"hash" TEXT
+	);`
+
+	_, err = s.db.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	stmt = `CREATE TABLE if not exists sessions (
+		"id" TEXT primary key,
+		"user" TEXT,
+		"device" TEXT,
+		"os" TEXT,
+		"ip" TEXT,
+		"created" INTEGER,
+		"last_seen" INTEGER,
+		"expires" INTEGER,
+		"revoked" INTEGER
+	);`
+
+	_, err = s.db.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	stmt = `CREATE TABLE if not exists auth_attempts (
+		"key" TEXT primary key,
+		"failures" INTEGER,
+		"last_failure" INTEGER,
+		"locked_until" INTEGER
+	);`
+
+	_, err = s.db.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	// журнал аудита только дополняется: изменение и удаление записей запрещены триггерами
+	stmt = `CREATE TABLE if not exists audit_log (
+		"id" INTEGER primary key autoincrement,
+		"user" TEXT,
+		"event" TEXT,
+		"device" TEXT,
+		"ip" TEXT,
+		"time" INTEGER,
+		"details" TEXT
+	);
+	CREATE INDEX if not exists audit_log_user on audit_log ("user");
+	CREATE TRIGGER if not exists audit_log_no_update BEFORE UPDATE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log is append-only');
+	END;
+	CREATE TRIGGER if not exists audit_log_no_delete BEFORE DELETE ON audit_log
+	BEGIN
+		SELECT RAISE(ABORT, 'audit log is append-only');
+	END;`
+
+	_, err = s.db.Exec(stmt)
+	if err != nil {
+		return err
+	}
+
+	// базы, созданные до появления сроков смены паролей и папок
+	err = s.addColumn("storage", "expires_at", "INTEGER")
+	if err != nil {
+		return err
+	}
+	err = s.addColumn("storage", "folder", "TEXT")
+	if err != nil {
+		return err
+	}
+	// базы, созданные до защиты от повторного использования одноразовых кодов и срока действия сессий.
+	// Сессии без срока считаются истекшими
+	err = s.addColumn("totp", "last_step", "INTEGER")
+	if err != nil {
+		return err
+	}
+	return s.addColumn("sessions", "expires", "INTEGER")
+}
+
+// addColumn добавляет колонку в существующую таблицу, если ее еще нет
+func (s *ServerStorage) addColumn(table, column, decl string) error {
+	var c int
+	err := s.db.QueryRow(`select COUNT(*) from pragma_table_info($1) where name=$2`, table, column).Scan(&c)
+	if err != nil || c > 0 {
+		return err
+	}
+
+	_, err = s.db.Exec(fmt.Sprintf(`alter table %s add column "%s" %s;`, table, column, decl))
+	return err
 }
 
 func (s *ServerStorage) CreateUser(login, pass string) (string, error) {
@@ -127,56 +249,497 @@ func (s *ServerStorage) CheckUser(login string) (id string, pass string, err err
 	return id, pass, err
 }
 
-func (s *ServerStorage) SetData(req models.UserData, user string) error {
-	stmt := `insert into storage (id, user, data, comment) values ($1,$2,$3,$4);`
+func (s *ServerStorage) GetLogin(id string) (login string, err error) {
+	stmt := `select login from users where id=$1`
+	err = s.db.QueryRow(stmt, id).Scan(&login)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return login, err
+}
+
+func (s *ServerStorage) UpdatePassword(id, pass string) error {
+	stmt := `update users set pass=$1 where id=$2;`
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, err := s.db.Exec(stmt, req.ID, user, req.Data, req.Comment)
+	_, err := s.db.Exec(stmt, pass, id)
 	return err
 }
 
-func (s *ServerStorage) GetData(user string) ([]models.UserData, error) {
-	stmt := `select id,data,comment from storage where user=$1;`
+func (s *ServerStorage) UpdateLogin(id, login string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var c int
+	err := s.db.QueryRow(`select COUNT(*) from users where login=$1 AND id!=$2`, login, id).Scan(&c)
+	if err != nil {
+		return err
+	}
+	if c > 0 {
+		return models.ErrUserConflict
+	}
+
+	_, err = s.db.Exec(`update users set login=$1 where id=$2;`, login, id)
+	return err
+}
+
+// DeleteUser удаляет пользователя и все связанные с ним записи в одной транзакции
+func (s *ServerStorage) DeleteUser(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		`delete from storage where "user"=$1;`,
+		`delete from sessions where "user"=$1;`,
+		`delete from totp where "user"=$1;`,
+		`delete from recovery_codes where "user"=$1;`,
+		`delete from recovery_keys where "user"=$1;`,
+		`delete from users where id=$1;`,
+	}
+	for _, stmt := range stmts {
+		_, err = tx.Exec(stmt, id)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *ServerStorage) SetTOTP(user, secret string) error {
+	stmt := `replace into totp ("user", secret, confirmed) values ($1,$2,0);`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, user, secret)
+	return err
+}
+
+func (s *ServerStorage) ConfirmTOTP(user string) error {
+	stmt := `update totp set confirmed=1 where "user"=$1;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, user)
+	return err
+}
+
+func (s *ServerStorage) GetTOTP(user string) (secret string, confirmed bool, err error) {
+	stmt := `select secret, confirmed from totp where "user"=$1`
+	err = s.db.QueryRow(stmt, user).Scan(&secret, &confirmed)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", false, nil
+	}
+
+	return secret, confirmed, err
+}
+
+func (s *ServerStorage) UseTOTPStep(user string, step int64) (bool, error) {
+	stmt := `update totp set last_step=$1 where "user"=$2 and (last_step is null or last_step < $1);`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.db.Exec(stmt, step, user)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *ServerStorage) DeleteTOTP(user string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, stmt := range []string{`delete from totp where "user"=$1;`, `delete from recovery_codes where "user"=$1;`} {
+		_, err = tx.Exec(stmt, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *ServerStorage) SetRecoveryCodes(user string, codes []string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	_, err = tx.Exec(`delete from recovery_codes where "user"=$1;`, user)
+	if err != nil {
+		return err
+	}
+
+	for _, code := range codes {
+		_, err = tx.Exec(`insert into recovery_codes ("user", code) values ($1,$2);`, user, code)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (s *ServerStorage) UseRecoveryCode(user, code string) (bool, error) {
+	stmt := `delete from recovery_codes where "user"=$1 AND code=$2;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res, err := s.db.Exec(stmt, user, code)
+	if err != nil {
+		return false, err
+	}
+
+	n, err := res.RowsAffected()
+	return n > 0, err
+}
+
+func (s *ServerStorage) GetAttempts(key string) (models.AuthAttempts, error) {
+	stmt := `select failures, last_failure, locked_until from auth_attempts where "key"=$1`
+
+	var (
+		a                 models.AuthAttempts
+		lastFailure, lock int64
+	)
+	err := s.db.QueryRow(stmt, key).Scan(&a.Failures, &lastFailure, &lock)
+	if errors.Is(err, sql.ErrNoRows) {
+		return a, nil
+	}
+	if err != nil {
+		return a, err
+	}
+
+	a.LastFailure = time.Unix(lastFailure, 0)
+	a.LockedUntil = time.Unix(lock, 0)
+	return a, nil
+}
+
+func (s *ServerStorage) SetAttempts(key string, a models.AuthAttempts) error {
+	stmt := `replace into auth_attempts ("key", failures, last_failure, locked_until) values ($1,$2,$3,$4);`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, key, a.Failures, a.LastFailure.Unix(), a.LockedUntil.Unix())
+	return err
+}
+
+func (s *ServerStorage) ResetAttempts(key string) error {
+	stmt := `delete from auth_attempts where "key"=$1;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, key)
+	return err
+}
+
+func (s *ServerStorage) SetRecoveryKey(user, hash string) error {
+	stmt := `replace into recovery_keys ("user", hash) values ($1,$2);`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, user, hash)
+	return err
+}
+
+func (s *ServerStorage) GetRecoveryKey(user string) (hash string, err error) {
+	stmt := `select hash from recovery_keys where "user"=$1`
+	err = s.db.QueryRow(stmt, user).Scan(&hash)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", nil
+	}
+
+	return hash, err
+}
+
+func (s *ServerStorage) DeleteRecoveryKey(user string) error {
+	stmt := `delete from recovery_keys where "user"=$1;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, user)
+	return err
+}
+
+func (s *ServerStorage) CreateSession(session models.Session) error {
+	stmt := `insert into sessions (id, "user", device, os, ip, created, last_seen, expires, revoked) values ($1,$2,$3,$4,$5,$6,$7,$8,0);`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, session.ID, session.User, session.Device, session.OS, session.IP,
+		session.Created.Unix(), session.LastSeen.Unix(), session.Expires.Unix())
+	return err
+}
+
+func (s *ServerStorage) GetSession(id string) (models.Session, error) {
+	stmt := `select id, "user", device, os, ip, created, last_seen, coalesce(expires, 0), revoked from sessions where id=$1`
+
+	session, err := scanSession(s.db.QueryRow(stmt, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return models.Session{}, nil
+	}
+
+	return session, err
+}
+
+func (s *ServerStorage) GetSessions(user string) ([]models.Session, error) {
+	stmt := `select id, "user", device, os, ip, created, last_seen, coalesce(expires, 0), revoked from sessions where "user"=$1 order by last_seen desc`
 	r, err := s.db.Query(stmt, user)
 	if err != nil {
 		return nil, err
 	}
-	if r.Err() != nil {
+	defer r.Close()
+
+	var res []models.Session
+	for r.Next() {
+		session, err := scanSession(r)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, session)
+	}
+
+	return res, r.Err()
+}
+
+func (s *ServerStorage) CountActiveUsers(since, now time.Time) (count int, err error) {
+	stmt := `select COUNT(distinct "user") from sessions where revoked=0 AND last_seen>=$1 AND expires>$2`
+	err = s.db.QueryRow(stmt, since.Unix(), now.Unix()).Scan(&count)
+	return count, err
+}
+
+func (s *ServerStorage) TouchSession(id, ip string, t time.Time) error {
+	stmt := `update sessions set last_seen=$1, ip=$2 where id=$3;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, t.Unix(), ip, id)
+	return err
+}
+
+func (s *ServerStorage) ExtendSession(id string, expires time.Time) error {
+	stmt := `update sessions set expires=$1 where id=$2;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, expires.Unix(), id)
+	return err
+}
+
+func (s *ServerStorage) RevokeSession(id, user string) error {
+	stmt := `update sessions set revoked=1 where id=$1 AND "user"=$2;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, id, user)
+	return err
+}
+
+func (s *ServerStorage) RevokeSessions(user, except string) error {
+	stmt := `update sessions set revoked=1 where "user"=$1 AND id!=$2;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, user, except)
+	return err
+}
+
+func (s *ServerStorage) AddAuditEvent(e models.AuditEvent) error {
+	stmt := `insert into audit_log ("user", event, device, ip, time, details) values ($1,$2,$3,$4,$5,$6);`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, e.User, e.Event, e.Device, e.IP, e.Time.Unix(), e.Details)
+	return err
+}
+
+func (s *ServerStorage) GetAuditEvents(user string, limit int) ([]models.AuditEvent, error) {
+	stmt := `select "user", event, device, ip, time, details from audit_log where "user"=$1 order by id desc limit $2`
+	r, err := s.db.Query(stmt, user, limit)
+	if err != nil {
 		return nil, err
 	}
 	defer r.Close()
 
+	var res []models.AuditEvent
+	for r.Next() {
+		var (
+			e models.AuditEvent
+			t int64
+		)
+		err = r.Scan(&e.User, &e.Event, &e.Device, &e.IP, &t, &e.Details)
+		if err != nil {
+			return nil, err
+		}
+		e.Time = time.Unix(t, 0)
+		res = append(res, e)
+	}
+
+	return res, r.Err()
+}
+
+// scanSession читает сессию из строки результата запроса
+func scanSession(r interface{ Scan(dest ...any) error }) (models.Session, error) {
 	var (
-		data models.UserData
-		res  []models.UserData
+		session                    models.Session
+		created, lastSeen, expires int64
 	)
 
+	err := r.Scan(&session.ID, &session.User, &session.Device, &session.OS, &session.IP, &created, &lastSeen, &expires,
+		&session.Revoked)
+	if err != nil {
+		return models.Session{}, err
+	}
+
+	session.Created = time.Unix(created, 0)
+	session.LastSeen = time.Unix(lastSeen, 0)
+	session.Expires = time.Unix(expires, 0)
+	return session, nil
+}
+
+func (s *ServerStorage) SetData(req models.UserData, user string) error {
+	stmt := `insert into storage (id, user, data, comment, expires_at, folder) values ($1,$2,$3,$4,$5,$6);`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, err := s.db.Exec(stmt, req.ID, user, req.Data, req.Comment, nullTime(req.ExpiresAt), req.Folder)
+	return err
+}
+
+func (s *ServerStorage) GetData(user string) ([]models.UserData, error) {
+	stmt := `select id,data,comment,expires_at,folder from storage where user=$1;`
+	return s.queryData(stmt, user)
+}
+
+// GetDueData возвращает записи пользователя со сроком смены пароля раньше before, начиная с ближайших
+func (s *ServerStorage) GetDueData(user string, before time.Time) ([]models.UserData, error) {
+	stmt := `select id,data,comment,expires_at,folder from storage where user=$1 AND expires_at IS NOT NULL AND expires_at<$2
+		order by expires_at;`
+	return s.queryData(stmt, user, before.Unix())
+}
+
+// GetFolderData возвращает записи пользователя, лежащие непосредственно в папке folder (пустая строка - в корне)
+func (s *ServerStorage) GetFolderData(user, folder string) ([]models.UserData, error) {
+	stmt := `select id,data,comment,expires_at,folder from storage where user=$1 AND IFNULL(folder,'')=$2;`
+	return s.queryData(stmt, user, folder)
+}
+
+func (s *ServerStorage) queryData(stmt string, args ...any) ([]models.UserData, error) {
+	r, err := s.db.Query(stmt, args...)
+	if err != nil {
+		return nil, err
+	}
+	if r.Err() != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	var res []models.UserData
 	for r.Next() {
-		err = r.Scan(&data.ID, &data.Data, &data.Comment)
+		var (
+			data    models.UserData
+			expires sql.NullInt64
+			folder  sql.NullString
+		)
+		err = r.Scan(&data.ID, &data.Data, &data.Comment, &expires, &folder)
 		if err != nil {
 			return nil, err
 		}
+		if expires.Valid {
+			t := time.Unix(expires.Int64, 0).UTC()
+			data.ExpiresAt = &t
+		}
+		data.Folder = folder.String
 		res = append(res, data)
 	}
 
 	return res, nil
 }
 
+// Delete удаляет запись. Если запись была папкой, ее содержимое переносится в родительскую папку
 func (s *ServerStorage) Delete(req models.DeleteRequest, user string) error {
-	stmt := `delete from storage where id=$1 AND user=$2`
-
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, err := s.db.Exec(stmt, req.ID, user)
-	return err
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	stmts := []string{
+		`update storage set folder=(select folder from storage where id=$1 AND user=$2) where folder=$1 AND user=$2;`,
+		`delete from storage where id=$1 AND user=$2;`,
+	}
+	for _, stmt := range stmts {
+		_, err = tx.Exec(stmt, req.ID, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
 }
 
+// Update обновляет содержимое записи пользователя или добавляет ее. Папка существующей записи не меняется
 func (s *ServerStorage) Update(req models.UserData, user string) error {
-	stmt := `replace into storage(id, user, data, comment) VALUES ($1,$2,$3,$4);`
+	stmt := `insert into storage(id, user, data, comment, expires_at, folder) VALUES ($1,$2,$3,$4,$5,$6)
+		on conflict(id) do update set data=excluded.data, comment=excluded.comment, expires_at=excluded.expires_at
+		where storage.user=excluded.user;`
 
 	s.mu.Lock()
 	defer s.mu.Unlock()
-	_, err := s.db.Exec(stmt, req.ID, user, req.Data, req.Comment)
+	_, err := s.db.Exec(stmt, req.ID, user, req.Data, req.Comment, nullTime(req.ExpiresAt), req.Folder)
 	return err
 }
+
+// MoveData переносит записи пользователя в папку req.Folder
+func (s *ServerStorage) MoveData(req models.MoveRequest, user string) error {
+	stmt := `update storage set folder=$1 where id=$2 AND user=$3;`
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, id := range req.IDs {
+		_, err = tx.Exec(stmt, req.Folder, id, user)
+		if err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// nullTime переводит необязательное время в unix секунды или NULL
+func nullTime(t *time.Time) sql.NullInt64 {
+	if t == nil {
+		return sql.NullInt64{}
+	}
+	return sql.NullInt64{Int64: t.Unix(), Valid: true}
+}
@@ -0,0 +1,202 @@
+package server_repo
+
+import (
+	"database/sql"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/azazel3ooo/keeper/internal/models"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestServerStorage_auditLog(t *testing.T) {
+	var storage ServerStorage
+	err := storage.Init(filepath.Join(t.TempDir(), "server.db"))
+	assert.Nil(t, err)
+	defer storage.Close()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	events := []models.AuditEvent{
+		{User: "user", Event: models.AuditLogin, Device: "laptop", IP: "127.0.0.1", Time: now},
+		{User: "other", Event: models.AuditLogin, IP: "127.0.0.2", Time: now},
+		{User: "user", Event: models.AuditRecordCreate, Device: "laptop", IP: "127.0.0.1", Time: now, Details: "id"},
+	}
+	for _, e := range events {
+		err = storage.AddAuditEvent(e)
+		assert.Nil(t, err)
+	}
+
+	res, err := storage.GetAuditEvents("user", 10)
+	assert.Nil(t, err)
+	assert.Equal(t, []models.AuditEvent{events[2], events[0]}, res)
+
+	_, err = storage.db.Exec(`update audit_log set event='login' where "user"='user'`)
+	assert.NotNilf(t, err, "update is forbidden")
+	_, err = storage.db.Exec(`delete from audit_log`)
+	assert.NotNilf(t, err, "delete is forbidden")
+}
+
+func TestServerStorage_GetDueData(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.db")
+
+	// база, созданная до появления колонки expires_at
+	old, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	_, err = old.Exec(`CREATE TABLE storage ("id" TEXT PRIMARY key, "user" TEXT, "data" TEXT, "comment" TEXT);
+		insert into storage values ('legacy', 'user', 'data', '');`)
+	assert.Nil(t, err)
+	old.Close()
+
+	var storage ServerStorage
+	err = storage.Init(path)
+	assert.Nil(t, err)
+	defer storage.Close()
+
+	now := time.Unix(time.Now().Unix(), 0).UTC()
+	soon, later := now.Add(time.Hour), now.Add(48*time.Hour)
+	assert.Nil(t, storage.SetData(models.UserData{ID: "later", Data: "data", ExpiresAt: &later}, "user"))
+	assert.Nil(t, storage.SetData(models.UserData{ID: "soon", Data: "data", ExpiresAt: &soon}, "user"))
+	assert.Nil(t, storage.SetData(models.UserData{ID: "other", Data: "data", ExpiresAt: &soon}, "other"))
+
+	res, err := storage.GetDueData("user", now.Add(72*time.Hour))
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UserData{
+		{ID: "soon", Data: "data", ExpiresAt: &soon},
+		{ID: "later", Data: "data", ExpiresAt: &later},
+	}, res)
+
+	// обновление без срока снимает его
+	assert.Nil(t, storage.Update(models.UserData{ID: "soon", Data: "data"}, "user"))
+	res, err = storage.GetDueData("user", now.Add(2*time.Hour))
+	assert.Nil(t, err)
+	assert.Empty(t, res)
+
+	all, err := storage.GetData("user")
+	assert.Nil(t, err)
+	assert.Len(t, all, 3)
+}
+
+func TestServerStorage_folders(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "server.db")
+
+	// база, созданная до появления колонки folder
+	old, err := sql.Open("sqlite3", path)
+	assert.Nil(t, err)
+	_, err = old.Exec(`CREATE TABLE storage ("id" TEXT PRIMARY key, "user" TEXT, "data" TEXT, "comment" TEXT,
+		"expires_at" INTEGER);
+		insert into storage values ('legacy', 'user', 'data', '', NULL);`)
+	assert.Nil(t, err)
+	old.Close()
+
+	var storage ServerStorage
+	err = storage.Init(path)
+	assert.Nil(t, err)
+	defer storage.Close()
+
+	assert.Nil(t, storage.SetData(models.UserData{ID: "work", Data: "folder"}, "user"))
+	assert.Nil(t, storage.SetData(models.UserData{ID: "dev", Data: "folder", Folder: "work"}, "user"))
+	assert.Nil(t, storage.SetData(models.UserData{ID: "github", Data: "data", Folder: "dev"}, "user"))
+	assert.Nil(t, storage.SetData(models.UserData{ID: "other", Data: "data", Folder: "work"}, "other"))
+
+	ids := func(folder string) []string {
+		res, err := storage.GetFolderData("user", folder)
+		assert.Nil(t, err)
+		var ids []string
+		for _, el := range res {
+			ids = append(ids, el.ID)
+		}
+		return ids
+	}
+	assert.ElementsMatch(t, []string{"legacy", "work"}, ids(""))
+	assert.ElementsMatch(t, []string{"dev"}, ids("work"))
+
+	// обновление содержимого не меняет папку и не затрагивает записи других пользователей
+	assert.Nil(t, storage.Update(models.UserData{ID: "github", Data: "new"}, "user"))
+	assert.Nil(t, storage.Update(models.UserData{ID: "other", Data: "stolen"}, "user"))
+	res, err := storage.GetFolderData("user", "dev")
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UserData{{ID: "github", Data: "new", Folder: "dev"}}, res)
+	res, err = storage.GetData("other")
+	assert.Nil(t, err)
+	assert.Equal(t, []models.UserData{{ID: "other", Data: "data", Folder: "work"}}, res)
+
+	assert.Nil(t, storage.MoveData(models.MoveRequest{IDs: []string{"legacy", "other"}, Folder: "dev"}, "user"))
+	assert.ElementsMatch(t, []string{"github", "legacy"}, ids("dev"))
+
+	// содержимое удаленной папки переносится в ее родителя
+	assert.Nil(t, storage.Delete(models.DeleteRequest{ID: "dev"}, "user"))
+	assert.ElementsMatch(t, []string{"github", "legacy"}, ids("work"))
+	res, err = storage.GetData("other")
+	assert.Nil(t, err)
+	assert.Equal(t, "work", res[0].Folder)
+}
+
+func TestServerStorage_totp(t *testing.T) {
+	var storage ServerStorage
+	err := storage.Init(filepath.Join(t.TempDir(), "server.db"))
+	assert.Nil(t, err)
+	defer storage.Close()
+
+	err = storage.SetTOTP("user", "secret")
+	assert.Nil(t, err)
+	err = storage.SetRecoveryCodes("user", []string{"code"})
+	assert.Nil(t, err)
+
+	tests := []struct {
+		description string
+		step        int64
+		want        bool
+	}{
+		{description: "first code", step: 10, want: true},
+		{description: "same period", step: 10, want: false},
+		{description: "earlier period", step: 9, want: false},
+		{description: "next period", step: 11, want: true},
+	}
+	for _, tt := range tests {
+		ok, err := storage.UseTOTPStep("user", tt.step)
+		assert.Nilf(t, err, tt.description)
+		assert.Equalf(t, tt.want, ok, tt.description)
+	}
+
+	err = storage.DeleteTOTP("user")
+	assert.Nil(t, err)
+	secret, _, err := storage.GetTOTP("user")
+	assert.Nil(t, err)
+	assert.Empty(t, secret)
+	ok, err := storage.UseRecoveryCode("user", "code")
+	assert.Nil(t, err)
+	assert.False(t, ok)
+}
+
+func TestServerStorage_sessions(t *testing.T) {
+	var storage ServerStorage
+	err := storage.Init(filepath.Join(t.TempDir(), "server.db"))
+	assert.Nil(t, err)
+	defer storage.Close()
+
+	now := time.Unix(time.Now().Unix(), 0)
+	sessions := []models.Session{
+		{ID: "active", User: "user", Created: now, LastSeen: now, Expires: now.Add(time.Minute)},
+		{ID: "expired", User: "other", Created: now, LastSeen: now, Expires: now},
+	}
+	for _, el := range sessions {
+		err = storage.CreateSession(el)
+		assert.Nil(t, err)
+	}
+
+	session, err := storage.GetSession("active")
+	assert.Nil(t, err)
+	assert.Equal(t, sessions[0], session)
+
+	count, err := storage.CountActiveUsers(now.Add(-time.Minute), now)
+	assert.Nil(t, err)
+	assert.Equalf(t, 1, count, "expired session is not active")
+
+	// сессии, созданные до появления срока действия, считаются истекшими
+	_, err = storage.db.Exec(`update sessions set expires=null where id='active'`)
+	assert.Nil(t, err)
+	session, err = storage.GetSession("active")
+	assert.Nil(t, err)
+	assert.Equal(t, time.Unix(0, 0), session.Expires)
+}
@@ -2,6 +2,8 @@ package testing_repos_server
 
 import (
 	"errors"
+	"sync"
+	"time"
 
 	"github.com/azazel3ooo/keeper/internal/models"
 )
@@ -12,25 +14,58 @@ type TestUser struct {
 }
 
 type TestExample struct {
-	User    string
-	Data    string
-	Comment string
+	User      string
+	Data      string
+	Comment   string
+	ExpiresAt *time.Time
+	Folder    string
+}
+
+type TestTOTP struct {
+	Secret    string
+	Confirmed bool
+	LastStep  int64
 }
 
 type TestUsers map[string]TestUser
 type TestData map[string]TestExample
+type TestTOTPs map[string]TestTOTP
+type TestRecoveryCodes map[string][]string
+type TestAttempts map[string]models.AuthAttempts
+type TestSessions map[string]models.Session
+type TestRecoveryKeys map[string]string
+type TestAudit []models.AuditEvent
 
+// TestingServerStorage хранилище сервера в памяти для тестов. Методы защищены мьютексом, потому что
+// потоки Watch обращаются к нему одновременно с обработчиками запросов
 type TestingServerStorage struct {
-	users TestUsers
-	data  TestData
+	mu            *sync.RWMutex
+	users         TestUsers
+	data          TestData
+	totp          TestTOTPs
+	recoveryCodes TestRecoveryCodes
+	attempts      TestAttempts
+	sessions      TestSessions
+	recoveryKeys  TestRecoveryKeys
+	audit         *TestAudit
 }
 
 func (t *TestingServerStorage) Init() {
+	t.mu = new(sync.RWMutex)
 	t.users = make(TestUsers)
 	t.data = make(TestData)
+	t.totp = make(TestTOTPs)
+	t.recoveryCodes = make(TestRecoveryCodes)
+	t.attempts = make(TestAttempts)
+	t.sessions = make(TestSessions)
+	t.recoveryKeys = make(TestRecoveryKeys)
+	t.audit = new(TestAudit)
 }
 
 func (t TestingServerStorage) CreateUser(log, pas string) (string, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	for k, _ := range t.users {
 		if log == t.users[k].Log {
 			return "", models.ErrUserConflict
@@ -44,6 +79,9 @@ func (t TestingServerStorage) CreateUser(log, pas string) (string, error) {
 }
 
 func (t TestingServerStorage) CheckUser(login string) (id string, pass string, err error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
 	for k, _ := range t.users {
 		if login == t.users[k].Log {
 			return k, t.users[k].Pas, nil
@@ -53,25 +91,335 @@ func (t TestingServerStorage) CheckUser(login string) (id string, pass string, e
 	return "", "", nil
 }
 
+func (t TestingServerStorage) GetLogin(id string) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.users[id].Log, nil
+}
+
+func (t TestingServerStorage) UpdatePassword(id, pass string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	u := t.users[id]
+	u.Pas = pass
+	t.users[id] = u
+	return nil
+}
+
+func (t TestingServerStorage) UpdateLogin(id, login string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k := range t.users {
+		if k != id && t.users[k].Log == login {
+			return models.ErrUserConflict
+		}
+	}
+
+	u := t.users[id]
+	u.Log = login
+	t.users[id] = u
+	return nil
+}
+
+func (t TestingServerStorage) DeleteUser(id string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for k, v := range t.data {
+		if v.User == id {
+			delete(t.data, k)
+		}
+	}
+	for k, v := range t.sessions {
+		if v.User == id {
+			delete(t.sessions, k)
+		}
+	}
+	delete(t.totp, id)
+	delete(t.recoveryCodes, id)
+	delete(t.recoveryKeys, id)
+	delete(t.users, id)
+	return nil
+}
+
+func (t TestingServerStorage) SetTOTP(user, secret string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.totp[user] = TestTOTP{Secret: secret}
+	return nil
+}
+
+func (t TestingServerStorage) ConfirmTOTP(user string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	v, ok := t.totp[user]
+	if !ok {
+		return errors.New("unknown user")
+	}
+
+	v.Confirmed = true
+	t.totp[user] = v
+	return nil
+}
+
+func (t TestingServerStorage) GetTOTP(user string) (string, bool, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	v := t.totp[user]
+	return v.Secret, v.Confirmed, nil
+}
+
+func (t TestingServerStorage) UseTOTPStep(user string, step int64) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	v, ok := t.totp[user]
+	if !ok || v.LastStep >= step {
+		return false, nil
+	}
+
+	v.LastStep = step
+	t.totp[user] = v
+	return true, nil
+}
+
+func (t TestingServerStorage) DeleteTOTP(user string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.totp, user)
+	delete(t.recoveryCodes, user)
+	return nil
+}
+
+func (t TestingServerStorage) SetRecoveryCodes(user string, codes []string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recoveryCodes[user] = append([]string(nil), codes...)
+	return nil
+}
+
+func (t TestingServerStorage) UseRecoveryCode(user, code string) (bool, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	codes := t.recoveryCodes[user]
+	for i := range codes {
+		if codes[i] == code {
+			t.recoveryCodes[user] = append(codes[:i], codes[i+1:]...)
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (t TestingServerStorage) GetAttempts(key string) (models.AuthAttempts, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.attempts[key], nil
+}
+
+func (t TestingServerStorage) SetAttempts(key string, a models.AuthAttempts) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.attempts[key] = a
+	return nil
+}
+
+func (t TestingServerStorage) ResetAttempts(key string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.attempts, key)
+	return nil
+}
+
+func (t TestingServerStorage) SetRecoveryKey(user, hash string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.recoveryKeys[user] = hash
+	return nil
+}
+
+func (t TestingServerStorage) GetRecoveryKey(user string) (string, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.recoveryKeys[user], nil
+}
+
+func (t TestingServerStorage) DeleteRecoveryKey(user string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	delete(t.recoveryKeys, user)
+	return nil
+}
+
+func (t TestingServerStorage) AddAuditEvent(e models.AuditEvent) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	*t.audit = append(*t.audit, e)
+	return nil
+}
+
+func (t TestingServerStorage) GetAuditEvents(user string, limit int) ([]models.AuditEvent, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var res []models.AuditEvent
+	for i := len(*t.audit) - 1; i >= 0 && len(res) < limit; i-- {
+		if (*t.audit)[i].User == user {
+			res = append(res, (*t.audit)[i])
+		}
+	}
+	return res, nil
+}
+
+func (t TestingServerStorage) CreateSession(s models.Session) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.sessions[s.ID] = s
+	return nil
+}
+
+func (t TestingServerStorage) GetSession(id string) (models.Session, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	return t.sessions[id], nil
+}
+
+func (t TestingServerStorage) GetSessions(user string) ([]models.Session, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	var res []models.Session
+	for _, s := range t.sessions {
+		if s.User == user {
+			res = append(res, s)
+		}
+	}
+
+	return res, nil
+}
+
+func (t TestingServerStorage) Ping() error {
+	return nil
+}
+
+func (t TestingServerStorage) CountActiveUsers(since, now time.Time) (int, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
+	users := make(map[string]bool)
+	for _, s := range t.sessions {
+		if !s.Revoked && !s.LastSeen.Before(since) && s.Expires.After(now) {
+			users[s.User] = true
+		}
+	}
+
+	return len(users), nil
+}
+
+func (t TestingServerStorage) TouchSession(id, ip string, at time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[id]
+	if !ok {
+		return nil
+	}
+
+	s.IP = ip
+	s.LastSeen = at
+	t.sessions[id] = s
+	return nil
+}
+
+func (t TestingServerStorage) ExtendSession(id string, expires time.Time) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[id]
+	if !ok {
+		return nil
+	}
+
+	s.Expires = expires
+	t.sessions[id] = s
+	return nil
+}
+
+func (t TestingServerStorage) RevokeSession(id, user string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	s, ok := t.sessions[id]
+	if ok && s.User == user {
+		s.Revoked = true
+		t.sessions[id] = s
+	}
+
+	return nil
+}
+
+func (t TestingServerStorage) RevokeSessions(user, except string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for id, s := range t.sessions {
+		if s.User == user && id != except {
+			s.Revoked = true
+			t.sessions[id] = s
+		}
+	}
+
+	return nil
+}
+
 func (t TestingServerStorage) SetData(req models.UserData, user string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	t.data[req.ID] = TestExample{
-		User:    user,
-		Data:    req.Data,
-		Comment: req.Comment,
+		User:      user,
+		Data:      req.Data,
+		Comment:   req.Comment,
+		ExpiresAt: req.ExpiresAt,
+		Folder:    req.Folder,
 	}
 
 	return nil
 }
 
 func (t TestingServerStorage) GetData(user string) ([]models.UserData, error) {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+
 	var res []models.UserData
 
 	for k, v := range t.data {
 		if v.User == user {
 			tmp := models.UserData{
-				ID:      k,
-				Data:    v.Data,
-				Comment: v.Comment,
+				ID:        k,
+				Data:      v.Data,
+				Comment:   v.Comment,
+				ExpiresAt: v.ExpiresAt,
+				Folder:    v.Folder,
 			}
 			res = append(res, tmp)
 		}
@@ -80,7 +428,34 @@ func (t TestingServerStorage) GetData(user string) ([]models.UserData, error) {
 	return res, nil
 }
 
+func (t TestingServerStorage) GetDueData(user string, before time.Time) ([]models.UserData, error) {
+	data, _ := t.GetData(user)
+
+	var res []models.UserData
+	for _, el := range data {
+		if el.ExpiresAt != nil && el.ExpiresAt.Before(before) {
+			res = append(res, el)
+		}
+	}
+	return res, nil
+}
+
+func (t TestingServerStorage) GetFolderData(user, folder string) ([]models.UserData, error) {
+	data, _ := t.GetData(user)
+
+	var res []models.UserData
+	for _, el := range data {
+		if el.Folder == folder {
+			res = append(res, el)
+		}
+	}
+	return res, nil
+}
+
 func (t TestingServerStorage) Delete(req models.DeleteRequest, user string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
 	v, ok := t.data[req.ID]
 	if !ok {
 		return errors.New("unknown ID")
@@ -88,15 +463,48 @@ func (t TestingServerStorage) Delete(req models.DeleteRequest, user string) erro
 
 	if v.User == user {
 		delete(t.data, req.ID)
+		for k, el := range t.data {
+			if el.User == user && el.Folder == req.ID {
+				el.Folder = v.Folder
+				t.data[k] = el
+			}
+		}
 	}
 	return nil
 }
 
 func (t TestingServerStorage) Update(req models.UserData, user string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	folder := req.Folder
+	if v, ok := t.data[req.ID]; ok {
+		if v.User != user {
+			return nil
+		}
+		folder = v.Folder
+	}
+
 	t.data[req.ID] = TestExample{
-		User:    user,
-		Data:    req.Data,
-		Comment: req.Comment,
+		User:      user,
+		Data:      req.Data,
+		Comment:   req.Comment,
+		ExpiresAt: req.ExpiresAt,
+		Folder:    folder,
+	}
+	return nil
+}
+
+func (t TestingServerStorage) MoveData(req models.MoveRequest, user string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, id := range req.IDs {
+		v, ok := t.data[id]
+		if ok && v.User == user {
+			v.Folder = req.Folder
+			t.data[id] = v
+		}
 	}
 	return nil
 }
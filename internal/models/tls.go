@@ -0,0 +1,120 @@
+package models
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"os"
+)
+
+var (
+	ErrInvalidTLSVersion = errors.New("unsupported tls min_version, expected 1.2 or 1.3")
+	ErrNoCertificates    = errors.New("no certificates found in ca file")
+	ErrIncompleteKeyPair = errors.New("both cert_file and key_file must be set")
+)
+
+// Enabled сообщает, что серверу задан сертификат и соединения должны приниматься по TLS
+func (c TLSConfig) Enabled() bool {
+	return c.CertFile != "" || c.KeyFile != ""
+}
+
+// ServerConfig возвращает настройки TLS для сервера. При заданном ClientCAFile сервер требует сертификат клиента,
+// подписанный этим CA
+func (c TLSConfig) ServerConfig() (*tls.Config, error) {
+	minVersion, err := c.minVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	cert, err := c.keyPair()
+	if err != nil {
+		return nil, err
+	}
+	if cert == nil {
+		return nil, ErrIncompleteKeyPair
+	}
+
+	cfg := &tls.Config{
+		MinVersion:   minVersion,
+		Certificates: []tls.Certificate{*cert},
+	}
+
+	if c.ClientCAFile != "" {
+		cfg.ClientCAs, err = loadCertPool(c.ClientCAFile)
+		if err != nil {
+			return nil, err
+		}
+		cfg.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+
+	return cfg, nil
+}
+
+// ClientConfig возвращает настройки TLS для клиента. Без CAFile используются системные доверенные сертификаты
+func (c TLSConfig) ClientConfig() (*tls.Config, error) {
+	minVersion, err := c.minVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	cfg := &tls.Config{MinVersion: minVersion}
+
+	if c.CAFile != "" {
+		cfg.RootCAs, err = loadCertPool(c.CAFile)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	cert, err := c.keyPair()
+	if err != nil {
+		return nil, err
+	}
+	if cert != nil {
+		cfg.Certificates = []tls.Certificate{*cert}
+	}
+
+	return cfg, nil
+}
+
+func (c TLSConfig) minVersion() (uint16, error) {
+	switch c.MinVersion {
+	case "", "1.2":
+		return tls.VersionTLS12, nil
+	case "1.3":
+		return tls.VersionTLS13, nil
+	}
+
+	return 0, ErrInvalidTLSVersion
+}
+
+// keyPair загружает сертификат и ключ. Возвращает nil, если они не заданы
+func (c TLSConfig) keyPair() (*tls.Certificate, error) {
+	if c.CertFile == "" && c.KeyFile == "" {
+		return nil, nil
+	}
+	if c.CertFile == "" || c.KeyFile == "" {
+		return nil, ErrIncompleteKeyPair
+	}
+
+	cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	return &cert, nil
+}
+
+func loadCertPool(path string) (*x509.CertPool, error) {
+	b, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	pool := x509.NewCertPool()
+	if !pool.AppendCertsFromPEM(b) {
+		return nil, ErrNoCertificates
+	}
+
+	return pool, nil
+}